@@ -2,6 +2,7 @@ package ast
 
 import (
     "bytes"
+    "math/big"
     "strings"
     "luederlang/token"
 )
@@ -9,6 +10,24 @@ import (
 type Node interface {
     TokenLiteral() string
     String() string
+    Pos() token.Position // where the node's token starts
+}
+
+// Where node begins in the source. That is its Pos for most nodes, but infix,
+// call, index and slice expressions have their operator as the token, so
+// runtime errors point at it, and begin at their left side.
+func Start(node Node) token.Position {
+    switch n := node.(type) {
+    case *InfixExpression:
+        return Start(n.Left)
+    case *CallExpression:
+        return Start(n.Function)
+    case *IndexExpression:
+        return Start(n.Left)
+    case *SliceExpression:
+        return Start(n.Left)
+    }
+    return node.Pos()
 }
 
 // All statement nodes implement this
@@ -35,6 +54,13 @@ func (p *Program) TokenLiteral() string {
     }
 }
 
+func (p *Program) Pos() token.Position {
+    if len(p.Statements) > 0 {
+        return p.Statements[0].Pos()
+    }
+    return token.Position{}
+}
+
 func (p *Program) String() string {
     var out bytes.Buffer
 
@@ -53,6 +79,7 @@ type IntStatement struct {
 
 func (is *IntStatement) statementNode() {}
 func (is *IntStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *IntStatement) Pos() token.Position { return is.Token.Pos }
 func (is *IntStatement) String() string {
     var out bytes.Buffer
 
@@ -77,6 +104,7 @@ type FloatStatement struct {
 
 func (fs *FloatStatement) statementNode() {}
 func (fs *FloatStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FloatStatement) Pos() token.Position { return fs.Token.Pos }
 func (fs *FloatStatement) String() string {
     var out bytes.Buffer
 
@@ -93,6 +121,101 @@ func (fs *FloatStatement) String() string {
     return out.String()
 }
 
+type BoolStatement struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+
+func (bs *BoolStatement) statementNode() {}
+func (bs *BoolStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BoolStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BoolStatement) String() string { return declarationString(bs.TokenLiteral(), bs.Name, bs.Value) }
+
+type StringStatement struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+
+func (ss *StringStatement) statementNode() {}
+func (ss *StringStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StringStatement) Pos() token.Position { return ss.Token.Pos }
+func (ss *StringStatement) String() string { return declarationString(ss.TokenLiteral(), ss.Name, ss.Value) }
+
+type ListStatement struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+
+func (ls *ListStatement) statementNode() {}
+func (ls *ListStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *ListStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *ListStatement) String() string { return declarationString(ls.TokenLiteral(), ls.Name, ls.Value) }
+
+type MapStatement struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+
+func (ms *MapStatement) statementNode() {}
+func (ms *MapStatement) TokenLiteral() string { return ms.Token.Literal }
+func (ms *MapStatement) Pos() token.Position { return ms.Token.Pos }
+func (ms *MapStatement) String() string { return declarationString(ms.TokenLiteral(), ms.Name, ms.Value) }
+
+type FunStatement struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+
+func (fs *FunStatement) statementNode() {}
+func (fs *FunStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunStatement) Pos() token.Position { return fs.Token.Pos }
+func (fs *FunStatement) String() string { return declarationString(fs.TokenLiteral(), fs.Name, fs.Value) }
+
+func declarationString(keyword string, name *Identifier, value Expression) string {
+    var out bytes.Buffer
+
+    out.WriteString(keyword + " ")
+    out.WriteString(name.String())
+    out.WriteString(" = ")
+
+    if value != nil {
+        out.WriteString(value.String())
+    }
+
+    out.WriteString(";")
+
+    return out.String()
+}
+
+// Splits any statement that binds a name into its parts. typeName is "" for
+// let statements, otherwise it is the declared type, e.g. "int" or "fun".
+func DeclarationOf(s Statement) (name *Identifier, value Expression, typeName string, ok bool) {
+    switch s := s.(type) {
+    case *LetStatement:
+        return s.Name, s.Value, "", true
+    case *IntStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *FloatStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *BoolStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *StringStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *ListStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *MapStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    case *FunStatement:
+        return s.Name, s.Value, s.TokenLiteral(), true
+    }
+    return nil, nil, "", false
+}
+
 type LetStatement struct {
     Token token.Token
     Name *Identifier
@@ -101,6 +224,7 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
     var out bytes.Buffer
 
@@ -124,6 +248,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
     var out bytes.Buffer
 
@@ -147,6 +272,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
     if es.Expression != nil {
         return es.Expression.String()
@@ -161,6 +287,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -179,6 +306,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 type Boolean struct {
@@ -188,6 +316,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IntegerLiteral struct {
@@ -197,8 +326,20 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// An int literal that doesn't fit in an int64
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode()      {}
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) Pos() token.Position { return bl.Token.Pos }
+func (bl *BigIntegerLiteral) String() string       { return bl.Token.Literal }
+
 type FloatLiteral struct {
 	Token token.Token
 	Value float64
@@ -206,6 +347,7 @@ type FloatLiteral struct {
 
 func (fl *FloatLiteral) expressionNode()      {}
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
 func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
 
 type PrefixExpression struct {
@@ -216,6 +358,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -236,6 +379,7 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position { return oe.Token.Pos }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -248,15 +392,19 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// At most one of ElseIf and Alternative is set, an else-if chain is a linked
+// list of IfExpressions that ends in an optional Alternative
 type IfExpression struct {
 	Token       token.Token // The 'if' token
 	Condition   Expression
 	Consequence *BlockStatement
+	ElseIf      *IfExpression
 	Alternative *BlockStatement
 }
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -265,7 +413,10 @@ func (ie *IfExpression) String() string {
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
 
-	if ie.Alternative != nil {
+	if ie.ElseIf != nil {
+		out.WriteString("else ")
+		out.WriteString(ie.ElseIf.String())
+	} else if ie.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ie.Alternative.String())
 	}
@@ -273,26 +424,48 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// A type written in a signature, e.g. the int in fun(int x) float { }
+type TypeAnnotation struct {
+	Token token.Token // one of the type keywords
+	Value string
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) Pos() token.Position  { return ta.Token.Pos }
+func (ta *TypeAnnotation) String() string       { return ta.Value }
+
+// ParameterTypes lines up with Parameters and holds nil for untyped parameters,
+// ReturnType is nil when the function does not declare one
 type FunctionLiteral struct {
-	Token      token.Token // The fun token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token // The fun token
+	Parameters     []*Identifier
+	ParameterTypes []*TypeAnnotation
+	ReturnType     *TypeAnnotation
+	Body           *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params = append(params, fl.ParameterTypes[i].String()+" "+p.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString(fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -302,10 +475,12 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+    Tail      bool // the enclosing function returns whatever this call returns
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -322,30 +497,52 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// x = 5, x += 5 and x++. Unlike a declaration it changes a variable that
+// already exists. Value is nil for ++ and --.
 type AssignStatement struct {
-    Token token.Token
+    Token token.Token // the identifier
     Name *Identifier
+    Operator string
     Value Expression
 }
 
 func (as *AssignStatement) statementNode() {}
 func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position { return as.Token.Pos }
 func (as *AssignStatement) String() string {
-    var out bytes.Buffer
+    return assignmentString(as.Name.String(), as.Operator, as.Value)
+}
 
-    out.WriteString(as.TokenLiteral() + " ")
-    out.WriteString(as.Name.String())
-    out.WriteString(" = ")
+func assignmentString(target string, operator string, value Expression) string {
+    var out bytes.Buffer
 
-    if as.Value != nil {
-        out.WriteString(as.Value.String())
+    out.WriteString(target)
+    if value == nil {
+        out.WriteString(operator)
+    } else {
+        out.WriteString(" " + operator + " ")
+        out.WriteString(value.String())
     }
 
     out.WriteString(";")
-    
+
     return out.String()
 }
 
+// The binary operator a compound assignment applies, "+" for both += and ++.
+// Empty for a plain =.
+func CompoundOperator(operator string) string {
+    switch operator {
+    case "++":
+        return "+"
+    case "--":
+        return "-"
+    case "=":
+        return ""
+    }
+    return operator[:len(operator)-1]
+}
+
 type StringLiteral struct {
     Token token.Token
     Value string
@@ -353,4 +550,345 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
 func (sl *StringLiteral) String() string { return sl.Token.Literal }
+
+// "a ${x} b", Parts alternate between the text around the ${ } as
+// StringLiterals and the expressions inside of them, text first and last
+type InterpolatedString struct {
+    Token token.Token // the STRING_START token
+    Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos }
+func (is *InterpolatedString) String() string {
+    var out bytes.Buffer
+
+    for i, part := range is.Parts {
+        if i%2 == 0 {
+            out.WriteString(part.String())
+        } else {
+            out.WriteString("${" + part.String() + "}")
+        }
+    }
+
+    return out.String()
+}
+
+type ListLiteral struct {
+    Token token.Token // the '[' token
+    Elements []Expression
+}
+
+func (ll *ListLiteral) expressionNode() {}
+func (ll *ListLiteral) TokenLiteral() string { return ll.Token.Literal }
+func (ll *ListLiteral) Pos() token.Position { return ll.Token.Pos }
+func (ll *ListLiteral) String() string {
+    var out bytes.Buffer
+
+    elements := []string{}
+    for _, el := range ll.Elements {
+        elements = append(elements, el.String())
+    }
+
+    out.WriteString("[")
+    out.WriteString(strings.Join(elements, ", "))
+    out.WriteString("]")
+
+    return out.String()
+}
+
+// lib.name is parsed as lib["name"], Token tells the two apart
+type IndexExpression struct {
+    Token token.Token // the '[' or '.' token
+    Left Expression
+    Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IndexExpression) String() string {
+    return "(" + ie.target() + ")"
+}
+
+func (ie *IndexExpression) IsMember() bool {
+    return ie.Token.Type == token.DOT
+}
+
+// xs[i] or lib.name, without the parentheses
+func (ie *IndexExpression) target() string {
+    if ie.IsMember() {
+        return ie.Left.String() + "." + ie.Index.(*StringLiteral).Value
+    }
+    return ie.Left.String() + "[" + ie.Index.String() + "]"
+}
+
+// Start and End are nil when omitted, e.g. xs[:2] or xs[1:]
+type SliceExpression struct {
+    Token token.Token // the '[' token
+    Left Expression
+    Start Expression
+    End Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("(")
+    out.WriteString(se.Left.String())
+    out.WriteString("[")
+    if se.Start != nil {
+        out.WriteString(se.Start.String())
+    }
+    out.WriteString(":")
+    if se.End != nil {
+        out.WriteString(se.End.String())
+    }
+    out.WriteString("])")
+
+    return out.String()
+}
+
+type MapLiteral struct {
+    Token token.Token // the '{' token
+    Keys []Expression
+    Values []Expression
+}
+
+func (ml *MapLiteral) expressionNode() {}
+func (ml *MapLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MapLiteral) Pos() token.Position { return ml.Token.Pos }
+func (ml *MapLiteral) String() string {
+    var out bytes.Buffer
+
+    pairs := []string{}
+    for i, key := range ml.Keys {
+        pairs = append(pairs, key.String()+": "+ml.Values[i].String())
+    }
+
+    out.WriteString("{")
+    out.WriteString(strings.Join(pairs, ", "))
+    out.WriteString("}")
+
+    return out.String()
+}
+
+type IndexAssignStatement struct {
+    Token token.Token // the first token of the target
+    Target *IndexExpression
+    Operator string
+    Value Expression // nil for ++ and --
+}
+
+func (ias *IndexAssignStatement) statementNode() {}
+func (ias *IndexAssignStatement) TokenLiteral() string { return ias.Token.Literal }
+func (ias *IndexAssignStatement) Pos() token.Position { return ias.Token.Pos }
+func (ias *IndexAssignStatement) String() string {
+    return assignmentString(ias.Target.target(), ias.Operator, ias.Value)
+}
+
+type WhileStatement struct {
+    Token token.Token // the 'while' token
+    Condition Expression
+    Body *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+func (ws *WhileStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("while")
+    out.WriteString(ws.Condition.String())
+    out.WriteString(" ")
+    out.WriteString(ws.Body.String())
+
+    return out.String()
+}
+
+// for (init; condition; post) { body }, any of the three clauses may be nil
+type ForStatement struct {
+    Token token.Token // the 'for' token
+    Init Statement
+    Condition Expression
+    Post Statement
+    Body *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+func (fs *ForStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("for(")
+    if fs.Init != nil {
+        out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+    }
+    out.WriteString("; ")
+    if fs.Condition != nil {
+        out.WriteString(fs.Condition.String())
+    }
+    out.WriteString("; ")
+    if fs.Post != nil {
+        out.WriteString(strings.TrimSuffix(fs.Post.String(), ";"))
+    }
+    out.WriteString(") ")
+    out.WriteString(fs.Body.String())
+
+    return out.String()
+}
+
+type ForInStatement struct {
+    Token token.Token // the 'for' token
+    Variable *Identifier
+    Iterable Expression
+    Body *BlockStatement
+}
+
+func (fis *ForInStatement) statementNode() {}
+func (fis *ForInStatement) TokenLiteral() string { return fis.Token.Literal }
+func (fis *ForInStatement) Pos() token.Position { return fis.Token.Pos }
+func (fis *ForInStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("for(")
+    out.WriteString(fis.Variable.String())
+    out.WriteString(" in ")
+    out.WriteString(fis.Iterable.String())
+    out.WriteString(") ")
+    out.WriteString(fis.Body.String())
+
+    return out.String()
+}
+
+type BreakStatement struct {
+    Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BreakStatement) String() string { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+    Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string { return cs.Token.Literal + ";" }
+
+// try { } catch (e) { } finally { }, either the catch or the finally may be
+// left out but not both
+type TryStatement struct {
+    Token token.Token // the 'try' token
+    Body *BlockStatement
+    CatchVariable *Identifier // nil without a catch
+    Catch *BlockStatement
+    Finally *BlockStatement
+}
+
+func (ts *TryStatement) statementNode() {}
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) Pos() token.Position { return ts.Token.Pos }
+func (ts *TryStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("try ")
+    out.WriteString(ts.Body.String())
+    if ts.Catch != nil {
+        out.WriteString(" catch(")
+        out.WriteString(ts.CatchVariable.String())
+        out.WriteString(") ")
+        out.WriteString(ts.Catch.String())
+    }
+    if ts.Finally != nil {
+        out.WriteString(" finally ")
+        out.WriteString(ts.Finally.String())
+    }
+
+    return out.String()
+}
+
+type ThrowStatement struct {
+    Token token.Token // the 'throw' token
+    Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position { return ts.Token.Pos }
+func (ts *ThrowStatement) String() string {
+    return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// import "path/to/lib.lueder" as lib
+type ImportStatement struct {
+    Token token.Token // the 'import' token
+    Path string
+    Name *Identifier
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position { return is.Token.Pos }
+func (is *ImportStatement) String() string {
+    return "import \"" + is.Path + "\" as " + is.Name.String() + ";"
+}
+
+// export in front of a declaration makes it visible to importers
+type ExportStatement struct {
+    Token token.Token // the 'export' token
+    Statement Statement
+}
+
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position { return es.Token.Pos }
+func (es *ExportStatement) String() string {
+    return "export " + es.Statement.String()
+}
+
+// Where the parser gave up on a statement. Partial is as much of it as did
+// parse, nil if nothing did, and any of its fields can be nil or bad.
+type BadStatement struct {
+    Token token.Token // the first token of the statement
+    Partial Statement
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BadStatement) String() string { return "<bad statement>" }
+
+// Stands in for an expression that is missing or didn't parse
+type BadExpression struct {
+    Token token.Token // where the expression should have started
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) Pos() token.Position { return be.Token.Pos }
+func (be *BadExpression) String() string { return "<bad expression>" }
+
+// The names a program exports, in order
+func ExportedNames(program *Program) []string {
+    names := []string{}
+    for _, s := range program.Statements {
+        if export, ok := s.(*ExportStatement); ok {
+            if name, _, _, ok := DeclarationOf(export.Statement); ok {
+                names = append(names, name.Value)
+            }
+        }
+    }
+    return names
+}
@@ -26,3 +26,24 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestDump(t *testing.T) {
+	node := &ExpressionStatement{
+		Token: token.Token{Type: token.IDENT, Literal: "f", Pos: token.Position{Line: 1, Column: 1}},
+		Expression: &CallExpression{
+			Token:     token.Token{Type: token.LPAREN, Literal: "(", Pos: token.Position{Line: 1, Column: 2}},
+			Function:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "f", Pos: token.Position{Line: 1, Column: 1}}, Value: "f"},
+			Arguments: []Expression{&Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: token.Position{Line: 1, Column: 3}}, Value: false}},
+			Tail:      true,
+		},
+	}
+
+	expected := `ExpressionStatement 1:1
+  Expression: CallExpression Tail=true 1:2
+    Function: Identifier Value="f" 1:1
+    Arguments[0]: Boolean Value=false 1:3
+`
+	if got := Dump(node); got != expected {
+		t.Errorf("Dump wrong.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+}
@@ -0,0 +1,68 @@
+package ast
+
+import (
+    "fmt"
+    "reflect"
+    "strings"
+)
+
+// Dump prints node as an indented tree, one node per line with the field it
+// sits in, its plain fields and where it starts:
+//
+//	ExpressionStatement 1:1
+//	  Expression: InfixExpression Operator="+" 1:1
+//	    Left: IntegerLiteral Value=1 1:1
+//	    Right: Identifier Value="x" 1:5
+func Dump(node Node) string {
+    var out strings.Builder
+    dump(&out, "", node, 0)
+    return out.String()
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func dump(out *strings.Builder, label string, node Node, depth int) {
+    if isNil(node) {
+        return
+    }
+
+    v := reflect.ValueOf(node)
+    if v.Kind() == reflect.Ptr {
+        v = v.Elem()
+    }
+
+    out.WriteString(strings.Repeat("  ", depth) + label + v.Type().Name())
+    type child struct {
+        label string
+        node Node
+    }
+    children := []child{}
+    for i := 0; i < v.NumField(); i++ {
+        field, value := v.Type().Field(i), v.Field(i)
+        switch {
+        case field.Name == "Token":
+        case field.Type.Implements(nodeType):
+            if !value.IsNil() {
+                children = append(children, child{field.Name + ": ", value.Interface().(Node)})
+            }
+        case value.Kind() == reflect.Slice && field.Type.Elem().Implements(nodeType):
+            for j := 0; j < value.Len(); j++ {
+                if !value.Index(j).IsNil() {
+                    children = append(children, child{fmt.Sprintf("%s[%d]: ", field.Name, j), value.Index(j).Interface().(Node)})
+                }
+            }
+        case value.Kind() == reflect.String:
+            fmt.Fprintf(out, " %s=%q", field.Name, value.String())
+        default:
+            fmt.Fprintf(out, " %s=%v", field.Name, value.Interface())
+        }
+    }
+    if pos := node.Pos(); pos.IsValid() {
+        out.WriteString(" " + pos.String())
+    }
+    out.WriteString("\n")
+
+    for _, c := range children {
+        dump(out, c.label, c.node, depth+1)
+    }
+}
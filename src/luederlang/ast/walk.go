@@ -0,0 +1,187 @@
+package ast
+
+// Inspect walks the tree rooted at node in source order and calls f for every
+// node it reaches. When f returns false the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+    if isNil(node) || !f(node) {
+        return
+    }
+
+    switch n := node.(type) {
+    case *Program:
+        for _, s := range n.Statements {
+            Inspect(s, f)
+        }
+
+    case *LetStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *IntStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *FloatStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *BoolStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *StringStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *ListStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *MapStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *FunStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+    case *AssignStatement:
+        Inspect(n.Name, f)
+        Inspect(n.Value, f)
+
+    case *ReturnStatement:
+        Inspect(n.ReturnValue, f)
+    case *ExpressionStatement:
+        Inspect(n.Expression, f)
+    case *BlockStatement:
+        for _, s := range n.Statements {
+            Inspect(s, f)
+        }
+    case *IndexAssignStatement:
+        Inspect(n.Target, f)
+        Inspect(n.Value, f)
+    case *WhileStatement:
+        Inspect(n.Condition, f)
+        Inspect(n.Body, f)
+    case *ForStatement:
+        Inspect(n.Init, f)
+        Inspect(n.Condition, f)
+        Inspect(n.Post, f)
+        Inspect(n.Body, f)
+    case *ForInStatement:
+        Inspect(n.Variable, f)
+        Inspect(n.Iterable, f)
+        Inspect(n.Body, f)
+    case *TryStatement:
+        Inspect(n.Body, f)
+        Inspect(n.CatchVariable, f)
+        Inspect(n.Catch, f)
+        Inspect(n.Finally, f)
+    case *ThrowStatement:
+        Inspect(n.Value, f)
+    case *ImportStatement:
+        Inspect(n.Name, f)
+    case *ExportStatement:
+        Inspect(n.Statement, f)
+    case *BadStatement:
+        Inspect(n.Partial, f)
+
+    case *PrefixExpression:
+        Inspect(n.Right, f)
+    case *InfixExpression:
+        Inspect(n.Left, f)
+        Inspect(n.Right, f)
+    case *IfExpression:
+        Inspect(n.Condition, f)
+        Inspect(n.Consequence, f)
+        Inspect(n.ElseIf, f)
+        Inspect(n.Alternative, f)
+    case *FunctionLiteral:
+        for i, p := range n.Parameters {
+            if i < len(n.ParameterTypes) {
+                Inspect(n.ParameterTypes[i], f)
+            }
+            Inspect(p, f)
+        }
+        Inspect(n.ReturnType, f)
+        Inspect(n.Body, f)
+    case *CallExpression:
+        Inspect(n.Function, f)
+        for _, a := range n.Arguments {
+            Inspect(a, f)
+        }
+    case *InterpolatedString:
+        for _, part := range n.Parts {
+            Inspect(part, f)
+        }
+    case *ListLiteral:
+        for _, el := range n.Elements {
+            Inspect(el, f)
+        }
+    case *MapLiteral:
+        for i := range n.Keys {
+            Inspect(n.Keys[i], f)
+            Inspect(n.Values[i], f)
+        }
+    case *IndexExpression:
+        Inspect(n.Left, f)
+        Inspect(n.Index, f)
+    case *SliceExpression:
+        Inspect(n.Left, f)
+        Inspect(n.Start, f)
+        Inspect(n.End, f)
+    }
+}
+
+// Optional children are nil pointers wrapped in a Node, which != nil misses
+func isNil(node Node) bool {
+    if node == nil {
+        return true
+    }
+    switch n := node.(type) {
+    case *Identifier:
+        return n == nil
+    case *BlockStatement:
+        return n == nil
+    case *IfExpression:
+        return n == nil
+    case *TypeAnnotation:
+        return n == nil
+    case *IndexExpression:
+        return n == nil
+    case *FunctionLiteral:
+        return n == nil
+    }
+    return false
+}
+
+// MarkTailCalls flags the calls whose result is the result of fl, the value of
+// a return or the last expression of the body, also through the branches of an
+// if at the end. Calls inside of a try don't count since the try has to see
+// them finish. Nested function literals are marked when they are parsed.
+func MarkTailCalls(fl *FunctionLiteral) {
+    markTailBlock(fl.Body)
+    Inspect(fl.Body, func(node Node) bool {
+        switch n := node.(type) {
+        case *FunctionLiteral, *TryStatement:
+            return false
+        case *ReturnStatement:
+            markTailExpression(n.ReturnValue)
+        }
+        return true
+    })
+}
+
+func markTailBlock(block *BlockStatement) {
+    if block == nil || len(block.Statements) == 0 {
+        return
+    }
+    if es, ok := block.Statements[len(block.Statements)-1].(*ExpressionStatement); ok {
+        markTailExpression(es.Expression)
+    }
+}
+
+func markTailExpression(exp Expression) {
+    switch e := exp.(type) {
+    case *CallExpression:
+        e.Tail = true
+    case *IfExpression:
+        markTailBlock(e.Consequence)
+        if e.ElseIf != nil {
+            markTailExpression(e.ElseIf)
+        }
+        markTailBlock(e.Alternative)
+    }
+}
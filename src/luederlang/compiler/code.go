@@ -0,0 +1,262 @@
+package compiler
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+    OpConstant Opcode = iota
+    OpPop
+    OpTrue
+    OpFalse
+    OpNull
+
+    OpAdd
+    OpSub
+    OpMul
+    OpDiv
+    OpMod
+    OpEqual
+    OpNotEqual
+    OpLessThan
+    OpGreaterThan
+    OpAnd
+    OpOr
+    OpMinus
+    OpBang
+
+    OpJump
+    OpJumpNotTruthy
+
+    OpGetGlobal
+    OpSetGlobal
+    OpGetLocal
+    OpSetLocal
+    OpGetBuiltin
+    // like set, but the variable has to be bound already
+    OpAssignGlobal
+    OpAssignLocal
+
+    OpList
+    OpMap
+    OpInterpolate
+    OpIndex
+    OpSlice
+    OpSetIndex
+
+    // checks the value on top of the stack against a declared type
+    OpCoerce
+    OpDupPair
+
+    OpIter
+    OpIterNext
+
+    OpTry
+    OpEndTry
+    OpThrow
+
+    OpImport
+
+    OpClosure
+    OpCall
+    OpTailCall
+    OpReturnValue
+)
+
+type Definition struct {
+    Name string
+    OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+    OpConstant: {"OpConstant", []int{2}},
+    OpPop: {"OpPop", []int{}},
+    OpTrue: {"OpTrue", []int{}},
+    OpFalse: {"OpFalse", []int{}},
+    OpNull: {"OpNull", []int{}},
+
+    OpAdd: {"OpAdd", []int{}},
+    OpSub: {"OpSub", []int{}},
+    OpMul: {"OpMul", []int{}},
+    OpDiv: {"OpDiv", []int{}},
+    OpMod: {"OpMod", []int{}},
+    OpEqual: {"OpEqual", []int{}},
+    OpNotEqual: {"OpNotEqual", []int{}},
+    OpLessThan: {"OpLessThan", []int{}},
+    OpGreaterThan: {"OpGreaterThan", []int{}},
+    OpAnd: {"OpAnd", []int{}},
+    OpOr: {"OpOr", []int{}},
+    OpMinus: {"OpMinus", []int{}},
+    OpBang: {"OpBang", []int{}},
+
+    OpJump: {"OpJump", []int{2}},
+    OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+    OpGetGlobal: {"OpGetGlobal", []int{2}},
+    OpSetGlobal: {"OpSetGlobal", []int{2}},
+    // how many functions out the variable lives, then its slot
+    OpGetLocal: {"OpGetLocal", []int{1, 1}},
+    OpSetLocal: {"OpSetLocal", []int{1, 1}},
+    OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+    OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+    OpAssignLocal: {"OpAssignLocal", []int{1, 1}},
+
+    OpList: {"OpList", []int{2}},
+    OpMap: {"OpMap", []int{2}},
+    // joins what that many values Inspect to into one string
+    OpInterpolate: {"OpInterpolate", []int{2}},
+    OpIndex: {"OpIndex", []int{}},
+    // bit 0 set if there is a start bound, bit 1 for the end
+    OpSlice: {"OpSlice", []int{1}},
+    OpSetIndex: {"OpSetIndex", []int{}},
+
+    // constant index of the type name, then of the variable name
+    OpCoerce: {"OpCoerce", []int{2, 2}},
+    // copies the two values on top of the stack, for xs[i] += 1
+    OpDupPair: {"OpDupPair", []int{}},
+
+    OpIter: {"OpIter", []int{}},
+    // jumps to the operand once the iterator is exhausted
+    OpIterNext: {"OpIterNext", []int{2}},
+
+    // errors raised until the matching OpEndTry jump to the operand
+    OpTry: {"OpTry", []int{2}},
+    OpEndTry: {"OpEndTry", []int{}},
+    OpThrow: {"OpThrow", []int{}},
+
+    // constant index of the path, pushes the module
+    OpImport: {"OpImport", []int{2}},
+
+    OpClosure: {"OpClosure", []int{2}},
+    OpCall: {"OpCall", []int{1}},
+    // a call whose result the function returns, it replaces the caller's frame
+    OpTailCall: {"OpTailCall", []int{1}},
+    OpReturnValue: {"OpReturnValue", []int{}},
+}
+
+// The operator each arithmetic or comparison opcode stands for, the vm hands
+// these to the evaluator
+var Operators = map[Opcode]string{
+    OpAdd: "+",
+    OpSub: "-",
+    OpMul: "*",
+    OpDiv: "/",
+    OpMod: "%",
+    OpEqual: "==",
+    OpNotEqual: "!=",
+    OpLessThan: "<",
+    OpGreaterThan: ">",
+    OpAnd: "&&",
+    OpOr: "||",
+    OpMinus: "-",
+    OpBang: "!",
+}
+
+func Lookup(op byte) (*Definition, error) {
+    def, ok := definitions[Opcode(op)]
+    if !ok {
+        return nil, fmt.Errorf("opcode %d undefined", op)
+    }
+    return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+    def, ok := definitions[op]
+    if !ok {
+        return []byte{}
+    }
+
+    instructionLen := 1
+    for _, w := range def.OperandWidths {
+        instructionLen += w
+    }
+
+    instruction := make([]byte, instructionLen)
+    instruction[0] = byte(op)
+
+    offset := 1
+    for i, o := range operands {
+        width := def.OperandWidths[i]
+        switch width {
+        case 2:
+            binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+        case 1:
+            instruction[offset] = byte(o)
+        }
+        offset += width
+    }
+
+    return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+    operands := make([]int, len(def.OperandWidths))
+    offset := 0
+
+    for i, width := range def.OperandWidths {
+        switch width {
+        case 2:
+            operands[i] = int(ReadUint16(ins[offset:]))
+        case 1:
+            operands[i] = int(ReadUint8(ins[offset:]))
+        }
+        offset += width
+    }
+
+    return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+    return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+    return uint8(ins[0])
+}
+
+// Disassembles the instructions, one per line with its offset
+func (ins Instructions) String() string {
+    var out bytes.Buffer
+
+    i := 0
+    for i < len(ins) {
+        def, err := Lookup(ins[i])
+        if err != nil {
+            fmt.Fprintf(&out, "ERROR: %s\n", err)
+            i++
+            continue
+        }
+
+        operands, read := ReadOperands(def, ins[i+1:])
+        fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+        i += 1 + read
+    }
+
+    return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+    operandCount := len(def.OperandWidths)
+
+    if len(operands) != operandCount {
+        return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+    }
+
+    switch operandCount {
+    case 0:
+        return def.Name
+    case 1:
+        return fmt.Sprintf("%s %d", def.Name, operands[0])
+    case 2:
+        return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+    }
+
+    return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
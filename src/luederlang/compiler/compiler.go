@@ -0,0 +1,1002 @@
+package compiler
+
+import (
+    "fmt"
+    "luederlang/ast"
+    "luederlang/evaluator"
+    "luederlang/object"
+    "luederlang/token"
+)
+
+type CompileError struct {
+    Pos token.Position
+    Message string
+}
+
+func (e *CompileError) Error() string {
+    return e.Pos.String() + ": " + e.Message
+}
+
+type EmittedInstruction struct {
+    Opcode Opcode
+    Position int
+}
+
+// Jumps out of a loop that are patched once the loop has been compiled.
+// tries is how many try blocks the loop itself is inside of.
+type loop struct {
+    breaks []int
+    continues []int
+    tries int
+}
+
+// A try or catch block being compiled. Jumping out of it with return, break
+// or continue has to run its finally block on the way.
+type tryBlock struct {
+    finally *ast.BlockStatement // nil without one
+}
+
+// Everything that belongs to the function being compiled
+type CompilationScope struct {
+    instructions Instructions
+    positions []object.InstructionPosition
+    lastInstruction EmittedInstruction
+    loops []*loop
+    tries []*tryBlock
+}
+
+type Compiler struct {
+    constants []object.Object
+    symbolTable *SymbolTable
+
+    scopes []CompilationScope
+    scopeIndex int
+
+    // position of the node being compiled, recorded for every instruction
+    pos token.Position
+
+    // counts the variables the compiler makes up for itself
+    hidden int
+}
+
+type Bytecode struct {
+    Instructions Instructions
+    Positions []object.InstructionPosition
+    Constants []object.Object
+    GlobalNames []string
+}
+
+func New() *Compiler {
+    return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// For the repl, so globals and constants carry over between lines
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+    return &Compiler{
+        constants: constants,
+        symbolTable: s,
+        scopes: []CompilationScope{{}},
+    }
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+    return &Bytecode{
+        Instructions: c.currentScope().instructions,
+        Positions: c.currentScope().positions,
+        Constants: c.constants,
+        GlobalNames: c.globals().Names(),
+    }
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+    outerPos := c.pos
+    if pos := node.Pos(); pos.IsValid() {
+        c.pos = pos
+    }
+    defer func() { c.pos = outerPos }()
+
+    return c.compileNode(node)
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
+    switch node := node.(type) {
+    case *ast.Program:
+        c.hoist(node.Statements)
+        for _, s := range node.Statements {
+            if err := c.Compile(s); err != nil {
+                return err
+            }
+            // every statement leaves a result, the program evaluates to the last one
+            if _, ok := s.(*ast.ExpressionStatement); !ok {
+                c.emit(OpNull)
+                c.emit(OpPop)
+            }
+        }
+
+    case *ast.ExpressionStatement:
+        if err := c.Compile(node.Expression); err != nil {
+            return err
+        }
+        c.emit(OpPop)
+
+    case *ast.BlockStatement:
+        return c.compileStatements(node.Statements)
+
+    case *ast.LetStatement, *ast.IntStatement, *ast.FloatStatement, *ast.BoolStatement,
+        *ast.StringStatement, *ast.ListStatement, *ast.MapStatement, *ast.FunStatement:
+        name, value, typeName, _ := ast.DeclarationOf(node.(ast.Statement))
+        symbol := c.symbolTable.Define(name.Value)
+
+        var err error
+        if fl, ok := value.(*ast.FunctionLiteral); ok {
+            err = c.compileFunction(fl, name.Value)
+        } else {
+            err = c.Compile(value)
+        }
+        if err != nil {
+            return err
+        }
+
+        if typeName != "" {
+            if err := c.emitCoerce(typeName, name.Value); err != nil {
+                return err
+            }
+        }
+        return c.setSymbol(symbol)
+
+    case *ast.AssignStatement:
+        return c.compileAssignStatement(node)
+
+    case *ast.ReturnStatement:
+        if err := c.Compile(node.ReturnValue); err != nil {
+            return err
+        }
+        // finally blocks run before the function returns, the value waits in
+        // a variable of its own so they can't disturb it
+        if len(c.currentScope().tries) > 0 {
+            value := c.hiddenSymbol()
+            if err := c.setSymbol(value); err != nil {
+                return err
+            }
+            if err := c.leaveTries(0); err != nil {
+                return err
+            }
+            if err := c.getSymbol(value); err != nil {
+                return err
+            }
+        }
+        c.emit(OpReturnValue)
+
+    case *ast.IndexAssignStatement:
+        if err := c.Compile(node.Target.Left); err != nil {
+            return err
+        }
+        if err := c.Compile(node.Target.Index); err != nil {
+            return err
+        }
+        if node.Operator != "=" {
+            c.emit(OpDupPair)
+            c.emit(OpIndex)
+        }
+        if err := c.compileAssignedValue(node.Operator, node.Value); err != nil {
+            return err
+        }
+        c.emit(OpSetIndex)
+
+    case *ast.WhileStatement:
+        return c.compileWhileStatement(node)
+
+    case *ast.ForStatement:
+        return c.compileForStatement(node)
+
+    case *ast.ForInStatement:
+        return c.compileForInStatement(node)
+
+    case *ast.BreakStatement:
+        l := c.currentLoop()
+        if l == nil {
+            return c.errorf("break outside of loop")
+        }
+        if err := c.leaveTries(l.tries); err != nil {
+            return err
+        }
+        l.breaks = append(l.breaks, c.emit(OpJump, 9999))
+
+    case *ast.ContinueStatement:
+        l := c.currentLoop()
+        if l == nil {
+            return c.errorf("continue outside of loop")
+        }
+        if err := c.leaveTries(l.tries); err != nil {
+            return err
+        }
+        l.continues = append(l.continues, c.emit(OpJump, 9999))
+
+    case *ast.TryStatement:
+        return c.compileTryStatement(node)
+
+    case *ast.ImportStatement:
+        path, err := c.addConstant(&object.String{Value: node.Path})
+        if err != nil {
+            return err
+        }
+        c.emit(OpImport, path)
+        return c.setSymbol(c.symbolTable.Define(node.Name.Value))
+
+    case *ast.ExportStatement:
+        return c.Compile(node.Statement)
+
+    case *ast.ThrowStatement:
+        if err := c.Compile(node.Value); err != nil {
+            return err
+        }
+        c.emit(OpThrow)
+
+    case *ast.Identifier:
+        return c.compileIdentifier(node)
+
+    case *ast.IntegerLiteral:
+        return c.emitConstant(&object.Integer{Value: node.Value})
+
+    case *ast.BigIntegerLiteral:
+        return c.emitConstant(&object.BigInt{Value: node.Value})
+
+    case *ast.FloatLiteral:
+        return c.emitConstant(&object.Float{Value: node.Value})
+
+    case *ast.StringLiteral:
+        return c.emitConstant(&object.String{Value: node.Value})
+
+    case *ast.Boolean:
+        if node.Value {
+            c.emit(OpTrue)
+        } else {
+            c.emit(OpFalse)
+        }
+
+    case *ast.PrefixExpression:
+        if err := c.Compile(node.Right); err != nil {
+            return err
+        }
+        switch node.Operator {
+        case "!":
+            c.emit(OpBang)
+        case "-":
+            c.emit(OpMinus)
+        default:
+            return c.errorf("unknown operator: %s", node.Operator)
+        }
+
+    case *ast.InfixExpression:
+        if err := c.Compile(node.Left); err != nil {
+            return err
+        }
+        if err := c.Compile(node.Right); err != nil {
+            return err
+        }
+        op, ok := infixOpcodes[node.Operator]
+        if !ok {
+            return c.errorf("unknown operator: %s", node.Operator)
+        }
+        c.emit(op)
+
+    case *ast.IfExpression:
+        return c.compileIfExpression(node)
+
+    case *ast.FunctionLiteral:
+        return c.compileFunction(node, "")
+
+    case *ast.CallExpression:
+        if err := c.Compile(node.Function); err != nil {
+            return err
+        }
+        for _, a := range node.Arguments {
+            if err := c.Compile(a); err != nil {
+                return err
+            }
+        }
+        if len(node.Arguments) > 255 {
+            return c.errorf("too many arguments: %d", len(node.Arguments))
+        }
+        if node.Tail {
+            // only closures replace the frame, a builtin's result still
+            // needs the return
+            c.emit(OpTailCall, len(node.Arguments))
+            c.emit(OpReturnValue)
+        } else {
+            c.emit(OpCall, len(node.Arguments))
+        }
+
+    case *ast.ListLiteral:
+        for _, el := range node.Elements {
+            if err := c.Compile(el); err != nil {
+                return err
+            }
+        }
+        if len(node.Elements) > maxOperand {
+            return c.errorf("too many list elements: %d", len(node.Elements))
+        }
+        c.emit(OpList, len(node.Elements))
+
+    case *ast.InterpolatedString:
+        for _, part := range node.Parts {
+            if err := c.Compile(part); err != nil {
+                return err
+            }
+        }
+        if len(node.Parts) > maxOperand {
+            return c.errorf("too many parts in a string: %d", len(node.Parts))
+        }
+        c.emit(OpInterpolate, len(node.Parts))
+
+    case *ast.MapLiteral:
+        for i, k := range node.Keys {
+            if err := c.Compile(k); err != nil {
+                return err
+            }
+            if err := c.Compile(node.Values[i]); err != nil {
+                return err
+            }
+        }
+        if len(node.Keys) > maxOperand {
+            return c.errorf("too many map entries: %d", len(node.Keys))
+        }
+        c.emit(OpMap, len(node.Keys))
+
+    case *ast.IndexExpression:
+        if err := c.Compile(node.Left); err != nil {
+            return err
+        }
+        if err := c.Compile(node.Index); err != nil {
+            return err
+        }
+        c.emit(OpIndex)
+
+    case *ast.SliceExpression:
+        if err := c.Compile(node.Left); err != nil {
+            return err
+        }
+        bounds := 0
+        if node.Start != nil {
+            if err := c.Compile(node.Start); err != nil {
+                return err
+            }
+            bounds |= 1
+        }
+        if node.End != nil {
+            if err := c.Compile(node.End); err != nil {
+                return err
+            }
+            bounds |= 2
+        }
+        c.emit(OpSlice, bounds)
+
+    default:
+        return c.errorf("cannot compile %T", node)
+    }
+
+    return nil
+}
+
+var infixOpcodes = map[string]Opcode{
+    "+": OpAdd,
+    "-": OpSub,
+    "*": OpMul,
+    "/": OpDiv,
+    "%": OpMod,
+    "==": OpEqual,
+    "!=": OpNotEqual,
+    "<": OpLessThan,
+    ">": OpGreaterThan,
+    "&&": OpAnd,
+    "||": OpOr,
+}
+
+func (c *Compiler) compileStatements(statements []ast.Statement) error {
+    for _, s := range statements {
+        if err := c.Compile(s); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Compiles a block that is used as a value, like the branches of an if, so
+// that it leaves the result of its last statement on the stack
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+    if err := c.compileStatements(block.Statements); err != nil {
+        return err
+    }
+
+    n := len(block.Statements)
+    if n > 0 {
+        if _, ok := block.Statements[n-1].(*ast.ExpressionStatement); ok && c.lastInstructionIs(OpPop) {
+            c.removeLastPop()
+            return nil
+        }
+    }
+    c.emit(OpNull)
+    return nil
+}
+
+func (c *Compiler) compileIdentifier(node *ast.Identifier) error {
+    symbol, ok := c.symbolTable.Resolve(node.Value)
+    if !ok {
+        if index, isBuiltin := builtinIndex(node.Value); isBuiltin {
+            c.emit(OpGetBuiltin, index)
+            return nil
+        }
+        // a global that is defined later on, the vm errors if it never is
+        symbol = c.globals().Define(node.Value)
+    }
+
+    return c.getSymbol(symbol)
+}
+
+// Assignments change the variable where it was declared and keep its type.
+// A name that is not declared anywhere yet becomes a global, the vm reports it
+// if it is still unbound when the assignment runs.
+func (c *Compiler) compileAssignStatement(as *ast.AssignStatement) error {
+    symbol, ok := c.symbolTable.Resolve(as.Name.Value)
+    if !ok {
+        symbol = c.globals().Define(as.Name.Value)
+    }
+
+    if as.Operator != "=" {
+        if err := c.getSymbol(symbol); err != nil {
+            return err
+        }
+    }
+    if err := c.compileAssignedValue(as.Operator, as.Value); err != nil {
+        return err
+    }
+    if symbol.TypeName != "" {
+        if err := c.emitCoerce(symbol.TypeName, symbol.Name); err != nil {
+            return err
+        }
+    }
+
+    switch symbol.Scope {
+    case GlobalScope:
+        if symbol.Index > maxOperand {
+            return c.errorf("too many global variables")
+        }
+        c.emit(OpAssignGlobal, symbol.Index)
+    case LocalScope:
+        c.emit(OpAssignLocal, symbol.Depth, symbol.Index)
+    }
+    return nil
+}
+
+// The current value is already on the stack for compound operators, ++ and
+// -- have no value and count by one
+func (c *Compiler) compileAssignedValue(operator string, value ast.Expression) error {
+    if value == nil {
+        if err := c.emitConstant(&object.Integer{Value: 1}); err != nil {
+            return err
+        }
+    } else if err := c.Compile(value); err != nil {
+        return err
+    }
+
+    if operator != "=" {
+        c.emit(infixOpcodes[ast.CompoundOperator(operator)])
+    }
+    return nil
+}
+
+func (c *Compiler) getSymbol(symbol Symbol) error {
+    switch symbol.Scope {
+    case GlobalScope:
+        if symbol.Index > maxOperand {
+            return c.errorf("too many global variables")
+        }
+        c.emit(OpGetGlobal, symbol.Index)
+    case LocalScope:
+        c.emit(OpGetLocal, symbol.Depth, symbol.Index)
+    }
+    return nil
+}
+
+func (c *Compiler) setSymbol(symbol Symbol) error {
+    switch symbol.Scope {
+    case GlobalScope:
+        if symbol.Index > maxOperand {
+            return c.errorf("too many global variables")
+        }
+        c.emit(OpSetGlobal, symbol.Index)
+    case LocalScope:
+        if symbol.Index > 255 {
+            return c.errorf("too many local variables")
+        }
+        c.emit(OpSetLocal, symbol.Depth, symbol.Index)
+    }
+    return nil
+}
+
+func (c *Compiler) compileIfExpression(ie *ast.IfExpression) error {
+    if err := c.Compile(ie.Condition); err != nil {
+        return err
+    }
+
+    jumpNotTruthyPos := c.emit(OpJumpNotTruthy, 9999)
+
+    if err := c.compileBlockValue(ie.Consequence); err != nil {
+        return err
+    }
+
+    jumpPos := c.emit(OpJump, 9999)
+    if err := c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions())); err != nil {
+        return err
+    }
+
+    switch {
+    case ie.ElseIf != nil:
+        if err := c.Compile(ie.ElseIf); err != nil {
+            return err
+        }
+    case ie.Alternative != nil:
+        if err := c.compileBlockValue(ie.Alternative); err != nil {
+            return err
+        }
+    default:
+        c.emit(OpNull)
+    }
+
+    return c.changeOperand(jumpPos, len(c.currentInstructions()))
+}
+
+func (c *Compiler) compileWhileStatement(ws *ast.WhileStatement) error {
+    start := len(c.currentInstructions())
+
+    if err := c.Compile(ws.Condition); err != nil {
+        return err
+    }
+    jumpNotTruthyPos := c.emit(OpJumpNotTruthy, 9999)
+
+    l, err := c.compileLoopBody(ws.Body)
+    if err != nil {
+        return err
+    }
+    if err := c.emitJump(start); err != nil {
+        return err
+    }
+
+    exit := len(c.currentInstructions())
+    if err := c.changeOperand(jumpNotTruthyPos, exit); err != nil {
+        return err
+    }
+    return c.patchLoop(l, exit, start)
+}
+
+func (c *Compiler) compileForStatement(fs *ast.ForStatement) error {
+    if fs.Init != nil {
+        if err := c.Compile(fs.Init); err != nil {
+            return err
+        }
+    }
+
+    start := len(c.currentInstructions())
+    jumpNotTruthyPos := -1
+    if fs.Condition != nil {
+        if err := c.Compile(fs.Condition); err != nil {
+            return err
+        }
+        jumpNotTruthyPos = c.emit(OpJumpNotTruthy, 9999)
+    }
+
+    l, err := c.compileLoopBody(fs.Body)
+    if err != nil {
+        return err
+    }
+
+    post := len(c.currentInstructions())
+    if fs.Post != nil {
+        if err := c.Compile(fs.Post); err != nil {
+            return err
+        }
+    }
+    if err := c.emitJump(start); err != nil {
+        return err
+    }
+
+    exit := len(c.currentInstructions())
+    if jumpNotTruthyPos != -1 {
+        if err := c.changeOperand(jumpNotTruthyPos, exit); err != nil {
+            return err
+        }
+    }
+    return c.patchLoop(l, exit, post)
+}
+
+// The iterator stays on the stack while the loop runs, so break jumps to the
+// OpPop that removes it
+func (c *Compiler) compileForInStatement(fis *ast.ForInStatement) error {
+    if err := c.Compile(fis.Iterable); err != nil {
+        return err
+    }
+    c.emit(OpIter)
+
+    start := c.emit(OpIterNext, 9999)
+    if err := c.setSymbol(c.symbolTable.Define(fis.Variable.Value)); err != nil {
+        return err
+    }
+
+    l, err := c.compileLoopBody(fis.Body)
+    if err != nil {
+        return err
+    }
+    if err := c.emitJump(start); err != nil {
+        return err
+    }
+
+    exit := c.emit(OpPop)
+    if err := c.changeOperand(start, exit); err != nil {
+        return err
+    }
+    return c.patchLoop(l, exit, start)
+}
+
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement) (*loop, error) {
+    scope := &c.scopes[c.scopeIndex]
+    l := &loop{tries: len(scope.tries)}
+    scope.loops = append(scope.loops, l)
+
+    err := c.Compile(body)
+
+    scope = &c.scopes[c.scopeIndex]
+    scope.loops = scope.loops[:len(scope.loops)-1]
+    return l, err
+}
+
+func (c *Compiler) patchLoop(l *loop, breakTarget int, continueTarget int) error {
+    for _, pos := range l.breaks {
+        if err := c.changeOperand(pos, breakTarget); err != nil {
+            return err
+        }
+    }
+    for _, pos := range l.continues {
+        if err := c.changeOperand(pos, continueTarget); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (c *Compiler) currentLoop() *loop {
+    loops := c.currentScope().loops
+    if len(loops) == 0 {
+        return nil
+    }
+    return loops[len(loops)-1]
+}
+
+// The finally block is compiled once for every way out of the statement:
+// after the try or catch block ends normally, on the way out of a return,
+// break or continue, and before an error nothing caught is thrown again.
+//
+//	OpTry catch
+//	<body> OpEndTry <finally> OpJump end
+//	catch: (the vm pushed the error) OpSet e
+//	OpTry rethrow <catch> OpEndTry <finally> OpJump end
+//	rethrow: OpSet pending <finally> OpGet pending OpThrow
+//	end:
+func (c *Compiler) compileTryStatement(ts *ast.TryStatement) error {
+    ends := []int{}
+
+    handler := c.emit(OpTry, 9999)
+    if err := c.compileTryBlock(ts.Body, ts.Finally); err != nil {
+        return err
+    }
+    c.emit(OpEndTry)
+    if err := c.compileFinally(ts.Finally); err != nil {
+        return err
+    }
+    ends = append(ends, c.emit(OpJump, 9999))
+    if err := c.changeOperand(handler, len(c.currentInstructions())); err != nil {
+        return err
+    }
+
+    if ts.Catch != nil {
+        if err := c.setSymbol(c.symbolTable.Define(ts.CatchVariable.Value)); err != nil {
+            return err
+        }
+
+        if ts.Finally == nil {
+            if err := c.compileStatements(ts.Catch.Statements); err != nil {
+                return err
+            }
+        } else {
+            handler = c.emit(OpTry, 9999)
+            if err := c.compileTryBlock(ts.Catch, ts.Finally); err != nil {
+                return err
+            }
+            c.emit(OpEndTry)
+            if err := c.compileFinally(ts.Finally); err != nil {
+                return err
+            }
+            ends = append(ends, c.emit(OpJump, 9999))
+            if err := c.changeOperand(handler, len(c.currentInstructions())); err != nil {
+                return err
+            }
+        }
+    }
+
+    if ts.Finally != nil {
+        pending := c.hiddenSymbol()
+        if err := c.setSymbol(pending); err != nil {
+            return err
+        }
+        if err := c.compileFinally(ts.Finally); err != nil {
+            return err
+        }
+        if err := c.getSymbol(pending); err != nil {
+            return err
+        }
+        c.emit(OpThrow)
+    }
+
+    for _, pos := range ends {
+        if err := c.changeOperand(pos, len(c.currentInstructions())); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (c *Compiler) compileTryBlock(block *ast.BlockStatement, finally *ast.BlockStatement) error {
+    scope := &c.scopes[c.scopeIndex]
+    scope.tries = append(scope.tries, &tryBlock{finally: finally})
+
+    err := c.compileStatements(block.Statements)
+
+    scope = &c.scopes[c.scopeIndex]
+    scope.tries = scope.tries[:len(scope.tries)-1]
+    return err
+}
+
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+    if finally == nil {
+        return nil
+    }
+    return c.compileStatements(finally.Statements)
+}
+
+// Leaves the try blocks of the current function down to the first outer ones,
+// dropping their handlers and running their finally blocks innermost first
+func (c *Compiler) leaveTries(outer int) error {
+    tries := c.currentScope().tries
+    defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+    for i := len(tries) - 1; i >= outer; i-- {
+        c.emit(OpEndTry)
+        // the finally block is no longer protected by its own try
+        c.scopes[c.scopeIndex].tries = append([]*tryBlock{}, tries[:i]...)
+        if err := c.compileFinally(tries[i].finally); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// A variable user code can't name, "@" never starts an identifier
+func (c *Compiler) hiddenSymbol() Symbol {
+    c.hidden++
+    return c.symbolTable.Define(fmt.Sprintf("@%d", c.hidden))
+}
+
+func (c *Compiler) compileFunction(fl *ast.FunctionLiteral, name string) error {
+    c.enterScope()
+
+    parameterTypes := make([]string, len(fl.Parameters))
+    for i, p := range fl.Parameters {
+        c.symbolTable.Define(p.Value)
+        if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+            parameterTypes[i] = fl.ParameterTypes[i].Value
+            c.symbolTable.SetType(p.Value, parameterTypes[i])
+        }
+    }
+    c.hoist(fl.Body.Statements)
+
+    if err := c.compileBlockValue(fl.Body); err != nil {
+        return err
+    }
+    c.emit(OpReturnValue)
+
+    if c.symbolTable.NumDefinitions() > 256 {
+        return c.errorf("too many local variables")
+    }
+
+    localNames := c.symbolTable.Names()
+    scope := c.leaveScope()
+
+    fn := &object.CompiledFunction{
+        Instructions: scope.instructions,
+        Positions: scope.positions,
+        Name: name,
+        LocalNames: localNames,
+        NumParameters: len(fl.Parameters),
+        ParameterTypes: parameterTypes,
+    }
+    if fl.ReturnType != nil {
+        fn.ReturnType = fl.ReturnType.Value
+    }
+
+    index, err := c.addConstant(fn)
+    if err != nil {
+        return err
+    }
+    c.emit(OpClosure, index)
+    return nil
+}
+
+// Every variable a function declares gets its slot up front, since blocks do
+// not open a scope of their own. Nested functions are left to themselves.
+func (c *Compiler) hoist(statements []ast.Statement) {
+    for _, s := range statements {
+        ast.Inspect(s, func(node ast.Node) bool {
+            switch node := node.(type) {
+            case *ast.FunctionLiteral:
+                return false
+            case *ast.ForInStatement:
+                c.symbolTable.Define(node.Variable.Value)
+            case *ast.TryStatement:
+                if node.CatchVariable != nil {
+                    c.symbolTable.Define(node.CatchVariable.Value)
+                }
+            case *ast.ImportStatement:
+                c.symbolTable.Define(node.Name.Value)
+            case ast.Statement:
+                if name, _, typeName, ok := ast.DeclarationOf(node); ok {
+                    c.symbolTable.Define(name.Value)
+                    if typeName != "" {
+                        c.symbolTable.SetType(name.Value, typeName)
+                    }
+                }
+            }
+            return true
+        })
+    }
+}
+
+func builtinIndex(name string) (int, bool) {
+    for i, n := range evaluator.BuiltinNames() {
+        if n == name {
+            return i, true
+        }
+    }
+    return 0, false
+}
+
+func (c *Compiler) globals() *SymbolTable {
+    s := c.symbolTable
+    for s.Outer != nil {
+        s = s.Outer
+    }
+    return s
+}
+
+func (c *Compiler) errorf(format string, a ...interface{}) error {
+    return &CompileError{Pos: c.pos, Message: fmt.Sprintf(format, a...)}
+}
+
+// Operands that point at a constant, a global or a jump target are 2 bytes
+const maxOperand = 65535
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+    if len(c.constants) > maxOperand {
+        return 0, c.errorf("too many constants")
+    }
+    c.constants = append(c.constants, obj)
+    return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+    index, err := c.addConstant(obj)
+    if err != nil {
+        return err
+    }
+    c.emit(OpConstant, index)
+    return nil
+}
+
+func (c *Compiler) emitCoerce(typeName string, name string) error {
+    typeIndex, err := c.addConstant(&object.String{Value: typeName})
+    if err != nil {
+        return err
+    }
+    nameIndex, err := c.addConstant(&object.String{Value: name})
+    if err != nil {
+        return err
+    }
+    c.emit(OpCoerce, typeIndex, nameIndex)
+    return nil
+}
+
+// A jump back to target, which is already known
+func (c *Compiler) emitJump(target int) error {
+    if target > maxOperand {
+        return c.errorf("too much code to jump over")
+    }
+    c.emit(OpJump, target)
+    return nil
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+    ins := Make(op, operands...)
+    pos := c.addInstruction(ins)
+
+    scope := &c.scopes[c.scopeIndex]
+    scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+
+    positions := scope.positions
+    if len(positions) == 0 || positions[len(positions)-1].Pos != c.pos {
+        scope.positions = append(positions, object.InstructionPosition{Offset: pos, Pos: c.pos})
+    }
+
+    return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+    posNewInstruction := len(c.currentInstructions())
+    c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+    return posNewInstruction
+}
+
+func (c *Compiler) currentScope() CompilationScope {
+    return c.scopes[c.scopeIndex]
+}
+
+func (c *Compiler) currentInstructions() Instructions {
+    return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op Opcode) bool {
+    if len(c.currentInstructions()) == 0 {
+        return false
+    }
+    return c.currentScope().lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+    scope := &c.scopes[c.scopeIndex]
+    last := scope.lastInstruction.Position
+
+    scope.instructions = scope.instructions[:last]
+    for len(scope.positions) > 0 && scope.positions[len(scope.positions)-1].Offset >= last {
+        scope.positions = scope.positions[:len(scope.positions)-1]
+    }
+    // nothing looks further back than one instruction, so the opcode is enough
+    scope.lastInstruction = EmittedInstruction{}
+}
+
+// Only used to patch in jump targets
+func (c *Compiler) changeOperand(opPos int, operand int) error {
+    if operand > maxOperand {
+        return c.errorf("too much code to jump over")
+    }
+    op := Opcode(c.currentInstructions()[opPos])
+    newInstruction := Make(op, operand)
+
+    ins := c.currentInstructions()
+    for i := 0; i < len(newInstruction); i++ {
+        ins[opPos+i] = newInstruction[i]
+    }
+    return nil
+}
+
+func (c *Compiler) enterScope() {
+    c.scopes = append(c.scopes, CompilationScope{})
+    c.scopeIndex++
+    c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+    scope := c.currentScope()
+
+    c.scopes = c.scopes[:len(c.scopes)-1]
+    c.scopeIndex--
+    c.symbolTable = c.symbolTable.Outer
+
+    return scope
+}
@@ -0,0 +1,247 @@
+package compiler
+
+import (
+	"fmt"
+	"luederlang/ast"
+	"luederlang/lexer"
+	"luederlang/object"
+	"luederlang/parser"
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{1, 255}, []byte{byte(OpGetLocal), 1, 255}},
+		{OpCoerce, []int{1, 2}, []byte{byte(OpCoerce), 0, 1, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+		}
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{2, 7}, 2},
+		{OpCoerce, []int{300, 4}, 4},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong symbol for a. got=%+v", a)
+	}
+	if again := global.Define("a"); again != a {
+		t.Errorf("defining a twice gave a new symbol. got=%+v", again)
+	}
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: LocalScope, Index: 0, Depth: 1},
+		{Name: "c", Scope: LocalScope, Index: 0, Depth: 0},
+	}
+	for _, sym := range expected {
+		result, ok := inner.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if _, ok := inner.Resolve("d"); ok {
+		t.Errorf("d should not resolve")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + 2",
+			"0000 OpConstant 0\n0003 OpConstant 1\n0006 OpAdd\n0007 OpPop\n",
+		},
+		{
+			"let x = 1; x",
+			"0000 OpConstant 0\n0003 OpSetGlobal 0\n0006 OpNull\n0007 OpPop\n0008 OpGetGlobal 0\n0011 OpPop\n",
+		},
+		{
+			"float y = 1;",
+			"0000 OpConstant 0\n0003 OpCoerce 1 2\n0008 OpSetGlobal 0\n0011 OpNull\n0012 OpPop\n",
+		},
+		{
+			"if (true) { 10 }",
+			"0000 OpTrue\n0001 OpJumpNotTruthy 10\n0004 OpConstant 0\n0007 OpJump 11\n0010 OpNull\n0011 OpPop\n",
+		},
+		{
+			"while (true) { break; }",
+			"0000 OpTrue\n0001 OpJumpNotTruthy 10\n0004 OpJump 10\n0007 OpJump 0\n0010 OpNull\n0011 OpPop\n",
+		},
+		{
+			"for (x in [1]) { x }",
+			"0000 OpConstant 0\n0003 OpList 1\n0006 OpIter\n0007 OpIterNext 20\n0010 OpSetGlobal 0\n" +
+				"0013 OpGetGlobal 0\n0016 OpPop\n0017 OpJump 7\n0020 OpPop\n0021 OpNull\n0022 OpPop\n",
+		},
+		{
+			"try { 1 } catch (e) { 2 }",
+			"0000 OpTry 11\n0003 OpConstant 0\n0006 OpPop\n0007 OpEndTry\n0008 OpJump 18\n" +
+				"0011 OpSetGlobal 0\n0014 OpConstant 1\n0017 OpPop\n0018 OpNull\n0019 OpPop\n",
+		},
+		{
+			`"a${1}b"`,
+			"0000 OpConstant 0\n0003 OpConstant 1\n0006 OpConstant 2\n0009 OpInterpolate 3\n0012 OpPop\n",
+		},
+		{
+			"len([])",
+			"0000 OpGetBuiltin 9\n0002 OpList 0\n0005 OpCall 1\n0007 OpPop\n",
+		},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(t, tt.input)); err != nil {
+			t.Fatalf("%s | compiler error: %s", tt.input, err)
+		}
+
+		got := c.Bytecode().Instructions.String()
+		if got != tt.expected {
+			t.Errorf("%s | wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	input := "let f = fun(a) { let b = a; fun() { a + b } };"
+
+	c := New()
+	if err := c.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	constants := c.Bytecode().Constants
+	inner, ok := constants[0].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 0 is not CompiledFunction. got=%T", constants[0])
+	}
+	expectedInner := "0000 OpGetLocal 1 0\n0003 OpGetLocal 1 1\n0006 OpAdd\n0007 OpReturnValue\n"
+	if got := Instructions(inner.Instructions).String(); got != expectedInner {
+		t.Errorf("wrong inner instructions.\nwant=\n%s\ngot=\n%s", expectedInner, got)
+	}
+
+	outer, ok := constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 1 is not CompiledFunction. got=%T", constants[1])
+	}
+	if outer.Name != "f" || outer.NumParameters != 1 || len(outer.LocalNames) != 2 {
+		t.Errorf("wrong function f. got name=%q params=%d locals=%v", outer.Name, outer.NumParameters, outer.LocalNames)
+	}
+}
+
+func TestCompileTailCall(t *testing.T) {
+	input := "let f = fun(g) { g(1) };"
+
+	c := New()
+	if err := c.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	fn, ok := c.Bytecode().Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 1 is not CompiledFunction. got=%T", c.Bytecode().Constants[1])
+	}
+	expected := "0000 OpGetLocal 0 0\n0003 OpConstant 0\n0006 OpTailCall 1\n0008 OpReturnValue\n0009 OpReturnValue\n"
+	if got := Instructions(fn.Instructions).String(); got != expected {
+		t.Errorf("wrong instructions.\nwant=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	// names can't have digits, so g, then a to p for every 4 bits of i
+	globals := strings.Builder{}
+	for i := 0; i <= 65536; i++ {
+		name := "g"
+		for n := i; n > 0; n /= 16 {
+			name += string(rune('a' + n%16))
+		}
+		fmt.Fprintf(&globals, "let %s = true; ", name)
+	}
+
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{"constants", "let s = 0;" + strings.Repeat(" s = s + 1;", 70000), "too many constants"},
+		{"forward jump", "let s = 0; let i = 1; if (true) {" + strings.Repeat(" s = s + i;", 70000) + " }", "1:23: too much code to jump over"},
+		{"backward jump", "let s = 0; let i = 1;" + strings.Repeat(" s = s + i;", 7000) + " while (false) { }", "too much code to jump over"},
+		{"globals", globals.String(), "too many global variables"},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		err := c.Compile(parse(t, tt.input))
+		if err == nil || !strings.HasSuffix(err.Error(), tt.expectedError) {
+			t.Errorf("%s | expected error %q. got=%v", tt.name, tt.expectedError, err)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%s | parser errors: %v", input, p.Errors())
+	}
+	return program
+}
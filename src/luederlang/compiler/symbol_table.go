@@ -0,0 +1,85 @@
+package compiler
+
+type SymbolScope string
+
+const (
+    GlobalScope SymbolScope = "GLOBAL"
+    LocalScope SymbolScope = "LOCAL"
+    BuiltinScope SymbolScope = "BUILTIN"
+)
+
+// Depth is how many functions out from where it was resolved a local lives.
+// TypeName is the declared type assignments have to keep, "" for let.
+type Symbol struct {
+    Name string
+    Scope SymbolScope
+    Index int
+    Depth int
+    TypeName string
+}
+
+// One table per function, the outermost one holds the globals
+type SymbolTable struct {
+    Outer *SymbolTable
+
+    store map[string]Symbol
+    names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+    return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+    s := NewSymbolTable()
+    s.Outer = outer
+    return s
+}
+
+// Defining a name twice hands back the first symbol, like let does with an
+// existing variable
+func (s *SymbolTable) Define(name string) Symbol {
+    if symbol, ok := s.store[name]; ok {
+        return symbol
+    }
+
+    symbol := Symbol{Name: name, Index: len(s.names), Scope: LocalScope}
+    if s.Outer == nil {
+        symbol.Scope = GlobalScope
+    }
+
+    s.store[name] = symbol
+    s.names = append(s.names, name)
+    return symbol
+}
+
+func (s *SymbolTable) SetType(name string, typeName string) {
+    if symbol, ok := s.store[name]; ok {
+        symbol.TypeName = typeName
+        s.store[name] = symbol
+    }
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+    if symbol, ok := s.store[name]; ok {
+        return symbol, true
+    }
+    if s.Outer == nil {
+        return Symbol{}, false
+    }
+
+    symbol, ok := s.Outer.Resolve(name)
+    if ok && symbol.Scope == LocalScope {
+        symbol.Depth++
+    }
+    return symbol, ok
+}
+
+// Names of the defined symbols, by index
+func (s *SymbolTable) Names() []string {
+    return s.names
+}
+
+func (s *SymbolTable) NumDefinitions() int {
+    return len(s.names)
+}
@@ -3,26 +3,192 @@ package evaluator
 import (
     "luederlang/object"
     "fmt"
+    "unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
     "len": &object.Builtin{
         Function: func(args ...object.Object) object.Object {
             if len(args) != 1 {
-                return newError("wrong number of arguments. want=1. got=%v", len(args))
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1. got=%v", len(args))
             }
             switch arg := args[0].(type) {
             case *object.String:
-                return &object.Integer{Value: int64(len(arg.Value))}
+                // code points, bytes(s) has the byte count
+                return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+            case *object.List:
+                return &object.Integer{Value: int64(len(arg.Elements))}
+            case *object.Map:
+                return &object.Integer{Value: int64(arg.Len())}
             default:
-                return newError("len operation only supported on strings")
+                return newError(object.TYPE_ERROR, "len operation only supported on strings, lists and maps")
             }
         },
     },
+    "keys": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1. got=%v", len(args))
+            }
+            m, ok := args[0].(*object.Map)
+            if !ok {
+                return newError(object.TYPE_ERROR, "keys operation only supported on maps")
+            }
+            elements := []object.Object{}
+            for _, pair := range m.Ordered() {
+                elements = append(elements, pair.Key)
+            }
+            return &object.List{Elements: elements}
+        },
+    },
+    "values": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1. got=%v", len(args))
+            }
+            m, ok := args[0].(*object.Map)
+            if !ok {
+                return newError(object.TYPE_ERROR, "values operation only supported on maps")
+            }
+            elements := []object.Object{}
+            for _, pair := range m.Ordered() {
+                elements = append(elements, pair.Value)
+            }
+            return &object.List{Elements: elements}
+        },
+    },
+    // bytes("é") is [195, 169], the UTF-8 that len and indexing look past
+    "bytes": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1. got=%v", len(args))
+            }
+            str, ok := args[0].(*object.String)
+            if !ok {
+                return newError(object.TYPE_ERROR, "bytes operation only supported on strings")
+            }
+            elements := make([]object.Object, len(str.Value))
+            for i := 0; i < len(str.Value); i++ {
+                elements[i] = &object.Integer{Value: int64(str.Value[i])}
+            }
+            return &object.List{Elements: elements}
+        },
+    },
+    // runes("é") is [233], the code points as numbers
+    "runes": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1. got=%v", len(args))
+            }
+            str, ok := args[0].(*object.String)
+            if !ok {
+                return newError(object.TYPE_ERROR, "runes operation only supported on strings")
+            }
+            // a byte that isn't UTF-8 comes out as 65533, the replacement char
+            elements := []object.Object{}
+            for _, r := range str.Value {
+                elements = append(elements, &object.Integer{Value: int64(r)})
+            }
+            return &object.List{Elements: elements}
+        },
+    },
+    "has": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 2 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=2. got=%v", len(args))
+            }
+            m, ok := args[0].(*object.Map)
+            if !ok {
+                return newError(object.TYPE_ERROR, "has operation only supported on maps")
+            }
+            key, ok := args[1].(object.Hashable)
+            if !ok {
+                return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(args[1]))
+            }
+            _, ok = m.Get(key)
+            return nativeBoolToBooleanObject(ok)
+        },
+    },
+    "delete": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 2 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=2. got=%v", len(args))
+            }
+            m, ok := args[0].(*object.Map)
+            if !ok {
+                return newError(object.TYPE_ERROR, "delete operation only supported on maps")
+            }
+            key, ok := args[1].(object.Hashable)
+            if !ok {
+                return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(args[1]))
+            }
+            // returns the removed value, or null if the key was not there
+            pair, ok := m.Delete(key)
+            if !ok {
+                return NULL
+            }
+            return pair.Value
+        },
+    },
+    // error("message") or error("Kind", "message"), ready to be thrown
+    "error": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 && len(args) != 2 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1 or 2. got=%v", len(args))
+            }
+            strs := []string{}
+            for _, arg := range args {
+                str, ok := arg.(*object.String)
+                if !ok {
+                    return newError(object.TYPE_ERROR, "error operation only supported on strings")
+                }
+                strs = append(strs, str.Value)
+            }
+            if len(strs) == 1 {
+                return &object.ErrorValue{Err: newError(object.THROWN_ERROR, "%s", strs[0])}
+            }
+            return &object.ErrorValue{Err: newError(strs[0], "%s", strs[1])}
+        },
+    },
+    // assert(cond) or assert(cond, "message")
+    "assert": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 1 && len(args) != 2 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1 or 2. got=%v", len(args))
+            }
+            if !isTruthy(args[0]) {
+                return assertionError(args[1:], "assertion failed")
+            }
+            return NULL
+        },
+    },
+    // assert_eq(got, want) or assert_eq(got, want, "message")
+    "assert_eq": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 2 && len(args) != 3 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=2 or 3. got=%v", len(args))
+            }
+            if !valuesEqual(args[0], args[1]) {
+                return assertionError(args[2:], "expected %s, got %s", describe(args[1]), describe(args[0]))
+            }
+            return NULL
+        },
+    },
+    "assert_ne": &object.Builtin{
+        Function: func(args ...object.Object) object.Object {
+            if len(args) != 2 && len(args) != 3 {
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=2 or 3. got=%v", len(args))
+            }
+            if valuesEqual(args[0], args[1]) {
+                return assertionError(args[2:], "expected something other than %s", describe(args[1]))
+            }
+            return NULL
+        },
+    },
     "help": &object.Builtin{
         Function: func(args ...object.Object) object.Object {
             if len(args) != 0 {
-                return newError("wrong number of arguments. want=0. got=%v", len(args))
+                return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=0. got=%v", len(args))
             }
             return &object.String{Value: "visit https://github.com/ryanlueds/luederlang"}
         },
@@ -37,3 +203,66 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+// The failure of an assert, message is the optional last argument
+func assertionError(message []object.Object, format string, a ...interface{}) *object.Error {
+    err := newError(object.ASSERTION_ERROR, format, a...)
+    if len(message) == 1 {
+        if str, ok := message[0].(*object.String); ok {
+            err.Message = str.Value + ": " + err.Message
+        } else {
+            err.Message = message[0].Inspect() + ": " + err.Message
+        }
+    }
+    return err
+}
+
+// Strings get their quotes back so "1" and 1 look different
+func describe(obj object.Object) string {
+    if str, ok := obj.(*object.String); ok {
+        return fmt.Sprintf("%q", str.Value)
+    }
+    return obj.Inspect()
+}
+
+// Equality for assert_eq. Numbers compare like == does, lists and maps by
+// their contents, functions and modules only to themselves.
+func valuesEqual(a, b object.Object) bool {
+    switch a := a.(type) {
+    case *object.Integer, *object.BigInt, *object.Float:
+        if b.Type() != object.INTEGER_OBJ && b.Type() != object.BIGINT_OBJ && b.Type() != object.FLOAT_OBJ {
+            return false
+        }
+        return evalInfixExpression(a, "==", b) == TRUE
+    case *object.String:
+        b, ok := b.(*object.String)
+        return ok && a.Value == b.Value
+    case *object.List:
+        b, ok := b.(*object.List)
+        if !ok || len(a.Elements) != len(b.Elements) {
+            return false
+        }
+        for i := range a.Elements {
+            if !valuesEqual(a.Elements[i], b.Elements[i]) {
+                return false
+            }
+        }
+        return true
+    case *object.Map:
+        b, ok := b.(*object.Map)
+        if !ok || a.Len() != b.Len() {
+            return false
+        }
+        for _, pair := range a.Ordered() {
+            other, ok := b.Get(pair.Key.(object.Hashable))
+            if !ok || !valuesEqual(pair.Value, other.Value) {
+                return false
+            }
+        }
+        return true
+    case *object.ErrorValue:
+        b, ok := b.(*object.ErrorValue)
+        return ok && a.Err.Kind == b.Err.Kind && a.Err.Message == b.Err.Message
+    }
+    return a == b
+}
@@ -0,0 +1,45 @@
+package evaluator_test
+
+import (
+	"fmt"
+	"luederlang/compiler"
+	"luederlang/evaluator"
+	"luederlang/lexer"
+	"luederlang/object"
+	"luederlang/parser"
+	"luederlang/vm"
+	"os"
+	"testing"
+)
+
+// Every test in this package runs once with the tree walker and once with the
+// bytecode vm, testEval picks the engine
+var engine string
+
+func TestMain(m *testing.M) {
+	code := 0
+	for _, engine = range []string{"tree", "vm"} {
+		if c := m.Run(); c != 0 {
+			fmt.Printf("FAIL with engine=%s\n", engine)
+			code = c
+		}
+	}
+	os.Exit(code)
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if engine == "tree" {
+		return evaluator.Eval(program, object.NewEnvironment())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		compileErr := err.(*compiler.CompileError)
+		return &object.Error{Message: compileErr.Message, Pos: compileErr.Pos}
+	}
+	return vm.New(c.Bytecode()).Run()
+}
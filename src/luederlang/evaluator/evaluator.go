@@ -3,24 +3,78 @@ package evaluator
 import (
     "luederlang/object"
     "luederlang/ast"
+    "luederlang/modules"
+    "luederlang/token"
     "fmt"
+    "math"
+    "math/big"
+    "strings"
+    "unicode/utf8"
 )
 
 var (
     TRUE = &object.Boolean{Value: true} // what do you mean these arent const
     FALSE = &object.Boolean{Value: false}
     NULL = &object.Null{}
+    BREAK = &object.Break{}
+    CONTINUE = &object.Continue{}
 )
 
+// How many calls can be running at once before recursion turns into a
+// RecursionError instead of overflowing the Go stack. The vm uses it too.
+var MaxCallDepth = 10000
+
+// calls running right now, tail calls don't add to it
+var depth int
+
+// Every module gets an environment of its own, shared by everyone importing it
+var imports *modules.Loader
+
+func init() {
+    imports = modules.NewLoader(func(program *ast.Program) (object.Scope, *object.Error) {
+        env := object.NewEnvironment()
+        if err, ok := Eval(program, env).(*object.Error); ok {
+            return nil, err
+        }
+        return env, nil
+    })
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+    result := evalNode(node, env)
+
+    // the innermost node an error passes through is where it happened
+    if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+        err.Pos = node.Pos()
+    }
+
+    return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
     switch node := node.(type) {
     case *ast.StringLiteral:
         return &object.String{Value: node.Value}
 
+    case *ast.InterpolatedString:
+        var out strings.Builder
+        for _, part := range node.Parts {
+            value := Eval(part, env)
+            if isError(value) {
+                return value
+            }
+            out.WriteString(value.Inspect())
+        }
+        return &object.String{Value: out.String()}
+
     case *ast.FunctionLiteral:
-        params := node.Parameters
-        body := node.Body
-        return &object.Function{Parameters: params, Body: body, Env: env}
+        return &object.Function{
+            Parameters: node.Parameters,
+            ParameterTypes: node.ParameterTypes,
+            ReturnType: node.ReturnType,
+            Body: node.Body,
+            Env: env,
+        }
 
     case *ast.Identifier:
         return evalIdentifier(node, env)
@@ -35,28 +89,31 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
         if len(args) == 1 && isError(args[0]) {
             return args[0]
         }
-        return applyFunction(function, args)
-
-    case *ast.LetStatement:
-        val := Eval(node.Value, env)
-        if isError(val) {
-            return val
+        if node.Tail {
+            return &tailCall{function: function, args: args, pos: node.Pos()}
         }
-        env.Set(node.Name.Value, val)
+        return applyFunction(function, args, node.Pos())
 
-    case *ast.IntStatement:
-        val := Eval(node.Value, env)
+    case *ast.LetStatement, *ast.IntStatement, *ast.FloatStatement, *ast.BoolStatement,
+        *ast.StringStatement, *ast.ListStatement, *ast.MapStatement, *ast.FunStatement:
+        name, value, typeName, _ := ast.DeclarationOf(node.(ast.Statement))
+        val := Eval(value, env)
         if isError(val) {
             return val
         }
-        env.Set(node.Name.Value, val)
-
-    case *ast.FloatStatement:
-        val := Eval(node.Value, env)
-        if isError(val) {
-            return val
+        val, ok := coerceToType(typeName, val)
+        if !ok {
+            return newError(object.TYPE_ERROR, "cannot assign %s to %s %s", object.TypeName(val), typeName, name.Value)
         }
-        env.Set(node.Name.Value, val)
+        if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+            if _, isLiteral := value.(*ast.FunctionLiteral); isLiteral {
+                fn.Name = name.Value
+            }
+        }
+        env.Declare(name.Value, val, typeName)
+
+    case *ast.AssignStatement:
+        return evalAssignStatement(node, env)
 
     case *ast.Program:
         return evalProgram(node, env)
@@ -67,6 +124,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
     case *ast.IntegerLiteral:
         return &object.Integer{Value: node.Value}
 
+    case *ast.BigIntegerLiteral:
+        return &object.BigInt{Value: node.Value}
+
     case *ast.FloatLiteral:
         return &object.Float{Value: node.Value}
 
@@ -104,31 +164,294 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
             return val
         }
         return &object.ReturnValue{Value: val}
+
+    case *ast.ListLiteral:
+        elements := evalExpressions(node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        return &object.List{Elements: elements}
+
+    case *ast.IndexExpression:
+        left := Eval(node.Left, env)
+        if isError(left) {
+            return left
+        }
+        index := Eval(node.Index, env)
+        if isError(index) {
+            return index
+        }
+        return evalIndexExpression(left, index)
+
+    case *ast.SliceExpression:
+        left := Eval(node.Left, env)
+        if isError(left) {
+            return left
+        }
+        var start, end object.Object
+        if node.Start != nil {
+            start = Eval(node.Start, env)
+            if isError(start) {
+                return start
+            }
+        }
+        if node.End != nil {
+            end = Eval(node.End, env)
+            if isError(end) {
+                return end
+            }
+        }
+        return evalSliceExpression(left, start, end)
+
+    case *ast.MapLiteral:
+        return evalMapLiteral(node, env)
+
+    case *ast.WhileStatement:
+        return evalWhileStatement(node, env)
+
+    case *ast.ForStatement:
+        return evalForStatement(node, env)
+
+    case *ast.ForInStatement:
+        return evalForInStatement(node, env)
+
+    case *ast.TryStatement:
+        return evalTryStatement(node, env)
+
+    case *ast.ImportStatement:
+        module, err := imports.Import(node.Path, node.Pos().Filename)
+        if err != nil {
+            return err
+        }
+        env.Declare(node.Name.Value, module, "")
+
+    case *ast.ExportStatement:
+        return Eval(node.Statement, env)
+
+    case *ast.ThrowStatement:
+        val := Eval(node.Value, env)
+        if isError(val) {
+            return val
+        }
+        return throwValue(val)
+
+    case *ast.BreakStatement:
+        return BREAK
+
+    case *ast.ContinueStatement:
+        return CONTINUE
+
+    case *ast.IndexAssignStatement:
+        left := Eval(node.Target.Left, env)
+        if isError(left) {
+            return left
+        }
+        index := Eval(node.Target.Index, env)
+        if isError(index) {
+            return index
+        }
+        var current object.Object
+        if node.Operator != "=" {
+            current = evalIndexExpression(left, index)
+            if isError(current) {
+                return current
+            }
+        }
+        val := evalAssignedValue(node.Operator, current, node.Value, env)
+        if isError(val) {
+            return val
+        }
+        if err := evalIndexAssignment(left, index, val); err != nil {
+            return err
+        }
     }
     return NULL
 }
 
-func applyFunction(function object.Object, args []object.Object) object.Object {
-    switch f := function.(type) {
-    case *object.Function:
-        extendedEnv := extendFunctionEnv(f, args)
-        evaluated := Eval(f.Body, extendedEnv)
-        return unwrapReturnValue(evaluated)
-    case *object.Builtin:
-        return f.Function(args...)
-    default:
-        return newError("not a function: %s", function.Type())
+// A call in tail position. The function body hands it back to applyFunction
+// which runs it in place of the caller, so tail recursion doesn't grow the stack
+type tailCall struct {
+    function object.Object
+    args     []object.Object
+    pos      token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// callPos is where the function is called from, errors coming out of its
+// body add it to their trace
+func applyFunction(function object.Object, args []object.Object, callPos token.Position) object.Object {
+    // declared return types of the functions that tail called out of here,
+    // the final result still has to pass all of them
+    returnTypes := []string{}
+    // the function whose tail call is being run, if any
+    var caller *object.Function
+    var callerPos token.Position
+
+    for {
+        var result object.Object
+        switch f := function.(type) {
+        case *object.Function:
+            extendedEnv, err := extendFunctionEnv(f, args)
+            if err != nil {
+                return failCall(err, callPos, caller, callerPos)
+            }
+            if depth >= MaxCallDepth {
+                return failCall(newError(object.RECURSION_ERROR, "maximum recursion depth exceeded"), callPos, caller, callerPos)
+            }
+            depth++
+            result = unwrapReturnValue(Eval(f.Body, extendedEnv))
+            depth--
+            if err, ok := result.(*object.Error); ok {
+                err.Trace = append(err.Trace, object.TraceEntry{Function: f.Name, Pos: callPos})
+                return err
+            }
+            if f.ReturnType != nil {
+                returnTypes = appendReturnType(returnTypes, f.ReturnType.Value)
+            }
+            if tc, ok := result.(*tailCall); ok {
+                caller, callerPos = f, callPos
+                function, args, callPos = tc.function, tc.args, tc.pos
+                continue
+            }
+        case *object.Builtin:
+            result = f.Function(args...)
+            if err, ok := result.(*object.Error); ok {
+                return failCall(err, callPos, caller, callerPos)
+            }
+        default:
+            return failCall(newError(object.TYPE_ERROR, "not a function: %s", object.TypeName(function)), callPos, caller, callerPos)
+        }
+
+        for i := len(returnTypes) - 1; i >= 0; i-- {
+            coerced, ok := coerceToType(returnTypes[i], result)
+            if !ok {
+                return newError(object.TYPE_ERROR, "cannot return %s from fun returning %s", object.TypeName(result), returnTypes[i])
+            }
+            result = coerced
+        }
+        return result
+    }
+}
+
+// Recursion through a typed function would otherwise pile up the same type
+func appendReturnType(returnTypes []string, typeName string) []string {
+    if len(returnTypes) > 0 && returnTypes[len(returnTypes)-1] == typeName {
+        return returnTypes
+    }
+    return append(returnTypes, typeName)
+}
+
+// An error from setting up a call. A tail call is made from inside of the
+// caller's body, so the caller shows up in the trace like it would without
+// the tail call
+func failCall(err *object.Error, pos token.Position, caller *object.Function, callerPos token.Position) *object.Error {
+    if !err.Pos.IsValid() {
+        err.Pos = pos
+    }
+    if caller != nil {
+        err.Trace = append(err.Trace, object.TraceEntry{Function: caller.Name, Pos: callerPos})
     }
+    return err
 }
 
-func extendFunctionEnv(function *object.Function, args []object.Object) *object.Environment {
+func extendFunctionEnv(function *object.Function, args []object.Object) (*object.Environment, *object.Error) {
     env := object.NewEnclosedEnvironment(function.Env)
 
+    if len(args) != len(function.Parameters) {
+        return nil, newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=%d. got=%d", len(function.Parameters), len(args))
+    }
+
     for i, param := range function.Parameters {
-        env.Set(param.Value, args[i])
+        arg := args[i]
+        typeName := ""
+        if i < len(function.ParameterTypes) && function.ParameterTypes[i] != nil {
+            typeName = function.ParameterTypes[i].Value
+            var ok bool
+            if arg, ok = coerceToType(typeName, arg); !ok {
+                return nil, newError(object.TYPE_ERROR, "cannot pass %s as %s %s", object.TypeName(arg), typeName, param.Value)
+            }
+        }
+        env.Declare(param.Value, arg, typeName)
+    }
+
+    return env, nil
+}
+
+// Assignment changes the variable where it was declared, which may be outside
+// the current function. It keeps the type the variable was declared with.
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+    name := node.Name.Value
+    scope, declared := env.Resolve(name)
+    if !declared && node.Operator != "=" {
+        return newError(object.NAME_ERROR, "identifier not found: %s", name)
     }
 
-    return env
+    var current object.Object
+    if declared {
+        current, _ = scope.Get(name)
+    }
+    val := evalAssignedValue(node.Operator, current, node.Value, env)
+    if isError(val) {
+        return val
+    }
+    if !declared {
+        return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", name)
+    }
+
+    typeName := scope.DeclaredType(name)
+    val, ok := coerceToType(typeName, val)
+    if !ok {
+        return newError(object.TYPE_ERROR, "cannot assign %s to %s %s", object.TypeName(val), typeName, name)
+    }
+    scope.Set(name, val)
+    return NULL
+}
+
+// What an assignment stores: the value itself for =, otherwise the current
+// value combined with it. ++ and -- have no value and count by one.
+func evalAssignedValue(operator string, current object.Object, value ast.Expression, env *object.Environment) object.Object {
+    var right object.Object = &object.Integer{Value: 1}
+    if value != nil {
+        right = Eval(value, env)
+        if isError(right) {
+            return right
+        }
+    }
+
+    if operator == "=" {
+        return right
+    }
+    return evalInfixExpression(current, ast.CompoundOperator(operator), right)
+}
+
+// Checks a value against a declared type, ints are widened when a float is
+// wanted. An empty typeName (let) accepts anything.
+func coerceToType(typeName string, val object.Object) (object.Object, bool) {
+    switch typeName {
+    case "int":
+        return val, val.Type() == object.INTEGER_OBJ || val.Type() == object.BIGINT_OBJ
+    case "float":
+        if integer, ok := val.(*object.Integer); ok {
+            return &object.Float{Value: float64(integer.Value)}, true
+        }
+        if val.Type() == object.BIGINT_OBJ {
+            return bigIntToFloat(val), true
+        }
+        return val, val.Type() == object.FLOAT_OBJ
+    case "bool":
+        return val, val.Type() == object.BOOLEAN_OBJ
+    case "string":
+        return val, val.Type() == object.STRING_OBJ
+    case "list":
+        return val, val.Type() == object.LIST_OBJ
+    case "map":
+        return val, val.Type() == object.MAP_OBJ
+    case "fun":
+        return val, val.Type() == object.FUNCTION_OBJ || val.Type() == object.BUILTIN_OBJ
+    }
+    return val, true
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -140,7 +463,7 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-    var result []object.Object
+    result := []object.Object{}
 
     for _, e := range exps {
         evaluated := Eval(e, env)
@@ -160,11 +483,11 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
     if builtin, ok := builtins[node.Value]; ok {
         return builtin
     }
-    return newError("identifier not found: %s", node.Value)
+    return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-    return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+    return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
@@ -196,11 +519,8 @@ func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.
     for _, statement := range bs.Statements {
         result = Eval(statement, env)
 
-        if result != nil {
-            rt := result.Type()
-            if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-                return result
-            }
+        if leavesBlock(result) {
+            return result
         }
     }
     return result
@@ -213,12 +533,340 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
     }
     if isTruthy(condition) {
         return Eval(ie.Consequence, env)
+    } else if ie.ElseIf != nil {
+        return evalIfExpression(ie.ElseIf, env)
     } else if ie.Alternative != nil {
         return Eval(ie.Alternative, env)
     }
     return NULL
 }
 
+func evalIndexExpression(left, index object.Object) object.Object {
+    switch {
+    case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+        field, ok := left.(*object.ErrorValue).Field(index.(*object.String).Value)
+        if !ok {
+            return NULL
+        }
+        return field
+    case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+        module := left.(*object.Module)
+        name := index.(*object.String).Value
+        val, ok := module.Get(name)
+        if !ok {
+            return newError(object.NAME_ERROR, "%s has no export %s", module.Name, name)
+        }
+        return val
+    case left.Type() == object.LIST_OBJ && index.Type() == object.INTEGER_OBJ:
+        elements := left.(*object.List).Elements
+        i, ok := normalizeIndex(index.(*object.Integer).Value, len(elements))
+        if !ok {
+            return newError(object.INDEX_ERROR, "index out of range: %d", index.(*object.Integer).Value)
+        }
+        return elements[i]
+    case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+        chars := characters(left.(*object.String).Value)
+        i, ok := normalizeIndex(index.(*object.Integer).Value, len(chars))
+        if !ok {
+            return newError(object.INDEX_ERROR, "index out of range: %d", index.(*object.Integer).Value)
+        }
+        return &object.String{Value: chars[i]}
+    case left.Type() == object.MAP_OBJ:
+        key, ok := index.(object.Hashable)
+        if !ok {
+            return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(index))
+        }
+        pair, ok := left.(*object.Map).Get(key)
+        if !ok {
+            return NULL
+        }
+        return pair.Value
+    default:
+        return newError(object.TYPE_ERROR, "index operator not supported: %s[%s]", object.TypeName(left), object.TypeName(index))
+    }
+}
+
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+    switch {
+    case left.Type() == object.LIST_OBJ && index.Type() == object.INTEGER_OBJ:
+        elements := left.(*object.List).Elements
+        i, ok := normalizeIndex(index.(*object.Integer).Value, len(elements))
+        if !ok {
+            return newError(object.INDEX_ERROR, "index out of range: %d", index.(*object.Integer).Value)
+        }
+        elements[i] = val
+    case left.Type() == object.MAP_OBJ:
+        key, ok := index.(object.Hashable)
+        if !ok {
+            return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(index))
+        }
+        left.(*object.Map).Set(key, val)
+    default:
+        return newError(object.TYPE_ERROR, "index assignment not supported: %s[%s]", object.TypeName(left), object.TypeName(index))
+    }
+    return nil
+}
+
+func evalMapLiteral(node *ast.MapLiteral, env *object.Environment) object.Object {
+    m := object.NewMap()
+
+    for i, keyNode := range node.Keys {
+        key := Eval(keyNode, env)
+        if isError(key) {
+            return key
+        }
+
+        hashKey, ok := key.(object.Hashable)
+        if !ok {
+            return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(key))
+        }
+
+        value := Eval(node.Values[i], env)
+        if isError(value) {
+            return value
+        }
+
+        m.Set(hashKey, value)
+    }
+
+    return m
+}
+
+func evalSliceExpression(left, start, end object.Object) object.Object {
+    var length int
+    var chars []string
+    switch left := left.(type) {
+    case *object.List:
+        length = len(left.Elements)
+    case *object.String:
+        chars = characters(left.Value)
+        length = len(chars)
+    default:
+        return newError(object.TYPE_ERROR, "slice operator not supported: %s", object.TypeName(left))
+    }
+
+    lo, ok := sliceBound(start, 0, length)
+    if !ok {
+        return newError(object.TYPE_ERROR, "slice bounds must be int. got=%s", object.TypeName(start))
+    }
+    hi, ok := sliceBound(end, length, length)
+    if !ok {
+        return newError(object.TYPE_ERROR, "slice bounds must be int. got=%s", object.TypeName(end))
+    }
+    if hi < lo {
+        hi = lo
+    }
+
+    switch left := left.(type) {
+    case *object.List:
+        elements := make([]object.Object, hi-lo)
+        copy(elements, left.Elements[lo:hi])
+        return &object.List{Elements: elements}
+    default:
+        return &object.String{Value: strings.Join(chars[lo:hi], "")}
+    }
+}
+
+// Splits s into its code points, which is what strings are indexed, sliced and
+// iterated by. A byte that isn't UTF-8 counts as one on its own and is kept as
+// it is, so joining them back always gives s.
+func characters(s string) []string {
+    chars := make([]string, 0, len(s))
+    for len(s) > 0 {
+        _, width := utf8.DecodeRuneInString(s)
+        chars = append(chars, s[:width])
+        s = s[width:]
+    }
+    return chars
+}
+
+// Negative indices count from the back, so xs[-1] is the last element
+func normalizeIndex(index int64, length int) (int, bool) {
+    if index < 0 {
+        index += int64(length)
+    }
+    if index < 0 || index >= int64(length) {
+        return 0, false
+    }
+    return int(index), true
+}
+
+// Slice bounds are clamped instead of erroring, like python
+func sliceBound(bound object.Object, fallback int, length int) (int, bool) {
+    if bound == nil {
+        return fallback, true
+    }
+    integer, ok := bound.(*object.Integer)
+    if !ok {
+        return 0, false
+    }
+    i := integer.Value
+    if i < 0 {
+        i += int64(length)
+    }
+    if i < 0 {
+        i = 0
+    }
+    if i > int64(length) {
+        i = int64(length)
+    }
+    return int(i), true
+}
+
+// The finally block runs however the try and catch blocks were left. If it
+// returns, breaks or errors itself that wins over what they did.
+func evalTryStatement(ts *ast.TryStatement, env *object.Environment) object.Object {
+    result := Eval(ts.Body, env)
+
+    if err, ok := result.(*object.Error); ok && ts.Catch != nil {
+        env.Declare(ts.CatchVariable.Value, &object.ErrorValue{Err: err}, "")
+        result = Eval(ts.Catch, env)
+    }
+
+    if ts.Finally != nil {
+        if finally := Eval(ts.Finally, env); leavesBlock(finally) {
+            return finally
+        }
+    }
+
+    if leavesBlock(result) {
+        return result
+    }
+    return NULL
+}
+
+// Whether obj stops the statements around it from running
+func leavesBlock(obj object.Object) bool {
+    if obj == nil {
+        return false
+    }
+    switch obj.Type() {
+    case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+        return true
+    }
+    return false
+}
+
+// The error a throw raises. Caught errors are thrown again as they were, with
+// the trace they collected so far.
+func throwValue(val object.Object) *object.Error {
+    switch val := val.(type) {
+    case *object.String:
+        return newError(object.THROWN_ERROR, "%s", val.Value)
+    case *object.ErrorValue:
+        err := *val.Err
+        err.Trace = append([]object.TraceEntry{}, val.Err.Trace...)
+        return &err
+    default:
+        return newError(object.TYPE_ERROR, "cannot throw %s", object.TypeName(val))
+    }
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+    for {
+        condition := Eval(ws.Condition, env)
+        if isError(condition) {
+            return condition
+        }
+        if !isTruthy(condition) {
+            return NULL
+        }
+
+        if result, done := evalLoopBody(ws.Body, env); done {
+            return result
+        }
+    }
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+    if fs.Init != nil {
+        init := Eval(fs.Init, env)
+        if isError(init) {
+            return init
+        }
+    }
+
+    for {
+        if fs.Condition != nil {
+            condition := Eval(fs.Condition, env)
+            if isError(condition) {
+                return condition
+            }
+            if !isTruthy(condition) {
+                return NULL
+            }
+        }
+
+        if result, done := evalLoopBody(fs.Body, env); done {
+            return result
+        }
+
+        if fs.Post != nil {
+            post := Eval(fs.Post, env)
+            if isError(post) {
+                return post
+            }
+        }
+    }
+}
+
+func evalForInStatement(fis *ast.ForInStatement, env *object.Environment) object.Object {
+    iterable := Eval(fis.Iterable, env)
+    if isError(iterable) {
+        return iterable
+    }
+
+    items, err := iterationItems(iterable)
+    if err != nil {
+        return err
+    }
+
+    for _, item := range items {
+        env.Set(fis.Variable.Value, item)
+
+        if result, done := evalLoopBody(fis.Body, env); done {
+            return result
+        }
+    }
+    return NULL
+}
+
+// The values a for-in loop visits: list elements, characters or map keys
+func iterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+    var items []object.Object
+    switch iterable := iterable.(type) {
+    case *object.List:
+        items = append(items, iterable.Elements...)
+    case *object.String:
+        for _, char := range characters(iterable.Value) {
+            items = append(items, &object.String{Value: char})
+        }
+    case *object.Map:
+        for _, pair := range iterable.Ordered() {
+            items = append(items, pair.Key)
+        }
+    default:
+        return nil, newError(object.TYPE_ERROR, "cannot iterate over %s", object.TypeName(iterable))
+    }
+    return items, nil
+}
+
+// Runs one iteration, done is true when the loop has to stop and return result
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+    result := Eval(body, env)
+    if result == nil {
+        return nil, false
+    }
+
+    switch result.Type() {
+    case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+        return result, true
+    case object.BREAK_OBJ:
+        return NULL, true
+    }
+    return nil, false
+}
+
 func isTruthy(obj object.Object) bool {
     switch obj {
     case TRUE:
@@ -247,7 +895,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
     case "-":
         return evalMinusPrefixExpression(right)
     default:
-        return newError("unknown operator: %s%s", operator, right.Type())
+        return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, object.TypeName(right))
     }
 }
 
@@ -260,18 +908,20 @@ func evalBangPrefixExpression(right object.Object) object.Object {
     case NULL:
         return TRUE
     default:
-        return newError("type mismatch: !%s", right.Type())
+        return newError(object.TYPE_ERROR, "type mismatch: !%s", object.TypeName(right))
     }
 }
 
 func evalMinusPrefixExpression(right object.Object) object.Object {
     switch right.Type() {
     case object.INTEGER_OBJ:
-        return &object.Integer{Value: 0-right.(*object.Integer).Value}
+        return negateInteger(right.(*object.Integer).Value)
+    case object.BIGINT_OBJ:
+        return normalizeBigInt(new(big.Int).Neg(right.(*object.BigInt).Value))
     case object.FLOAT_OBJ:
         return &object.Float{Value: 0-right.(*object.Float).Value}
     default:
-        return newError("type mismatch: -%s", right.Type())
+        return newError(object.TYPE_ERROR, "type mismatch: -%s", object.TypeName(right))
     }
 }
 
@@ -283,6 +933,10 @@ func evalInfixExpression(
     operator string,
     right object.Object,
 ) object.Object {
+    if left.Type() == object.BIGINT_OBJ || right.Type() == object.BIGINT_OBJ {
+        return evalBigIntInfixExpression(left, operator, right)
+    }
+
     switch operator{
     case "+":
         return evalPlusInfixExpression(left, right)
@@ -305,14 +959,9 @@ func evalInfixExpression(
     case "||":
         return evalOrInfixExpression(left, right)
     case "%":
-        if left.Type() != object.INTEGER_OBJ && right.Type() != object.INTEGER_OBJ {
-            return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-        }
-        leftVal := left.(*object.Integer).Value
-        rightVal := right.(*object.Integer).Value
-		return &object.Integer{Value: ((leftVal % rightVal) + rightVal) % rightVal}
+        return evalModuloInfixExpression(left, right)
     default:
-        return newError("How did you even do this... What operator is %s?", operator)
+        return newError(object.INTERNAL_ERROR, "How did you even do this... What operator is %s?", operator)
     }
 }
 
@@ -324,7 +973,7 @@ func evalPlusInfixExpression(left, right object.Object) object.Object {
         switch right.Type() {
         case object.INTEGER_OBJ:
             rightVal := right.(*object.Integer).Value
-            return &object.Integer{Value: leftVal + rightVal}
+            return addIntegers(leftVal, rightVal)
         case object.FLOAT_OBJ:
             rightVal := right.(*object.Float).Value
             return &object.Float{Value: float64(leftVal) + rightVal}
@@ -346,7 +995,52 @@ func evalPlusInfixExpression(left, right object.Object) object.Object {
         rightVal := right.(*object.String).Value
         return &object.String{Value: leftVal + rightVal}
     }
-    return newError("type mismatch: %s + %s", left.Type(), right.Type())
+    if left.Type() == object.LIST_OBJ && right.Type() == object.LIST_OBJ {
+        leftVal := left.(*object.List).Elements
+        rightVal := right.(*object.List).Elements
+        elements := make([]object.Object, 0, len(leftVal)+len(rightVal))
+        elements = append(elements, leftVal...)
+        elements = append(elements, rightVal...)
+        return &object.List{Elements: elements}
+    }
+    return newError(object.TYPE_ERROR, "type mismatch: %s + %s", object.TypeName(left), object.TypeName(right))
+}
+
+// Takes the sign of the right side like the ints do, so -3 % 5.0 is 2.0
+func evalModuloInfixExpression(left, right object.Object) object.Object {
+    if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+        leftVal := left.(*object.Integer).Value
+        rightVal := right.(*object.Integer).Value
+        if rightVal == 0 {
+            return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+        }
+        return moduloIntegers(leftVal, rightVal)
+    }
+
+    leftVal, leftOk := floatValue(left)
+    rightVal, rightOk := floatValue(right)
+    if !leftOk || !rightOk {
+        return newError(object.TYPE_ERROR, "type mismatch: %s %% %s", object.TypeName(left), object.TypeName(right))
+    }
+    if rightVal == 0 {
+        return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+    }
+    m := math.Mod(leftVal, rightVal)
+    if m != 0 && (m < 0) != (rightVal < 0) {
+        m += rightVal
+    }
+    return &object.Float{Value: m}
+}
+
+// An int or float as a float64
+func floatValue(obj object.Object) (float64, bool) {
+    switch obj := obj.(type) {
+    case *object.Integer:
+        return float64(obj.Value), true
+    case *object.Float:
+        return obj.Value, true
+    }
+    return 0, false
 }
 
 func evalMultiplyInfixExpression(left, right object.Object) object.Object {
@@ -356,7 +1050,7 @@ func evalMultiplyInfixExpression(left, right object.Object) object.Object {
         switch right.Type() {
         case object.INTEGER_OBJ:
             rightVal := right.(*object.Integer).Value
-            return &object.Integer{Value: leftVal * rightVal}
+            return multiplyIntegers(leftVal, rightVal)
         case object.FLOAT_OBJ:
             rightVal := right.(*object.Float).Value
             return &object.Float{Value: float64(leftVal) * rightVal}
@@ -372,7 +1066,7 @@ func evalMultiplyInfixExpression(left, right object.Object) object.Object {
             return &object.Float{Value: leftVal * rightVal}
         }
     }
-    return newError("type mismatch: %s * %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s * %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalSubtractInfixExpression(left, right object.Object) object.Object {
@@ -382,7 +1076,7 @@ func evalSubtractInfixExpression(left, right object.Object) object.Object {
         switch right.Type() {
         case object.INTEGER_OBJ:
             rightVal := right.(*object.Integer).Value
-            return &object.Integer{Value: leftVal - rightVal}
+            return subtractIntegers(leftVal, rightVal)
         case object.FLOAT_OBJ:
             rightVal := right.(*object.Float).Value
             return &object.Float{Value: float64(leftVal) - rightVal}
@@ -398,7 +1092,7 @@ func evalSubtractInfixExpression(left, right object.Object) object.Object {
             return &object.Float{Value: leftVal - rightVal}
         }
     }
-    return newError("type mismatch: %s - %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s - %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalDivideInfixExpression(left, right object.Object) object.Object {
@@ -408,7 +1102,10 @@ func evalDivideInfixExpression(left, right object.Object) object.Object {
         switch right.Type() {
         case object.INTEGER_OBJ:
             rightVal := right.(*object.Integer).Value
-            return &object.Integer{Value: leftVal / rightVal}
+            if rightVal == 0 {
+                return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+            }
+            return divideIntegers(leftVal, rightVal)
         case object.FLOAT_OBJ:
             rightVal := right.(*object.Float).Value
             return &object.Float{Value: float64(leftVal) / rightVal}
@@ -424,7 +1121,7 @@ func evalDivideInfixExpression(left, right object.Object) object.Object {
             return &object.Float{Value: leftVal / rightVal}
         }
     }
-    return newError("type mismatch: %s / %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s / %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalLTInfixExpression(left, right object.Object) object.Object {
@@ -450,7 +1147,7 @@ func evalLTInfixExpression(left, right object.Object) object.Object {
             return nativeBoolToBooleanObject(leftVal < rightVal)
         }
     }
-    return newError("type mismatch: %s < %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s < %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalGTInfixExpression(left, right object.Object) object.Object {
@@ -476,7 +1173,7 @@ func evalGTInfixExpression(left, right object.Object) object.Object {
             return nativeBoolToBooleanObject(leftVal > rightVal)
         }
     }
-    return newError("type mismatch: %s > %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s > %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalEqualsInfixExpression(left, right object.Object) object.Object {
@@ -505,7 +1202,10 @@ func evalEqualsInfixExpression(left, right object.Object) object.Object {
     if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
         return nativeBoolToBooleanObject(left == right)
     }
-    return newError("type mismatch: %s == %s", left.Type(), right.Type())
+    if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+        return nativeBoolToBooleanObject(left.(*object.String).Value == right.(*object.String).Value)
+    }
+    return newError(object.TYPE_ERROR, "type mismatch: %s == %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalNotEqualsInfixExpression(left, right object.Object) object.Object {
@@ -534,19 +1234,22 @@ func evalNotEqualsInfixExpression(left, right object.Object) object.Object {
     if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
         return nativeBoolToBooleanObject(left != right)
     }
-    return newError("type mismatch: %s != %s", left.Type(), right.Type())
+    if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+        return nativeBoolToBooleanObject(left.(*object.String).Value != right.(*object.String).Value)
+    }
+    return newError(object.TYPE_ERROR, "type mismatch: %s != %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalAndInfixExpression(left, right object.Object) object.Object {
     if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
         return nativeBoolToBooleanObject(left.(*object.Boolean).Value && right.(*object.Boolean).Value)
     }
-    return newError("type mismatch: %s && %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s && %s", object.TypeName(left), object.TypeName(right))
 }
 
 func evalOrInfixExpression(left, right object.Object) object.Object {
     if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
         return nativeBoolToBooleanObject(left.(*object.Boolean).Value || right.(*object.Boolean).Value)
     }
-    return newError("type mismatch: %s && %s", left.Type(), right.Type())
+    return newError(object.TYPE_ERROR, "type mismatch: %s && %s", object.TypeName(left), object.TypeName(right))
 }
@@ -1,21 +1,18 @@
-package evaluator
+package evaluator_test
 
 import (
+	"io"
+	"luederlang/evaluator"
 	"luederlang/lexer"
 	"luederlang/object"
 	"luederlang/parser"
+	"luederlang/typechecker"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := object.NewEnvironment()
-
-	return Eval(program, env)
-}
-
 func testIntegerObject(t *testing.T, obj object.Object, expected int64, test string) bool {
 	res, ok := obj.(*object.Integer)
 	if !ok {
@@ -48,6 +45,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"5--3", 8},
+		{"--5", 5},
+		{"let x = 2; x--; x--1", 2},
 	}
 
 	for _, tt := range tests {
@@ -56,6 +56,67 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input        string
+		expected     string
+		expectedType object.ObjectType
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808", object.BIGINT_OBJ},
+		{"-9223372036854775807 - 2", "-9223372036854775809", object.BIGINT_OBJ},
+		{"4611686018427387904 * 4", "18446744073709551616", object.BIGINT_OBJ},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808", object.BIGINT_OBJ},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808", object.BIGINT_OBJ},
+		{"let f = fun(n) { if (n < 2) { return 1 } n * f(n - 1) }; f(25)", "15511210043330985984000000", object.BIGINT_OBJ},
+		{"9223372036854775808", "9223372036854775808", object.BIGINT_OBJ},
+		{"0x1_0000_0000_0000_0000 - 1", "18446744073709551615", object.BIGINT_OBJ},
+		// and back once it fits
+		{"9223372036854775807 + 1 - 1", "9223372036854775807", object.INTEGER_OBJ},
+		{"(9223372036854775807 * 10) / 10", "9223372036854775807", object.INTEGER_OBJ},
+		{"-(9223372036854775807 + 1)", "-9223372036854775808", object.INTEGER_OBJ},
+		{"-9223372036854775808", "-9223372036854775808", object.INTEGER_OBJ},
+		{"let x = 9223372036854775807; x++; x--; x", "9223372036854775807", object.INTEGER_OBJ},
+		{"(9223372036854775807 + 1) % 10", "8", object.INTEGER_OBJ},
+		{"-(9223372036854775807 + 2) % 7", "5", object.INTEGER_OBJ},
+		{"(9223372036854775807 + 3) % -7", "-4", object.INTEGER_OBJ},
+		{"9223372036854775806 % 9223372036854775807", "9223372036854775806", object.INTEGER_OBJ},
+		// next to floats they are floats, % takes the sign of the right side
+		{"let md = fun(a, b) { a % b }; let fact = fun(n) { if (n < 2) { return 1 } n * fact(n - 1) }; md(fact(25), 2.5)", "0.5", object.FLOAT_OBJ},
+		{"(9223372036854775807 + 1) % 10.0", "8", object.FLOAT_OBJ},
+		{"-(9223372036854775807 + 1) % 3.0", "1", object.FLOAT_OBJ},
+		{"2.5 % (9223372036854775807 + 1)", "2.5", object.FLOAT_OBJ},
+		{"-2.5 % (9223372036854775807 + 1)", "9.223372036854776e+18", object.FLOAT_OBJ},
+		{"7 % 2.5", "2", object.FLOAT_OBJ},
+		{"-3 % 5.0", "2", object.FLOAT_OBJ},
+		{"3 % -5.0", "-2", object.FLOAT_OBJ},
+		{"7.5 % 2", "1.5", object.FLOAT_OBJ},
+		{"1 % 0.0", "ERROR: division by zero", object.ERROR_OBJ},
+		{"(9223372036854775807 + 1) && 1.5", "ERROR: type mismatch: int && float", object.ERROR_OBJ},
+		{`(9223372036854775807 + 1) % "a"`, "ERROR: type mismatch: int % string", object.ERROR_OBJ},
+		{"(9223372036854775807 + 1) * 1.5", "1.3835058055282164e+19", object.FLOAT_OBJ},
+		{"float f = 9223372036854775807 + 1; f", "9.223372036854776e+18", object.FLOAT_OBJ},
+		// comparisons across all three
+		{"9223372036854775807 + 1 > 9223372036854775807", "true", object.BOOLEAN_OBJ},
+		{"9223372036854775807 < 9223372036854775807 + 1", "true", object.BOOLEAN_OBJ},
+		{"9223372036854775807 + 1 == 9223372036854775808.0", "true", object.BOOLEAN_OBJ},
+		{"(9223372036854775807 + 1) * 2 != 9223372036854775807 + 1", "true", object.BOOLEAN_OBJ},
+		{"1e30 > 9223372036854775807 * 2", "true", object.BOOLEAN_OBJ},
+		{"int x = 9223372036854775807 * 2; x", "18446744073709551614", object.BIGINT_OBJ},
+		{`let m = {9223372036854775807 + 1: "big"}; m[9223372036854775807 + 1]`, "big", object.STRING_OBJ},
+		{`"${9223372036854775807 + 1}"`, "9223372036854775808", object.STRING_OBJ},
+		{"(9223372036854775807 + 1) / 0", "ERROR: division by zero", object.ERROR_OBJ},
+		{"(9223372036854775807 + 1) % 0", "ERROR: division by zero", object.ERROR_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%s %q, got=%s %q",
+				tt.input, tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -182,6 +243,10 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"" != "a"`, true},
+		{`"né" != "né"`, false},
 	}
 
 	for _, tt := range tests {
@@ -202,6 +267,11 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else if (3 > 2) { 40 }", 40},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"let n = 0; if (true) { n = n + 1; } else if (true) { n = n + 10; } else { n = n + 100; }; n", 1},
 	}
 
 	for _, tt := range tests {
@@ -222,31 +292,31 @@ func TestErrorHandling(t *testing.T) {
 	}{
 		{
 			"5 + true;",
-			"type mismatch: INTEGER + BOOLEAN",
+			"type mismatch: int + bool",
 		},
 		{
 			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
+			"type mismatch: int + bool",
 		},
 		{
 			"-true",
-			"type mismatch: -BOOLEAN",
+			"type mismatch: -bool",
 		},
 		{
 			"true + false;",
-			"type mismatch: BOOLEAN + BOOLEAN",
+			"type mismatch: bool + bool",
 		},
 		{
 			"true + false + true + false;",
-			"type mismatch: BOOLEAN + BOOLEAN",
+			"type mismatch: bool + bool",
 		},
 		{
 			"5; true + false; 5",
-			"type mismatch: BOOLEAN + BOOLEAN",
+			"type mismatch: bool + bool",
 		},
 		{
 			"if (10 > 1) { true + false; }",
-			"type mismatch: BOOLEAN + BOOLEAN",
+			"type mismatch: bool + bool",
 		},
 		{
 			`
@@ -258,7 +328,7 @@ if (10 > 1) {
   return 1;
 }
 `,
-			"type mismatch: BOOLEAN + BOOLEAN",
+			"type mismatch: bool + bool",
 		},
 		{
 			"foobar",
@@ -295,7 +365,6 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
         {"let a = 9; a = 7; a", 7},
-        {"float a = 9; a = 7; a", 7}, // TODO: this shouldn't work... unless...
 	}
 
 	for _, tt := range tests {
@@ -303,7 +372,81 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; if (true) { x = 2; }; x", 2},
+		{"let total = 0; let add = fun(n) { total = total + n; }; add(2); add(3); total", 5},
+		{"let counter = fun() { let n = 0; fun() { n += 1; n } }; let c = counter(); c(); c(); c()", 3},
+		{"let x = 1; let f = fun() { let x = 5; x = 6; x }; f() + x", 7},
+		{"let x = 10; x -= 3; x *= 2; x /= 7; x", 2},
+		{"let x = 7; x %= 3; x", 1},
+		{"let i = 0; i++; i++; i--; i", 1},
+		{"let s = 0; for (let i = 0; i < 5; i++) { s += i; }; s", 10},
+		{"let xs = [1, 2]; xs[1] += 5; xs[1]", 7},
+		{`let m = {"a": 1}; m["a"]++; m["a"]`, 2},
+		{"float a = 9; a = 7; a", 7.0},
+		{"y = 5", "cannot assign to undeclared identifier: y"},
+		{"y += 1", "identifier not found: y"},
+		{"let f = fun() { z = 1 }; f()", "cannot assign to undeclared identifier: z"},
+		{"int a = 1; a = 2.5", "cannot assign float to int a"},
+		{`fun(int x) { x = "s" }(1)`, "cannot assign string to int x"},
+		{`let s = "a"; s++`, "type mismatch: string + int"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case float64:
+			testFloatObject(t, evaluated, expected, tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fun(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(1000000, 0)", 1000000},
+		{"let count = fun(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(100000)", 0},
+		{`let even = fun(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fun(n) { if (n == 0) { false } else { even(n - 1) } };
+even(100001)`, false},
+		{"let loop = fun(n) { while (true) { if (n == 0) { return 1; } return loop(n - 1); } }; loop(100000)", 1},
+		{"let size = fun(xs) { len(xs) }; size([1, 2, 3])", 3},
+		// the tail call still answers to the caller's return type
+		{"let g = fun() { 2 }; let f = fun() float { g() }; f()", 2.0},
+		{`let g = fun() { "s" }; let f = fun() int { g() }; f()`, "cannot return string from fun returning int"},
+		{"let g = fun() float { 1 }; let f = fun() int { g() }; f()", "cannot return float from fun returning int"},
+		{"let g = fun(x) { x }; let f = fun() { g() }; f()", "wrong number of arguments. want=1. got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case float64:
+			testFloatObject(t, evaluated, expected, tt.input)
+		case bool:
+			testBooleanObject(t, evaluated, expected, tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
+	if engine != "tree" {
+		t.Skip("the vm compiles functions, there is no body to look at")
+	}
 	input := "fun(x) { x + 2; };"
 
 	evaluated := testEval(input)
@@ -363,6 +506,38 @@ ourFunction(20) + first + second;`
 	testIntegerObject(t, testEval(input), 70, "It's one test")
 }
 
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let newAdder = fun(x) { fun(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{"let f = fun() { let x = 1; let g = fun() { x }; x = 2; g() }; f()", 2},
+		{"let f = fun() { let g = fun() { y }; let y = 3; g() }; f()", 3},
+		{"let f = fun() { let g = fun() { h() }; let h = fun() { 4 }; g() }; f()", 4},
+		{`let f = fun(n) {
+			let even = fun(n) { if (n == 0) { true } else { odd(n - 1) } };
+			let odd = fun(n) { if (n == 0) { false } else { even(n - 1) } };
+			even(n)
+		}; f(10)`, true},
+		{"let count = fun(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(5000)", 5000},
+		{"let f = fun() { g() }; let g = fun() { 6 }; f()", 6},
+		{"let f = fun() { nope }; f()", "identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case bool:
+			testBooleanObject(t, evaluated, expected, tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
     input := `"Hello World!"`
 
@@ -377,6 +552,33 @@ func TestStringLiteral(t *testing.T) {
     }
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = "Ryan"; let n = 2; "Hello ${user}, you have ${n + 1} items"`, "Hello Ryan, you have 3 items"},
+		{`"${[1, 2.5]} ${{"a": true}} ${if (false) { 1 }}"`, "[1, 2.5] {a: true} null"},
+		{`let f = fun(x) { "<${x}>" }; "${f(f(1))}"`, "<<1>>"},
+		{`"\${x}"`, "${x}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s | object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s | wrong value. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	// errors inside of ${ } come out as they are
+	testErrorObject(t, testEval(`"a ${1 / 0} b"`), "division by zero", "division in a string")
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -390,3 +592,676 @@ func TestStringConcatenation(t *testing.T) {
 		t.Errorf("String has wrong value. got=%q", str.Value)
 	}
 }
+
+func TestListLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.List)
+	if !ok {
+		t.Fatalf("object is not List. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("list has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1, input)
+	testIntegerObject(t, result.Elements[1], 4, input)
+	testIntegerObject(t, result.Elements[2], 6, input)
+}
+
+func TestListIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myList = [1, 2, 3]; myList[2];", 3},
+		{"let myList = [1, 2, 3]; myList[0] + myList[1] + myList[2];", 6},
+		{"let myList = [1, 2, 3]; let i = myList[0]; myList[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][3]", "index out of range: 3"},
+		{"[1, 2, 3][-4]", "index out of range: -4"},
+		{"[1, 2, 3][true]", "index operator not supported: list[bool]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][1:-1]", "[2, 3]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][0:100]", "[1, 2, 3, 4]"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[-1]`, "o"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`len("😀")`, "1"},
+		{`"héllo"[1]`, "é"},
+		{`"名前です"[-1]`, "す"},
+		{`"héllo"[1:4]`, "éll"},
+		{`"a😀b"[:2]`, "a😀"},
+		{`let out = []; for (c in "né😀") { out = out + [c] }; out`, "[n, é, 😀]"},
+		{`let café = 1; café + 1`, "2"},
+		{`bytes("aé")`, "[97, 195, 169]"},
+		{`runes("aé😀")`, "[97, 233, 128512]"},
+		{`len(bytes("héllo"))`, "6"},
+		{`bytes("")`, "[]"},
+		{`bytes(1)`, "ERROR: bytes operation only supported on strings"},
+		{`runes("a", "b")`, "ERROR: wrong number of arguments. want=1. got=2"},
+		{`"héllo"[5]`, "ERROR: index out of range: 5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestListConcatenation(t *testing.T) {
+	input := "let a = [1, 2]; let b = a + [3]; a + b"
+
+	evaluated := testEval(input)
+	if evaluated.Inspect() != "[1, 2, 1, 2, 3]" {
+		t.Errorf("wrong result. got=%q", evaluated.Inspect())
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([])`, 0},
+		{`len([1, 2, 3])`, 3},
+		{`len(1)`, "len operation only supported on strings, lists and maps"},
+		{`len("one", "two")`, "wrong number of arguments. want=1. got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		}
+	}
+}
+
+func TestAsserts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert(1 < 2)`, "null"},
+		{`assert(false)`, "AssertionError: assertion failed"},
+		{`assert(false, "nope")`, "AssertionError: nope: assertion failed"},
+		{`assert_eq(1 + 1, 2)`, "null"},
+		{`assert_eq(2, 2.0)`, "null"},
+		{`assert_eq("a", "a")`, "null"},
+		{`assert_eq([1, [2, "x"]], [1, [2, "x"]])`, "null"},
+		{`assert_eq({"a": 1, "b": [true]}, {"b": [true], "a": 1})`, "null"},
+		{`assert_eq(9223372036854775807 * 2, 18446744073709551614.0)`, "null"},
+		{`assert_eq(9223372036854775807 * 2, 9223372036854775807 * 3)`, "AssertionError: expected 27670116110564327421, got 18446744073709551614"},
+		{`assert_eq(1, "1")`, "AssertionError: expected \"1\", got 1"},
+		{`assert_eq([1, 2], [1, 3], "lists")`, "AssertionError: lists: expected [1, 3], got [1, 2]"},
+		{`assert_eq({"a": 1}, {"a": 2})`, "AssertionError: expected {a: 2}, got {a: 1}"},
+		{`assert_ne(1, 2)`, "null"},
+		{`assert_ne("a", "a")`, "AssertionError: expected something other than \"a\""},
+		{`let f = fun() { 1 }; assert_eq(f, f)`, "null"},
+		{`assert_eq(1)`, "ArgumentError: wrong number of arguments. want=2 or 3. got=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Describe()
+		}
+		if got != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string, test string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("%s | object is not Error. got=%T (%+v)", test, obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("%s | wrong error message. expected=%q, got=%q", test, expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func TestMapLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6,
+	1.5: 7
+}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Map)
+	if !ok {
+		t.Fatalf("Eval didn't return Map. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{evaluator.TRUE, 5},
+		{evaluator.FALSE, 6},
+		{&object.Float{Value: 1.5}, 7},
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Map has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for _, tt := range expected {
+		pair, ok := result.Get(tt.key)
+		if !ok {
+			t.Errorf("no pair for key %s", tt.key.(object.Object).Inspect())
+			continue
+		}
+
+		testIntegerObject(t, pair.Value, tt.value, input)
+	}
+}
+
+func TestMapIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		// == says 1 and 1.0 are equal, so they are the same key
+		{`{1: 5}[1.0]`, 5},
+		{`{1.0: 5}[1]`, 5},
+		{`{2: 5}[2.5]`, nil},
+		{`let m = {1: 2, 1.0: 3}; m[1] * 10 + len(m)`, 31},
+		{`{"foo": 5}[fun(x) { x }]`, "unusable as map key: fun"},
+		{`{[1]: 5}`, "unusable as map key: list"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case string:
+			testErrorObject(t, evaluated, expected, tt.input)
+		default:
+			testNullObject(t, evaluated, tt.input)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = {}; m["a"] = 1; m[2] = true; m`, "{a: 1, 2: true}"},
+		{`let m = {"a": 1}; m["a"] = m["a"] + 1; m`, "{a: 2}"},
+		{`let xs = [1, 2, 3]; xs[0] = 5; xs[-1] = 6; xs`, "[5, 2, 6]"},
+		{`let xs = [1]; xs[1] = 5;`, "ERROR: index out of range: 1"},
+		{`let s = "abc"; s[0] = "d";`, "ERROR: index assignment not supported: string[int]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMapBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({"b": 1, "a": 2})`, "[b, a]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`let m = {"a": 1, "b": 2}; delete(m, "a")`, "1"},
+		{`let m = {"a": 1, "b": 2}; delete(m, "a"); m`, "{b: 2}"},
+		{`delete({}, "a")`, "null"},
+		{`len({1: 1, 2: 2})`, "2"},
+		{`keys([1])`, "ERROR: keys operation only supported on maps"},
+		{`has({}, [])`, "ERROR: unusable as map key: list"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i = i + 1; }; i", 10},
+		{"let sum = 0; for (let i = 1; i < 101; i = i + 1) { sum = sum + i; }; sum", 5050},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum", 6},
+		{"let n = 0; for (k in {1: 1, 2: 2}) { n = n + k; }; n", 3},
+		{`let s = ""; for (c in "abc") { s = c + s; }; s`, "cba"},
+		{"let i = 0; while (true) { i = i + 1; if (i == 5) { break; } }; i", 5},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } n = n + x; }; n", 4},
+		{"let f = fun() { for (x in [1, 2, 3]) { if (x == 2) { return x; } } return 0; }; f()", 2},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } n = n + 1; } }; n", 2},
+		{"let i = 0; while (i < 3) { i = i + 1; }", nil},
+		{"for (x in 5) { }", "cannot iterate over int"},
+		{"while (true) { 1 + true; }", "type mismatch: int + bool"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected), tt.input)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%s | wrong string. expected=%q, got=%q", tt.input, expected, str.Value)
+				}
+			} else {
+				testErrorObject(t, evaluated, expected, tt.input)
+			}
+		default:
+			testNullObject(t, evaluated, tt.input)
+		}
+	}
+}
+
+func TestLongLoop(t *testing.T) {
+	input := `
+let count = 0;
+for (let i = 1; i < 200001; i = i + 1) {
+	if (i % 3 == 0 && i % 5 == 0) { count = count + 1; }
+}
+count`
+
+	testIntegerObject(t, testEval(input), 13333, input)
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "1:3"},
+		{"let x = 1;\nlet y = [1, 2];\ny[x + 5]", "3:2"},
+		{"let f = fun(x) {\n  return x + foo;\n};\nf(1)", "2:14"},
+		{"len(1)", "1:4"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q | no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expected {
+			t.Errorf("%q | wrong error position. expected=%s, got=%s",
+				tt.input, tt.expected, errObj.Pos)
+		}
+	}
+}
+
+func TestTypedDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int x = 5; x", "5"},
+		{"float x = 5; x / 2", "2.5"},
+		{"bool b = !true; b", "false"},
+		{`string s = "a" + "b"; s`, "ab"},
+		{"list xs = [1] + [2]; xs", "[1, 2]"},
+		{`map m = {"a": 1}; m`, "{a: 1}"},
+		{"fun f = len; f([1])", "1"},
+		{"int x = 7.8;", "ERROR: cannot assign float to int x"},
+		{"float y = true;", "ERROR: cannot assign bool to float y"},
+		{`list xs = "abc";`, "ERROR: cannot assign string to list xs"},
+		{"let f = fun(int x, float y) { y / x }; f(2, 1)", "0.5"},
+		{"let f = fun(int x) { x }; f(1.5)", "ERROR: cannot pass float as int x"},
+		{"let f = fun(x) int { x }; f(true)", "ERROR: cannot return bool from fun returning int"},
+		{"let f = fun(x) float { x }; f(1)", "1"},
+		{"let f = fun(x, y) { x }; f(1)", "ERROR: wrong number of arguments. want=2. got=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let r = ""; try { nope } catch (e) { r = e["kind"] + ": " + e["message"]; }; r`, "NameError: identifier not found: nope"},
+		{`let r = ""; try { int x = "a"; } catch (e) { r = e["kind"]; }; r`, "TypeError"},
+		{`let r = ""; try { [1][5] } catch (e) { r = e["kind"]; }; r`, "IndexError"},
+		{`let r = ""; try { 1 / 0 } catch (e) { r = e["kind"]; }; r`, "ZeroDivisionError"},
+		{`let r = ""; try { throw "boom"; } catch (e) { r = e["kind"] + ": " + e["message"]; }; r`, "Error: boom"},
+		{`let r = ""; try { throw error("ValueError", "bad"); } catch (e) { r = e; }; r`, "ValueError: bad"},
+		{"let r = 0; try {\n  nope\n} catch (e) { r = e[\"line\"] * 10 + e[\"column\"]; }; r", "23"},
+		{`let log = ""; try { log += "a"; throw "x"; log += "b"; } catch (e) { log += "c"; } finally { log += "d"; }; log`, "acd"},
+		{`let log = ""; try { log += "a"; } catch (e) { log += "c"; } finally { log += "d"; }; log`, "ad"},
+		{`let n = 0; let f = fun() { try { return 10; } finally { n += 1; } }; f() + n`, "11"},
+		{`let f = fun() { try { return 1; } finally { return 2; } }; f()`, "2"},
+		{`let n = 0; for (let i = 0; i < 5; i++) { try { if (i == 2) { break; } } finally { n += 1; } }; n`, "3"},
+		{`let n = 0; for (x in [1, 2, 3]) { try { continue; } finally { n += x; } }; n`, "6"},
+		{`let total = 0; for (x in [1, 2]) { try { total += x + nope; } catch (e) { total += x; } }; total`, "3"},
+		{`let f = fun() { g() }; let g = fun() { 1 / 0 }; let r = ""; try { f() } catch (e) { r = e["message"]; }; r`, "division by zero"},
+		{`let g = fun() { throw "deep"; }; let f = fun() { g() + 1 }; let t = []; try { f() } catch (e) { t = e["trace"]; }; t`, "[g called at 1:51, f called at 1:80]"},
+		// a tail call replaces f
+		{`let g = fun() { throw "deep"; }; let f = fun() { g() }; let t = []; try { f() } catch (e) { t = e["trace"]; }; t`, "[g called at 1:51]"},
+		{`let r = ""; try { try { throw "a"; } catch (e) { throw e; } } catch (outer) { r = outer["message"]; }; r`, "a"},
+		{`let n = 0; let f = fun() { try { nope } finally { n = 5; } }; try { f() } catch (e) {}; n`, "5"},
+		{`let log = ""; try { try { throw "a"; } catch (e) { throw "b"; } finally { log += "f"; } } catch (e) { log += e["message"]; }; log`, "fb"},
+		{`let f = fun() { for (x in [1, 2, 3]) { try { if (x == 2) { return x; } } catch (e) {} } }; f()`, "2"},
+		{`let f = fun() { try { return 1; } catch (e) { 2 } }; f(); nope`, "ERROR: identifier not found: nope"},
+		{`try { 1 } finally { nope }`, "ERROR: identifier not found: nope"},
+		{`throw "boom"`, "ERROR: boom"},
+		{`throw 1`, "ERROR: cannot throw int"},
+		{`5 % "2"`, "ERROR: type mismatch: int % string"},
+		{`1 % 0`, "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// The Errors example from the README, print and all
+// Jumps are 2 bytes in the vm, it has to refuse what it can't jump over
+// instead of landing in the wrong place
+func TestCodeTooLongForTheVM(t *testing.T) {
+	input := "let s = 0; let i = 3; if (true) {" + strings.Repeat(" s = s + i;", 70000) + " } s"
+	evaluated := testEval(input)
+
+	if engine == "tree" {
+		testIntegerObject(t, evaluated, 210000, "70000 additions")
+		return
+	}
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "too much code to jump over" {
+		t.Errorf("expected the vm to refuse the program. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+// A mistake reads the same whether the checker or the running program finds it
+func TestCheckerAndRuntimeErrorsMatch(t *testing.T) {
+	tests := []string{
+		`int a = 1; a = "s"`,
+		`float y = true;`,
+		`1 + true`,
+		`-"a"`,
+		`throw 1`,
+		`for (x in 5) { }`,
+		`[1, 2][true]`,
+	}
+
+	for _, input := range tests {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		errors := typechecker.New().Check(program)
+		if len(errors) != 1 {
+			t.Errorf("%s | expected 1 type error. got=%v", input, errors)
+			continue
+		}
+
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok {
+			t.Errorf("%s | expected a runtime error", input)
+			continue
+		}
+		if errObj.Message != errors[0].Message {
+			t.Errorf("%s | checker and runtime disagree.\nchecker=%q\nruntime=%q", input, errors[0].Message, errObj.Message)
+		}
+	}
+}
+
+func TestReadmeErrorExample(t *testing.T) {
+	input := `let parse = fun(s) {
+    if (s == "") {
+        throw error("ValueError", "empty input")
+    }
+    s
+}
+
+try {
+    parse("")
+} catch (e) {
+    print(e["kind"] + ": " + e["message"]) // ValueError: empty input
+} finally {
+    print("\n")
+}`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	if errors := typechecker.New().Check(program); len(errors) != 0 {
+		t.Fatalf("unexpected type errors: %v", errors)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	evaluated := testEval(input)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if _, ok := evaluated.(*object.Error); ok {
+		t.Fatalf("example failed: %s", evaluated.Inspect())
+	}
+	if string(out) != "ValueError: empty input\n" {
+		t.Errorf("wrong output. got=%q", out)
+	}
+}
+
+func TestUncaughtErrorTrace(t *testing.T) {
+	input := "let g = fun() {\n  1 / 0\n};\nlet f = fun() { g() + 1 };\nf()"
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if errObj.Kind != object.ZERO_DIVISION_ERROR || errObj.Pos.String() != "2:5" {
+		t.Errorf("wrong error. got=%s at %s", errObj.Describe(), errObj.Pos)
+	}
+
+	expected := []string{"g called at 4:18", "f called at 5:2"}
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong trace length. expected=%d, got=%d", len(expected), len(errObj.Trace))
+	}
+	for i, entry := range errObj.Trace {
+		if entry.String() != expected[i] {
+			t.Errorf("wrong trace entry %d. expected=%q, got=%q", i, expected[i], entry.String())
+		}
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	input := "let f = fun(n) { f(n + 1) + 1 };\nlet g = fun() { f(0) + 1 };\ng()"
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	if errObj.Describe() != "RecursionError: maximum recursion depth exceeded" || errObj.Pos.String() != "1:19" {
+		t.Errorf("wrong error. got=%s at %s", errObj.Describe(), errObj.Pos)
+	}
+	if len(errObj.Trace) != evaluator.MaxCallDepth {
+		t.Errorf("wrong trace length. expected=%d, got=%d", evaluator.MaxCallDepth, len(errObj.Trace))
+	}
+
+	defer func(max int) { evaluator.MaxCallDepth = max }(evaluator.MaxCallDepth)
+	evaluator.MaxCallDepth = 50
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fun(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(49)", "49"},
+		{"let f = fun(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)", "RecursionError: maximum recursion depth exceeded"},
+		{"let f = fun(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)", "0"},
+		{`let f = fun(n) { f(n + 1) + 1 }; let kind = ""; try { f(0) } catch (e) { kind = e["kind"]; }; kind`, "RecursionError"},
+		// the depth goes back down once the error is caught
+		{`let f = fun(n) { f(n + 1) + 1 }; try { f(0) } catch (e) { }; let h = fun(n) { if (n == 0) { 0 } else { 1 + h(n - 1) } }; h(49)`, "49"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Describe()
+		}
+		if got != tt.expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.lueder": `export let square = fun(x) { x * x };
+let hidden = 1;
+export let count = 0;
+export let bump = fun() { count += 1; count };
+export let shared = [0];`,
+		"lib/text.lueder":   `import "./helper" as helper; export let shout = fun(s) { helper.bang(s) };`,
+		"lib/helper.lueder": `export let bang = fun(s) { s + "!" };`,
+		"cycle_a.lueder":    `import "cycle_b" as b; export let a = 1;`,
+		"cycle_b.lueder":    `import "cycle_a" as a; export let b = 2;`,
+		"broken.lueder":     `export int x = 1.5;`,
+		"fails.lueder":      `export let f = fun() { nope };`,
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("LUEDERPATH", filepath.Join(dir, "lib"))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "DIR/math.lueder" as m; m.square(4)`, "16"},
+		{`import "DIR/math" as m; m["square"](3)`, "9"},
+		{`import "DIR/math" as m; m.bump(); m.bump(); m.count`, "2"},
+		{`import "DIR/math" as a; import "DIR/math.lueder" as b; a.shared[0] = 5; b.shared[0]`, "5"},
+		{`import "text" as text; text.shout("hi")`, "hi!"},
+		{`import "DIR/math" as m; m.hidden`, "ERROR: DIR/math.lueder has no export hidden"},
+		{`import "nope" as n; 1`, `ERROR: cannot find module "nope"`},
+		{`import "DIR/cycle_a" as a; 1`, "ERROR: import cycle: DIR/cycle_a.lueder -> DIR/cycle_b.lueder -> DIR/cycle_a.lueder"},
+		{`import "DIR/broken" as b; 1`, "ERROR: DIR/broken.lueder:1:16: cannot assign float to int x"},
+		{`import "DIR/fails" as f; let r = ""; try { f.f() } catch (e) { r = e.kind + " " + e.message; }; r`, "NameError identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "DIR", dir)
+		expected := strings.ReplaceAll(tt.expected, "DIR", dir)
+
+		evaluated := testEval(input)
+		if evaluated.Inspect() != expected {
+			t.Errorf("%s | wrong result. expected=%q, got=%q",
+				input, expected, evaluated.Inspect())
+		}
+	}
+}
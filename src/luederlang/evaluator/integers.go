@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+    "luederlang/object"
+    "math"
+    "math/big"
+)
+
+// Int math that doesn't wrap around. Integer results that overflow int64 come
+// back as a BigInt, and math with a BigInt comes back as an Integer whenever
+// the result fits again.
+
+func addIntegers(a, b int64) object.Object {
+    sum := a + b
+    if (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0) {
+        return normalizeBigInt(new(big.Int).Add(big.NewInt(a), big.NewInt(b)))
+    }
+    return &object.Integer{Value: sum}
+}
+
+func subtractIntegers(a, b int64) object.Object {
+    difference := a - b
+    if (a >= 0 && b < 0 && difference < 0) || (a < 0 && b > 0 && difference >= 0) {
+        return normalizeBigInt(new(big.Int).Sub(big.NewInt(a), big.NewInt(b)))
+    }
+    return &object.Integer{Value: difference}
+}
+
+func multiplyIntegers(a, b int64) object.Object {
+    if a == 0 || b == 0 {
+        return &object.Integer{Value: 0}
+    }
+    product := a * b
+    if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+        return normalizeBigInt(new(big.Int).Mul(big.NewInt(a), big.NewInt(b)))
+    }
+    return &object.Integer{Value: product}
+}
+
+// b is not 0, the caller reports that
+func divideIntegers(a, b int64) object.Object {
+    if a == math.MinInt64 && b == -1 {
+        return normalizeBigInt(new(big.Int).Neg(big.NewInt(a)))
+    }
+    return &object.Integer{Value: a / b}
+}
+
+// Takes the sign of b, so -3 % 5 is 2. b is not 0.
+func moduloIntegers(a, b int64) object.Object {
+    m := a % b
+    if m != 0 && (m < 0) != (b < 0) {
+        m += b
+    }
+    return &object.Integer{Value: m}
+}
+
+func negateInteger(a int64) object.Object {
+    if a == math.MinInt64 {
+        return normalizeBigInt(new(big.Int).Neg(big.NewInt(a)))
+    }
+    return &object.Integer{Value: -a}
+}
+
+// Math where at least one side is a BigInt. Next to an int both are BigInts,
+// next to a float the BigInt becomes a float like an int would.
+func evalBigIntInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+    // && and || take no numbers, the error names the BigInt and not its float
+    if operator == "&&" || operator == "||" {
+        return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
+    }
+    if left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ {
+        return evalInfixExpression(bigIntToFloat(left), operator, bigIntToFloat(right))
+    }
+    x, xOk := toBigInt(left)
+    y, yOk := toBigInt(right)
+    if !xOk || !yOk {
+        return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
+    }
+
+    switch operator {
+    case "+":
+        return normalizeBigInt(new(big.Int).Add(x, y))
+    case "-":
+        return normalizeBigInt(new(big.Int).Sub(x, y))
+    case "*":
+        return normalizeBigInt(new(big.Int).Mul(x, y))
+    case "/":
+        if y.Sign() == 0 {
+            return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+        }
+        // Quo truncates like int64 division does
+        return normalizeBigInt(new(big.Int).Quo(x, y))
+    case "%":
+        if y.Sign() == 0 {
+            return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+        }
+        m := new(big.Int).Rem(x, y)
+        if m.Sign() != 0 && m.Sign() != y.Sign() {
+            m.Add(m, y)
+        }
+        return normalizeBigInt(m)
+    case "<":
+        return nativeBoolToBooleanObject(x.Cmp(y) < 0)
+    case ">":
+        return nativeBoolToBooleanObject(x.Cmp(y) > 0)
+    case "==":
+        return nativeBoolToBooleanObject(x.Cmp(y) == 0)
+    case "!=":
+        return nativeBoolToBooleanObject(x.Cmp(y) != 0)
+    }
+    return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
+}
+
+// An Integer if n fits in one, a BigInt otherwise
+func normalizeBigInt(n *big.Int) object.Object {
+    if n.IsInt64() {
+        return &object.Integer{Value: n.Int64()}
+    }
+    return &object.BigInt{Value: n}
+}
+
+func toBigInt(obj object.Object) (*big.Int, bool) {
+    switch obj := obj.(type) {
+    case *object.Integer:
+        return big.NewInt(obj.Value), true
+    case *object.BigInt:
+        return obj.Value, true
+    }
+    return nil, false
+}
+
+// obj as a Float if it is a BigInt, anything else stays as it is
+func bigIntToFloat(obj object.Object) object.Object {
+    if b, ok := obj.(*object.BigInt); ok {
+        f, _ := new(big.Float).SetInt(b.Value).Float64()
+        return &object.Float{Value: f}
+    }
+    return obj
+}
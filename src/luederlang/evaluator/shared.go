@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+    "luederlang/object"
+    "luederlang/token"
+    "sort"
+)
+
+// The vm runs the same operations as the tree walker through these, so both
+// engines agree on every result and error message.
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+    return evalPrefixExpression(operator, right)
+}
+
+func EvalInfix(left object.Object, operator string, right object.Object) object.Object {
+    return evalInfixExpression(left, operator, right)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+    return evalIndexExpression(left, index)
+}
+
+// start and end are nil when the bound was left out
+func EvalSlice(left, start, end object.Object) object.Object {
+    return evalSliceExpression(left, start, end)
+}
+
+// Returns an error or nil
+func EvalIndexAssignment(left, index, val object.Object) object.Object {
+    return evalIndexAssignment(left, index, val)
+}
+
+func CoerceToType(typeName string, val object.Object) (object.Object, bool) {
+    return coerceToType(typeName, val)
+}
+
+func IterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+    return iterationItems(iterable)
+}
+
+// The error throw raises for val
+func Throw(val object.Object) *object.Error {
+    return throwValue(val)
+}
+
+func IsTruthy(obj object.Object) bool {
+    return isTruthy(obj)
+}
+
+func NativeBool(input bool) *object.Boolean {
+    return nativeBoolToBooleanObject(input)
+}
+
+// Builtin names in a fixed order, the compiler refers to builtins by index
+func BuiltinNames() []string {
+    names := make([]string, 0, len(builtins))
+    for name := range builtins {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Calls fn from outside of any program, like the test runner does
+func Call(fn object.Object, args ...object.Object) object.Object {
+    return applyFunction(fn, args, token.Position{})
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+    builtin, ok := builtins[name]
+    return builtin, ok
+}
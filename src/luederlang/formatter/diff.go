@@ -0,0 +1,131 @@
+package formatter
+
+import (
+    "fmt"
+    "strings"
+)
+
+const diffContext = 3
+
+// One line of a diff, ' ' kept, '-' removed or '+' added. a and b are how
+// many lines of each side come before it.
+type edit struct {
+    kind byte
+    line string
+    a, b int
+}
+
+// A unified diff from before to after with three lines of context, "" when
+// they are the same
+func Diff(name string, before string, after string) string {
+    if before == after {
+        return ""
+    }
+    edits := diffLines(splitLines(before), splitLines(after))
+
+    var out strings.Builder
+    fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+    for i := 0; i < len(edits); {
+        if edits[i].kind == ' ' {
+            i++
+            continue
+        }
+
+        // changes with at most twice the context between them share a hunk
+        start := max(i-diffContext, 0)
+        end := i
+        for end < len(edits) {
+            if edits[end].kind != ' ' {
+                end++
+                continue
+            }
+            same := end
+            for same < len(edits) && edits[same].kind == ' ' {
+                same++
+            }
+            if same == len(edits) || same-end > 2*diffContext {
+                end = min(end+diffContext, same)
+                break
+            }
+            end = same
+        }
+
+        writeHunk(&out, edits[start:end])
+        i = end
+    }
+    return out.String()
+}
+
+func writeHunk(out *strings.Builder, edits []edit) {
+    countA, countB := 0, 0
+    for _, e := range edits {
+        if e.kind != '+' {
+            countA++
+        }
+        if e.kind != '-' {
+            countB++
+        }
+    }
+    // an empty side starts at the line before it, like diff -u does
+    startA, startB := edits[0].a, edits[0].b
+    if countA > 0 {
+        startA++
+    }
+    if countB > 0 {
+        startB++
+    }
+
+    fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+    for _, e := range edits {
+        out.WriteByte(e.kind)
+        out.WriteString(e.line)
+        if !strings.HasSuffix(e.line, "\n") {
+            out.WriteString("\n\\ No newline at end of file\n")
+        }
+    }
+}
+
+// Lines with their \n, the last one might not have one
+func splitLines(s string) []string {
+    lines := strings.SplitAfter(s, "\n")
+    if lines[len(lines)-1] == "" {
+        lines = lines[:len(lines)-1]
+    }
+    return lines
+}
+
+// The edits that turn a into b, from their longest common subsequence
+func diffLines(a []string, b []string) []edit {
+    // lcs[i][j] is how long the longest common subsequence of a[i:] and b[j:] is
+    lcs := make([][]int, len(a)+1)
+    for i := range lcs {
+        lcs[i] = make([]int, len(b)+1)
+    }
+    for i := len(a) - 1; i >= 0; i-- {
+        for j := len(b) - 1; j >= 0; j-- {
+            if a[i] == b[j] {
+                lcs[i][j] = lcs[i+1][j+1] + 1
+            } else {
+                lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+            }
+        }
+    }
+
+    edits := []edit{}
+    i, j := 0, 0
+    for i < len(a) || j < len(b) {
+        switch {
+        case i < len(a) && j < len(b) && a[i] == b[j]:
+            edits = append(edits, edit{' ', a[i], i, j})
+            i++
+            j++
+        case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+            edits = append(edits, edit{'-', a[i], i, j})
+            i++
+        default:
+            edits = append(edits, edit{'+', b[j], i, j})
+            j++
+        }
+    }
+    return edits
+}
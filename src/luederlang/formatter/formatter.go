@@ -0,0 +1,689 @@
+package formatter
+
+import (
+    "fmt"
+    "io/fs"
+    "luederlang/ast"
+    "luederlang/lexer"
+    "luederlang/parser"
+    "luederlang/token"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Prints a program in the one layout every file should have: four space
+// indents, one statement per line, a ; after every simple statement and only
+// the parentheses the precedence needs. Comments stay where they were and
+// blank lines between statements are kept, but never more than one.
+//
+// Some choices are left to the source. Lists and maps that span lines get one
+// element per line, and function literals and ifs that fit on one line with
+// at most one statement per block stay on one line.
+func Format(filename string, input string) (string, []*parser.ParseError) {
+    p := parser.New(lexer.NewFile(filename, input))
+    program := p.ParseProgram()
+    if len(p.ErrorList()) != 0 {
+        return "", p.ErrorList()
+    }
+
+    pr := &printer{first: true, lineStart: true}
+    l := lexer.NewWithComments(input)
+    for {
+        tok := l.NextToken()
+        if tok.Type == token.COMMENT {
+            pr.comments = append(pr.comments, len(pr.tokens))
+        }
+        pr.tokens = append(pr.tokens, tok)
+        if tok.Type == token.EOF {
+            break
+        }
+    }
+
+    pr.statements(program.Statements, len(pr.tokens)-1)
+    pr.flush(len(pr.tokens))
+    return pr.out.String(), nil
+}
+
+const indent = "    "
+
+type printer struct {
+    // every token of the source, comments included, to find out where nodes
+    // end and which comments go between them
+    tokens []token.Token
+    // indices into tokens of the comments that weren't printed yet
+    comments []int
+
+    out       strings.Builder
+    indent    int
+    lineStart bool // nothing is on the current output line yet
+    // the source line of whatever was printed last, a gap to the next thing
+    // becomes a blank line unless that thing comes first in its block
+    lastLine int
+    first    bool
+}
+
+func (p *printer) write(s string) {
+    if p.lineStart {
+        p.out.WriteString(strings.Repeat(indent, p.indent))
+        p.lineStart = false
+    }
+    p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+    p.out.WriteString("\n")
+    p.lineStart = true
+}
+
+// A blank line if the source had one before line
+func (p *printer) gap(line int) {
+    if !p.first && line > p.lastLine+1 {
+        p.out.WriteString("\n")
+    }
+    p.first = false
+}
+
+// The index of the token at pos
+func (p *printer) index(pos token.Position) int {
+    return sort.Search(len(p.tokens), func(i int) bool {
+        at := p.tokens[i].Pos
+        return at.Line > pos.Line || (at.Line == pos.Line && at.Column >= pos.Column)
+    })
+}
+
+// Where a statement or element really starts, parentheses around it included
+func (p *printer) start(node ast.Node) int {
+    i := p.index(ast.Start(node))
+    for i > 0 && p.tokens[i-1].Type == token.LPAREN {
+        i--
+    }
+    return i
+}
+
+// The bracket that closes the one at open
+func (p *printer) closing(open int) int {
+    depth := 0
+    for i := open; i < len(p.tokens); i++ {
+        switch p.tokens[i].Type {
+        case token.LPAREN, token.LBRACKET, token.LBRACE:
+            depth++
+        case token.RPAREN, token.RBRACKET, token.RBRACE:
+            depth--
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+    return len(p.tokens) - 1
+}
+
+// The line token i ends on, strings can span several
+func (p *printer) line(i int) int {
+    return p.tokens[i].Pos.Line + strings.Count(p.tokens[i].Literal, "\n")
+}
+
+func (p *printer) commentBefore(i int) bool {
+    return len(p.comments) > 0 && p.comments[0] < i
+}
+
+// Prints the comments in front of token before, each on its own line
+func (p *printer) flush(before int) {
+    for p.commentBefore(before) {
+        c := p.tokens[p.comments[0]]
+        p.comments = p.comments[1:]
+        if !p.lineStart {
+            p.newline()
+        }
+        p.gap(c.Pos.Line)
+        p.write(c.Literal)
+        p.newline()
+        p.lastLine = c.Pos.Line
+    }
+}
+
+// Ends the line of something that ends right before token next, taking along
+// a comment that was on the same line in the source
+func (p *printer) endLine(next int) {
+    end := next - 1
+    for end > 0 && p.tokens[end].Type == token.COMMENT {
+        end--
+    }
+    for k, c := range p.comments {
+        if c > end && c < next && p.line(c) == p.line(end) {
+            p.write(" " + p.tokens[c].Literal)
+            p.comments = append(p.comments[:k], p.comments[k+1:]...)
+            break
+        }
+    }
+    p.newline()
+    p.lastLine = p.line(end)
+}
+
+// A comment right after an opening bracket stays on its line
+func (p *printer) afterOpen(open int) {
+    if len(p.comments) > 0 && p.comments[0] == open+1 && p.line(open+1) == p.line(open) {
+        p.write(" " + p.tokens[open+1].Literal)
+        p.comments = p.comments[1:]
+    }
+}
+
+// Prints items one per line, with the comments and blank lines around them.
+// close is the token after the last one.
+func (p *printer) lines(starts []int, close int, item func(i int)) {
+    for i, start := range starts {
+        p.flush(start)
+        p.gap(p.tokens[start].Pos.Line)
+        item(i)
+        next := close
+        if i+1 < len(starts) {
+            next = starts[i+1]
+        }
+        p.endLine(next)
+    }
+    p.flush(close)
+}
+
+// Opens a bracket whose contents go on their own lines
+func (p *printer) open(bracket string, open int) {
+    p.write(bracket)
+    p.afterOpen(open)
+    p.newline()
+    p.indent++
+    p.first = true
+    p.lastLine = p.line(open)
+}
+
+func (p *printer) close(bracket string) {
+    p.indent--
+    p.write(bracket)
+    p.first = false
+}
+
+func (p *printer) statements(statements []ast.Statement, close int) {
+    starts := []int{}
+    for _, s := range statements {
+        starts = append(starts, p.start(s))
+    }
+    p.lines(starts, close, func(i int) {
+        var next ast.Statement
+        if i+1 < len(statements) {
+            next = statements[i+1]
+        }
+        p.statement(statements[i], next)
+    })
+}
+
+func (p *printer) statement(s ast.Statement, next ast.Statement) {
+    switch s := s.(type) {
+    case *ast.ExpressionStatement:
+        p.expression(s.Expression)
+        // the } of an if ends it, unless the next statement would continue it
+        if _, ok := s.Expression.(*ast.IfExpression); !ok || continues(next) {
+            p.write(";")
+        }
+    case *ast.BlockStatement:
+        p.block(s)
+    case *ast.WhileStatement:
+        p.write("while (")
+        p.expression(s.Condition)
+        p.write(") ")
+        p.block(s.Body)
+    case *ast.ForStatement:
+        p.write("for (")
+        if s.Init != nil {
+            p.simple(s.Init)
+        }
+        p.write(";")
+        if s.Condition != nil {
+            p.write(" ")
+            p.expression(s.Condition)
+        }
+        p.write(";")
+        if s.Post != nil {
+            p.write(" ")
+            p.simple(s.Post)
+        }
+        p.write(") ")
+        p.block(s.Body)
+    case *ast.ForInStatement:
+        p.write("for (" + s.Variable.Value + " in ")
+        p.expression(s.Iterable)
+        p.write(") ")
+        p.block(s.Body)
+    case *ast.TryStatement:
+        p.write("try ")
+        p.block(s.Body)
+        if s.Catch != nil {
+            p.write(" catch (" + s.CatchVariable.Value + ") ")
+            p.block(s.Catch)
+        }
+        if s.Finally != nil {
+            p.write(" finally ")
+            p.block(s.Finally)
+        }
+    default:
+        p.simple(s)
+        p.write(";")
+    }
+}
+
+// Loops, try and blocks are the statements that can't go on one line
+func isSimple(s ast.Statement) bool {
+    switch s.(type) {
+    case *ast.BlockStatement, *ast.WhileStatement, *ast.ForStatement,
+        *ast.ForInStatement, *ast.TryStatement:
+        return false
+    }
+    return true
+}
+
+// A simple statement without its ;
+func (p *printer) simple(s ast.Statement) {
+    if name, value, typeName, ok := ast.DeclarationOf(s); ok {
+        if typeName == "" {
+            typeName = "let"
+        }
+        p.write(typeName + " " + name.Value + " = ")
+        p.expression(value)
+        return
+    }
+
+    switch s := s.(type) {
+    case *ast.ExpressionStatement:
+        p.expression(s.Expression)
+    case *ast.AssignStatement:
+        p.write(s.Name.Value)
+        p.assignment(s.Operator, s.Value)
+    case *ast.IndexAssignStatement:
+        p.expression(s.Target)
+        p.assignment(s.Operator, s.Value)
+    case *ast.ReturnStatement:
+        p.write("return ")
+        p.expression(s.ReturnValue)
+    case *ast.ThrowStatement:
+        p.write("throw ")
+        p.expression(s.Value)
+    case *ast.ImportStatement:
+        p.write("import " + quote(s.Path) + " as " + s.Name.Value)
+    case *ast.ExportStatement:
+        p.write("export ")
+        p.simple(s.Statement)
+    case *ast.BreakStatement, *ast.ContinueStatement:
+        p.write(s.TokenLiteral())
+    }
+}
+
+func (p *printer) assignment(operator string, value ast.Expression) {
+    if value == nil {
+        p.write(operator)
+        return
+    }
+    p.write(" " + operator + " ")
+    p.expression(value)
+}
+
+// Whether s would be read as more of an if statement in front of it, like
+// the call in if (a) { f } (x)
+func continues(s ast.Statement) bool {
+    var e ast.Expression
+    switch s := s.(type) {
+    case *ast.ExpressionStatement:
+        e = s.Expression
+    case *ast.IndexAssignStatement:
+        e = s.Target
+    default:
+        return false
+    }
+    switch leading(e) {
+    case '(', '[', '-':
+        return true
+    }
+    return false
+}
+
+// The first character e prints as, 0 when it doesn't matter
+func leading(e ast.Expression) byte {
+    var left ast.Expression
+    min := parser.CALL
+    switch e := e.(type) {
+    case *ast.InfixExpression:
+        left, min = e.Left, precedence(e)
+    case *ast.CallExpression:
+        left = e.Function
+    case *ast.IndexExpression:
+        left = e.Left
+    case *ast.SliceExpression:
+        left = e.Left
+    case *ast.PrefixExpression:
+        return e.Operator[0]
+    case *ast.ListLiteral:
+        return '['
+    default:
+        return 0
+    }
+    if precedence(left) < min {
+        return '('
+    }
+    return leading(left)
+}
+
+// How tightly e holds together, it needs parentheses where something tighter
+// is expected
+func precedence(e ast.Expression) int {
+    switch e := e.(type) {
+    case *ast.InfixExpression:
+        return parser.Precedence(e.Token.Type)
+    case *ast.PrefixExpression:
+        return parser.PREFIX
+    case *ast.CallExpression:
+        return parser.CALL
+    case *ast.IndexExpression, *ast.SliceExpression:
+        return parser.INDEX
+    }
+    return parser.INDEX + 1
+}
+
+// Prints e, in parentheses if it holds together less than min
+func (p *printer) operand(e ast.Expression, min int) {
+    if precedence(e) < min {
+        p.write("(")
+        p.expression(e)
+        p.write(")")
+        return
+    }
+    p.expression(e)
+}
+
+func (p *printer) expression(e ast.Expression) {
+    switch e := e.(type) {
+    case *ast.Identifier:
+        p.write(e.Value)
+    case *ast.Boolean, *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.FloatLiteral:
+        p.write(e.TokenLiteral())
+    case *ast.StringLiteral:
+        if tok := p.tokens[p.index(e.Pos())]; tok.Type == token.STRING_LITERAL {
+            p.write(stringSource(tok.Literal, !strings.HasPrefix(tok.Literal, `"`) || strings.HasPrefix(tok.Literal, `"""`)))
+        } else {
+            p.write(quote(e.Value))
+        }
+    case *ast.InterpolatedString:
+        multiline := strings.HasPrefix(p.tokens[p.index(e.Pos())].Literal, `"""`)
+        for i, part := range e.Parts {
+            if i%2 == 1 {
+                p.expression(part)
+            } else {
+                p.write(stringSource(p.tokens[p.index(part.Pos())].Literal, multiline))
+            }
+        }
+    case *ast.PrefixExpression:
+        p.write(e.Operator)
+        // --x would be a decrement
+        if right, ok := e.Right.(*ast.PrefixExpression); ok && right.Operator == "-" && e.Operator == "-" {
+            p.write("(")
+            p.expression(right)
+            p.write(")")
+            return
+        }
+        p.operand(e.Right, parser.PREFIX)
+    case *ast.InfixExpression:
+        // everything is left associative, a - (b - c) keeps its parentheses
+        p.operand(e.Left, precedence(e))
+        p.write(" " + e.Operator + " ")
+        p.operand(e.Right, precedence(e)+1)
+    case *ast.CallExpression:
+        p.operand(e.Function, parser.CALL)
+        p.write("(")
+        for i, arg := range e.Arguments {
+            if i > 0 {
+                p.write(", ")
+            }
+            p.expression(arg)
+        }
+        p.write(")")
+    case *ast.IndexExpression:
+        p.operand(e.Left, parser.CALL)
+        if e.IsMember() {
+            p.write("." + e.Index.(*ast.StringLiteral).Value)
+            return
+        }
+        p.write("[")
+        p.expression(e.Index)
+        p.write("]")
+    case *ast.SliceExpression:
+        p.operand(e.Left, parser.CALL)
+        p.write("[")
+        if e.Start != nil {
+            p.expression(e.Start)
+        }
+        p.write(":")
+        if e.End != nil {
+            p.expression(e.End)
+        }
+        p.write("]")
+    case *ast.ListLiteral:
+        p.list(e)
+    case *ast.MapLiteral:
+        p.hash(e)
+    case *ast.FunctionLiteral:
+        p.function(e)
+    case *ast.IfExpression:
+        p.ifExpression(e, p.fitsOnLine(e.Token.Pos, ifBlocks(e)...))
+    }
+}
+
+func (p *printer) list(list *ast.ListLiteral) {
+    open := p.index(list.Pos())
+    close := p.closing(open)
+    if p.line(open) == p.line(close) || (len(list.Elements) == 0 && !p.commentBefore(close)) {
+        p.write("[")
+        for i, el := range list.Elements {
+            if i > 0 {
+                p.write(", ")
+            }
+            p.expression(el)
+        }
+        p.write("]")
+        return
+    }
+
+    starts := []int{}
+    for _, el := range list.Elements {
+        starts = append(starts, p.start(el))
+    }
+    p.open("[", open)
+    p.lines(starts, close, func(i int) {
+        p.expression(list.Elements[i])
+        if i+1 < len(list.Elements) {
+            p.write(",")
+        }
+    })
+    p.close("]")
+}
+
+func (p *printer) hash(m *ast.MapLiteral) {
+    open := p.index(m.Pos())
+    close := p.closing(open)
+    pair := func(i int) {
+        p.expression(m.Keys[i])
+        p.write(": ")
+        p.expression(m.Values[i])
+    }
+    if p.line(open) == p.line(close) || (len(m.Keys) == 0 && !p.commentBefore(close)) {
+        p.write("{")
+        for i := range m.Keys {
+            if i > 0 {
+                p.write(", ")
+            }
+            pair(i)
+        }
+        p.write("}")
+        return
+    }
+
+    starts := []int{}
+    for _, key := range m.Keys {
+        starts = append(starts, p.start(key))
+    }
+    p.open("{", open)
+    p.lines(starts, close, func(i int) {
+        pair(i)
+        if i+1 < len(m.Keys) {
+            p.write(",")
+        }
+    })
+    p.close("}")
+}
+
+func (p *printer) function(fl *ast.FunctionLiteral) {
+    params := []string{}
+    for i, param := range fl.Parameters {
+        if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+            params = append(params, fl.ParameterTypes[i].Value+" "+param.Value)
+        } else {
+            params = append(params, param.Value)
+        }
+    }
+    p.write("fun(" + strings.Join(params, ", ") + ") ")
+    if fl.ReturnType != nil {
+        p.write(fl.ReturnType.Value + " ")
+    }
+
+    if p.fitsOnLine(fl.Token.Pos, fl.Body) {
+        p.inlineBlock(fl.Body)
+    } else {
+        p.block(fl.Body)
+    }
+}
+
+func (p *printer) ifExpression(ie *ast.IfExpression, inline bool) {
+    body := p.block
+    if inline {
+        body = p.inlineBlock
+    }
+
+    p.write("if (")
+    p.expression(ie.Condition)
+    p.write(") ")
+    body(ie.Consequence)
+    if ie.ElseIf != nil {
+        p.write(" else ")
+        p.ifExpression(ie.ElseIf, inline)
+    } else if ie.Alternative != nil {
+        p.write(" else ")
+        body(ie.Alternative)
+    }
+}
+
+// The blocks of an if and all of its else branches
+func ifBlocks(ie *ast.IfExpression) []*ast.BlockStatement {
+    blocks := []*ast.BlockStatement{ie.Consequence}
+    if ie.ElseIf != nil {
+        return append(blocks, ifBlocks(ie.ElseIf)...)
+    }
+    if ie.Alternative != nil {
+        blocks = append(blocks, ie.Alternative)
+    }
+    return blocks
+}
+
+// Whether something starting at start and ending with blocks was written on
+// one line, with at most one simple statement in each block
+func (p *printer) fitsOnLine(start token.Position, blocks ...*ast.BlockStatement) bool {
+    for _, b := range blocks {
+        if len(b.Statements) > 1 || (len(b.Statements) == 1 && !isSimple(b.Statements[0])) {
+            return false
+        }
+    }
+    last := blocks[len(blocks)-1]
+    return p.line(p.closing(p.index(last.Pos()))) == start.Line
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+    open := p.index(b.Pos())
+    close := p.closing(open)
+    if len(b.Statements) == 0 && !p.commentBefore(close) {
+        p.write("{}")
+        return
+    }
+
+    p.open("{", open)
+    p.statements(b.Statements, close)
+    p.close("}")
+}
+
+// { x } for a block that fitsOnLine
+func (p *printer) inlineBlock(b *ast.BlockStatement) {
+    if len(b.Statements) == 0 {
+        p.write("{}")
+        return
+    }
+    p.write("{ ")
+    p.simple(b.Statements[0])
+    p.write(" }")
+}
+
+// A string or a piece of one as written, escapes stay as they are. Only "..."
+// strings get their line breaks turned into \n, raw and triple quoted ones
+// are multiline and stay what they are.
+func stringSource(literal string, multiline bool) string {
+    if multiline {
+        return literal
+    }
+    return strings.ReplaceAll(literal, "\n", "\\n")
+}
+
+// s as a "..." literal
+func quote(s string) string {
+    var out strings.Builder
+    out.WriteByte('"')
+    for i := 0; i < len(s); i++ {
+        switch c := s[i]; c {
+        case '"', '\\':
+            out.WriteString("\\" + string(c))
+        case '\n':
+            out.WriteString("\\n")
+        case '\t':
+            out.WriteString("\\t")
+        case '\r':
+            out.WriteString("\\r")
+        default:
+            if c < ' ' || c == 0x7f {
+                fmt.Fprintf(&out, "\\x%02x", c)
+            } else {
+                out.WriteByte(c)
+            }
+        }
+    }
+    out.WriteByte('"')
+    return out.String()
+}
+
+// The .lueder files in paths, directories are searched all the way down
+func Files(paths []string) ([]string, error) {
+    files := []string{}
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil {
+            return nil, err
+        }
+        if !info.IsDir() {
+            files = append(files, path)
+            continue
+        }
+        err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+            if err != nil {
+                return err
+            }
+            if !d.IsDir() && strings.HasSuffix(file, ".lueder") {
+                files = append(files, file)
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return files, nil
+}
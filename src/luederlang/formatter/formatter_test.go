@@ -0,0 +1,183 @@
+package formatter
+
+import (
+	"luederlang/lexer"
+	"luederlang/parser"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"int y = ((1 + 2)) * 3;", "int y = (1 + 2) * 3;\n"},
+		{"a - (b - c); (a - b) - c; a * (b + c) || d && e", "a - (b - c);\na - b - c;\na * (b + c) || d && e;\n"},
+		{"-(-x); -(x + 1); (-x)[0]; f(1)(2)[3]; !(a == b)", "-(-x);\n-(x + 1);\n(-x)[0];\nf(1)(2)[3];\n!(a == b);\n"},
+		{"x+=1\ny++\nxs[0]=2\nlib.f(xs[1:], xs[:2])", "x += 1;\ny++;\nxs[0] = 2;\nlib.f(xs[1:], xs[:2]);\n"},
+		{"let s = \"a\nb\"; import \"lib\" as lib", "let s = \"a\\nb\";\nimport \"lib\" as lib;\n"},
+		{"\"a ${ x+1 } \\${b}\n\"; \"\"\"\n${ f(\"${y}\") }\n\"\"\"", "\"a ${x + 1} \\${b}\\n\";\n\"\"\"\n${f(\"${y}\")}\n\"\"\";\n"},
+		// strings keep their escapes and quotes, in blocks too
+		{
+			"let s = \"\\\"\\u{e9}\\x41\"; let f = fun() {\nlet t = \"\"\"\n  a\n\"\"\"\nlet r = `C:\\dir`\n}",
+			"let s = \"\\\"\\u{e9}\\x41\";\nlet f = fun() {\n    let t = \"\"\"\n  a\n\"\"\";\n    let r = `C:\\dir`;\n};\n",
+		},
+		{"export fun sq = fun(int x) int { x * x }", "export fun sq = fun(int x) int { x * x };\n"},
+		{
+			"let f = fun(x) {\nlet y = x\n\t\ty\n}",
+			"let f = fun(x) {\n    let y = x;\n    y;\n};\n",
+		},
+		{
+			"while (x) { x-- } for (let i = 0; i < 3; i++) {} for (;;) { break } for (v in xs) { print(v) }",
+			"while (x) {\n    x--;\n}\nfor (let i = 0; i < 3; i++) {}\nfor (;;) {\n    break;\n}\nfor (v in xs) {\n    print(v);\n}\n",
+		},
+		{
+			"try { throw \"x\" } catch (e) { print(e) } finally { print(1) }",
+			"try {\n    throw \"x\";\n} catch (e) {\n    print(e);\n} finally {\n    print(1);\n}\n",
+		},
+		{
+			"if (a) { 1 } else if (b) { 2 } else { 3 }\nif (a) {\n1 }",
+			"if (a) { 1 } else if (b) { 2 } else { 3 }\nif (a) {\n    1;\n}\n",
+		},
+		// the ; keeps the second line from calling the if
+		{"if (a) { f }; (x + 1) * 2\nif (a) { f }; -1\nif (a) { f }; (x)", "if (a) { f };\n(x + 1) * 2;\nif (a) { f };\n-1;\nif (a) { f }\nx;\n"},
+		{"let xs = [1,\n2]; let m = {\"a\": 1,\n\"b\": 2,}; let e = []", "let xs = [\n    1,\n    2\n];\nlet m = {\n    \"a\": 1,\n    \"b\": 2\n};\nlet e = [];\n"},
+		{"{\nlet x = 1\n}", "{\n    let x = 1;\n}\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		output, errs := Format("", tt.input)
+		if len(errs) != 0 {
+			t.Errorf("Format(%q) failed: %s", tt.input, errs[0])
+			continue
+		}
+		if output != tt.expected {
+			t.Errorf("Format(%q) wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `// header
+
+
+let x = 1 // one
+// about f
+
+let f = fun(x) { // the function
+	// inside
+
+
+	let ys = [1, // first
+	2]
+	ys // last
+	// before the }
+}
+
+// the end`
+
+	expected := `// header
+
+let x = 1; // one
+// about f
+
+let f = fun(x) { // the function
+    // inside
+
+    let ys = [
+        1, // first
+        2
+    ];
+    ys; // last
+    // before the }
+};
+
+// the end
+`
+
+	output, errs := Format("", input)
+	if len(errs) != 0 {
+		t.Fatalf("Format failed: %s", errs[0])
+	}
+	if output != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+// Formatting again changes nothing and the program still means the same
+func TestFormatIsStable(t *testing.T) {
+	inputs := []string{
+		"let x = 1+2 // c\nint y=3\n\n\n\nprint(x,y)",
+		"let g = fun(x) {\n  // inside\n\tif (x > 1) { return x } else { return -(-x) }\n\n\n  let xs = [1,\n    2, // two\n    3]\n  xs[0] = {\"a\": fun() { 2 }}[\"a\"]()\n}",
+		"for (let i = 0; i < 3; i++) { // loop\n// body\ncontinue }\n{\n  { let inner = 1 } }",
+		"try {\nthrow error(\"E\", \"m\")\n} catch (e) { print(e[\"kind\"]) }\nif (a) {\n} else if (b) {\nc }\n(1 + 2) * 3",
+	}
+
+	for _, input := range inputs {
+		first, errs := Format("", input)
+		if len(errs) != 0 {
+			t.Errorf("Format(%q) failed: %s", input, errs[0])
+			continue
+		}
+		second, errs := Format("", first)
+		if len(errs) != 0 {
+			t.Errorf("formatted output of %q does not parse: %s\n%s", input, errs[0], first)
+			continue
+		}
+		if second != first {
+			t.Errorf("formatting twice changed the output.\nfirst:\n%s\nsecond:\n%s", first, second)
+		}
+		if parse(first) != parse(input) {
+			t.Errorf("formatting changed the program.\nbefore: %s\nafter:  %s", parse(input), parse(first))
+		}
+		if strings.Count(first, "//") != strings.Count(input, "//") {
+			t.Errorf("comments got lost:\n%s", first)
+		}
+	}
+}
+
+func parse(input string) string {
+	return parser.New(lexer.New(input)).ParseProgram().String()
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, errs := Format("bad.lueder", "let = 1")
+	if len(errs) == 0 {
+		t.Fatalf("expected a parse error")
+	}
+	if errs[0].Pos.Filename != "bad.lueder" {
+		t.Errorf("error has the wrong file. got=%s", errs[0].Pos)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		before   string
+		after    string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"1\n2\n3\n4\n5\nx\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\n5\ny\n6\n7\n8\n9\n",
+			"--- f\n+++ f\n@@ -3,7 +3,7 @@\n 3\n 4\n 5\n-x\n+y\n 6\n 7\n 8\n",
+		},
+		{
+			"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			"A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n",
+			"--- f\n+++ f\n@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n@@ -7,4 +7,4 @@\n g\n h\n i\n-j\n+J\n",
+		},
+		{"", "a\n", "--- f\n+++ f\n@@ -0,0 +1,1 @@\n+a\n"},
+		{"a", "a\n", "--- f\n+++ f\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+	}
+
+	for _, tt := range tests {
+		diff := Diff("f", tt.before, tt.after)
+		if diff != tt.expected {
+			t.Errorf("Diff(%q, %q) wrong.\nexpected=%q\ngot=     %q", tt.before, tt.after, tt.expected, diff)
+		}
+	}
+}
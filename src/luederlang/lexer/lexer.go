@@ -1,19 +1,70 @@
 package lexer
 
 import (
+    "fmt"
 	"luederlang/token"
+    "strconv"
     "strings"
+    "unicode"
+    "unicode/utf8"
 )
 
+// Something wrong with a literal, like a bad escape. The lexer still makes a
+// token for it and keeps going, the parser reports these with its own errors.
+type Error struct {
+    Pos     token.Position
+    Message string
+    // the literal runs to the end of the input, the REPL waits for more
+    Unterminated bool
+}
+
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+    // where l.ch is in the input, for token positions. Columns count bytes
+    // like Position says, so a wide char moves the next one over by more.
+    filename string
+    line     int
+    column   int
+
+    // hand out // comments as COMMENT tokens instead of skipping them, and
+    // string literals as they are written
+    keepComments bool
+
+    errors []*Error
+    // the innermost string whose ${ } l.ch is in, nil outside of all of them
+    interpolation *interpolation
+}
+
+// Never changed once made, so copies of a Lexer can lex ahead on their own
+type interpolation struct {
+    pos    token.Position // where the string starts
+    open   token.Position // where the ${ is
+    triple bool
+    // how many { are open inside of the ${ }
+    depth int
+    outer *interpolation
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// Like New, but comments come out as COMMENT tokens too and string literals
+// keep their quotes and escapes. The parser can't handle those, this is for
+// tools like the formatter that need every byte.
+func NewWithComments(input string) *Lexer {
+    l := New(input)
+    l.keepComments = true
+    return l
+}
+
+// Like New, but every token position also records the file it came from
+func NewFile(filename string, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
@@ -22,6 +73,8 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.eatWhitespace()
+    pos := l.curPosition()
+    start := l.position
 
 	switch l.ch {
     case '&':
@@ -30,6 +83,8 @@ func (l *Lexer) NextToken() token.Token {
             l.readChar()
             literal := string(ch) + string(l.ch)
             tok = token.Token{Type: token.LAND, Literal: literal}
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
         }
 
     case '|':
@@ -37,13 +92,16 @@ func (l *Lexer) NextToken() token.Token {
             ch := l.ch
             l.readChar()
             literal := string(ch) + string(l.ch)
-            tok = token.Token{Type: token.LAND, Literal: literal}
+            tok = token.Token{Type: token.LOR, Literal: literal}
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
         }
 
 	case '"':
-        tok.Type = token.STRING_LITERAL
-        tok.Literal = l.readString()
-		// TODO: not sure
+        tok = l.readString(pos)
+
+    case '`':
+        tok = token.Token{Type: token.STRING_LITERAL, Literal: l.readRawString(pos)}
 
 	case '=':
 		if l.peekChar() == '=' {
@@ -56,10 +114,24 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+        switch l.peekChar() {
+        case '=':
+            tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+        case '+':
+            tok = l.readTwoCharToken(token.INCREMENT)
+        default:
+            tok = newToken(token.PLUS, l.ch)
+        }
 
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+        switch l.peekChar() {
+        case '=':
+            tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+        case '-':
+            tok = l.readTwoCharToken(token.DECREMENT)
+        default:
+            tok = newToken(token.MINUS, l.ch)
+        }
 
 	case '!':
 		if l.peekChar() == '=' {
@@ -72,16 +144,26 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case '/':
 		if l.peekChar() == '/' {
-			for l.ch != '\n' && l.ch != '\r' && l.ch != '\t' && l.ch != 0 {
+            start := l.position
+			for l.ch != '\n' && l.ch != '\r' && l.ch != 0 {
 				l.readChar()
 			}
+            if l.keepComments {
+                return token.Token{Type: token.COMMENT, Literal: l.input[start:l.position], Pos: pos}
+            }
 			return l.NextToken()
+		} else if l.peekChar() == '=' {
+            tok = l.readTwoCharToken(token.SLASH_ASSIGN)
 		} else {
 			tok = newToken(token.SLASH, l.ch)
 		}
 
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+        if l.peekChar() == '=' {
+            tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+        } else {
+            tok = newToken(token.ASTERISK, l.ch)
+        }
 
 	case '<':
 		tok = newToken(token.LT, l.ch)
@@ -90,7 +172,11 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.GT, l.ch)
 
     case '%':
-        tok = newToken(token.MOD, l.ch)
+        if l.peekChar() == '=' {
+            tok = l.readTwoCharToken(token.MOD_ASSIGN)
+        } else {
+            tok = newToken(token.MOD, l.ch)
+        }
 
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
@@ -98,11 +184,35 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+
 	case '{':
+        if in := l.interpolation; in != nil {
+            l.interpolation = &interpolation{in.pos, in.open, in.triple, in.depth + 1, in.outer}
+        }
 		tok = newToken(token.LBRACE, l.ch)
 
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+        in := l.interpolation
+        switch {
+        case in != nil && in.depth == 0:
+            // the end of a ${ }, the string goes on
+            l.interpolation = in.outer
+            l.readChar()
+            tok = l.readStringPart(in, token.STRING_END, token.STRING_MIDDLE)
+        case in != nil:
+            l.interpolation = &interpolation{in.pos, in.open, in.triple, in.depth - 1, in.outer}
+            fallthrough
+        default:
+            tok = newToken(token.RBRACE, l.ch)
+        }
+
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
@@ -111,41 +221,186 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 
 	case 0:
+        // the strings around it can't end either, one error covers them
+        if l.interpolation != nil {
+            l.errors = append(l.errors, &Error{Pos: l.interpolation.open, Message: "missing } in ${...}", Unterminated: true})
+            l.interpolation = nil
+        }
 		// see if theres a better way to do this
 		tok.Literal = ""
 		tok.Type = token.EOF
 
 	default:
-		if isLetter(l.ch) {
+		if l.ch == '.' && (l.peekChar() < '0' || l.peekChar() > '9') {
+			// lib.name, a . in front of a digit starts a number instead
+			tok = newToken(token.DOT, l.ch)
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+            tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.LookupNumber(tok.Literal)
+            tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+    switch tok.Type {
+    case token.STRING_LITERAL, token.STRING_START, token.STRING_MIDDLE, token.STRING_END:
+        if l.keepComments {
+            tok.Literal = l.input[start:min(l.position+1, len(l.input))]
+        }
+    }
+
 	l.readChar()
+    tok.Pos = pos
 	return tok
 }
 
-func (l *Lexer) readString() string {
-    var sb strings.Builder
+func (l *Lexer) curPosition() token.Position {
+    return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
+// The errors so far, in the order the lexer ran into them
+func (l *Lexer) Errors() []*Error {
+    return l.errors
+}
+
+func (l *Lexer) addError(pos token.Position, format string, a ...interface{}) {
+    l.errors = append(l.errors, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *Lexer) unterminated(pos token.Position, kind string) {
+    l.errors = append(l.errors, &Error{Pos: pos, Message: "unterminated " + kind, Unterminated: true})
+}
+
+// "..." can span lines. """...""" is the same with quotes allowed inside, a
+// line break right after the opening quotes is not part of it. Both stop at
+// the ${ of their first interpolation if they have one.
+func (l *Lexer) readString(pos token.Position) token.Token {
+    triple := strings.HasPrefix(l.input[l.position:], `"""`)
+    if triple {
+        l.readChar()
+        l.readChar()
+        if l.peekChar() == '\n' {
+            l.readChar()
+        }
+    }
     l.readChar()
-    for l.ch != '"' && l.ch != 0 {
-        if l.ch == '\\' && l.peekChar() == 'n' {
-            sb.WriteString(string('\n'))
+    return l.readStringPart(&interpolation{pos: pos, triple: triple}, token.STRING_LITERAL, token.STRING_START)
+}
+
+// Reads the text of string s up to its closing quote, which makes an end
+// token, or up to the next ${, which makes an open one. l.ch ends up on the
+// last char of either.
+func (l *Lexer) readStringPart(s *interpolation, end token.TokenType, open token.TokenType) token.Token {
+    var sb strings.Builder
+    for {
+        switch {
+        case l.ch == 0:
+            // inside of a ${ the missing } gets the error, at the EOF token
+            if l.interpolation == nil {
+                l.unterminated(s.pos, "string")
+            }
+            return token.Token{Type: end, Literal: sb.String()}
+        case s.triple && strings.HasPrefix(l.input[l.position:], `"""`):
             l.readChar()
-        } else {
-            sb.WriteString(string(l.ch))
+            l.readChar()
+            return token.Token{Type: end, Literal: sb.String()}
+        case !s.triple && l.ch == '"':
+            return token.Token{Type: end, Literal: sb.String()}
+        case l.ch == '$' && l.peekChar() == '{':
+            at := l.curPosition()
+            l.readChar()
+            l.interpolation = &interpolation{pos: s.pos, open: at, triple: s.triple, outer: l.interpolation}
+            return token.Token{Type: open, Literal: sb.String()}
+        }
+        l.readStringChar(&sb)
+    }
+}
+
+// `...` has no escapes at all
+func (l *Lexer) readRawString(pos token.Position) string {
+    start := l.position + 1
+    l.readChar()
+    for l.ch != '`' {
+        if l.ch == 0 {
+            l.unterminated(pos, "raw string")
+            break
+        }
+        l.readChar()
+    }
+    return l.input[start:l.position]
+}
+
+// Reads one char of a string into sb, or the escape sequence it starts
+func (l *Lexer) readStringChar(sb *strings.Builder) {
+    if l.ch != '\\' {
+        // the bytes as written, so invalid UTF-8 stays as it was
+        sb.WriteString(l.input[l.position:l.readPosition])
+        l.readChar()
+        return
+    }
+
+    pos := l.curPosition()
+    start := l.position
+    l.readChar()
+    switch l.ch {
+    case 'n':
+        sb.WriteByte('\n')
+    case 't':
+        sb.WriteByte('\t')
+    case 'r':
+        sb.WriteByte('\r')
+    case '0':
+        sb.WriteByte(0)
+    case '\\', '"', '\'', '$':
+        sb.WriteRune(l.ch)
+    case 'x':
+        digits := hexDigits(l.input[l.readPosition:], 2)
+        if len(digits) != 2 {
+            l.addError(pos, "\\x needs two hex digits, like \\x41")
+            sb.WriteString(l.input[start:l.readPosition])
+            break
         }
+        b, _ := strconv.ParseUint(digits, 16, 8)
+        sb.WriteByte(byte(b))
+        l.readChar()
         l.readChar()
+    case 'u':
+        // \u{ then one to six hex digits then }
+        rest := l.input[l.readPosition:]
+        digits := ""
+        if strings.HasPrefix(rest, "{") {
+            digits = hexDigits(rest[1:], 6)
+        }
+        if digits == "" || !strings.HasPrefix(rest[1+len(digits):], "}") {
+            l.addError(pos, "\\u needs a code point in braces, like \\u{e9}")
+            sb.WriteString(l.input[start:l.readPosition])
+            break
+        }
+        r, _ := strconv.ParseUint(digits, 16, 32)
+        if utf8.ValidRune(rune(r)) {
+            sb.WriteRune(rune(r))
+        } else {
+            l.addError(pos, "invalid code point \\u{%s}", digits)
+        }
+        for l.ch != '}' {
+            l.readChar()
+        }
+    case 0:
+        // the caller reports the missing quote
+        sb.WriteByte('\\')
+        return
+    default:
+        l.addError(pos, "unknown escape sequence \\%c", l.ch)
+        sb.WriteString(l.input[start:l.readPosition])
     }
-    return sb.String()
+    l.readChar()
 }
 
 func (l *Lexer) eatWhitespace() {
@@ -155,21 +410,31 @@ func (l *Lexer) eatWhitespace() {
 }
 
 func (l *Lexer) readChar() {
+    if l.ch == '\n' {
+        l.line += 1
+        l.column = 1
+    } else {
+        // by the width of the char we are leaving, 1 before the first one
+        l.column += max(l.readPosition-l.position, 1)
+    }
+
+    width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+        // a byte that isn't UTF-8 comes out as utf8.RuneError, one wide
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+    r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+    return r
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -180,22 +445,47 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// Reads a number with everything stuck to it, so 0xFF, 1_000 and 1.5e-3 but
+// also 1.2.3 or 12ab. The parser checks the digits and says what is wrong.
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for isDigit(l.ch) {
+    prefixed := l.ch == '0' && strings.ContainsRune("xXbBoO", l.peekChar())
+	for isDigit(l.ch) || isLetter(l.ch) {
+        // the sign of an exponent, in hex the e is a digit and - is a minus
+        exponent := !prefixed && (l.ch == 'e' || l.ch == 'E')
 		l.readChar()
+        if exponent && (l.ch == '+' || l.ch == '-') {
+            l.readChar()
+        }
 	}
 	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+// Any unicode letter, so names like café or 名前 work
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+// The hex digits s starts with, at most n of them
+func hexDigits(s string, n int) string {
+    i := 0
+    for i < len(s) && i < n && strings.IndexByte("0123456789abcdefABCDEF", s[i]) >= 0 {
+        i++
+    }
+    return s[:i]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return (ch >= '0' && ch <= '9') || ch == '.'
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// For operators like += where the next char is part of the token
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+    ch := l.ch
+    l.readChar()
+    return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
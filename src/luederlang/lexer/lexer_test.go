@@ -29,6 +29,12 @@ if (5 < 10) {
 // akjsdlfkjasdf
 "foobar"
 "foo bar"
+[1, 2];
+xs[1:2]
+while for in break continue
+a || b && c
+x += 1 -= *= /= %= i++ j--
+import "lib" as lib export lib.add .5
 `
 
 	tests := []struct {
@@ -110,6 +116,48 @@ if (5 < 10) {
 		{token.SEMICOLON, ";"},
 		{token.STRING_LITERAL, "foobar"},
 		{token.STRING_LITERAL, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT_LITERAL, "1"},
+		{token.COMMA, ","},
+		{token.INT_LITERAL, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "xs"},
+		{token.LBRACKET, "["},
+		{token.INT_LITERAL, "1"},
+		{token.COLON, ":"},
+		{token.INT_LITERAL, "2"},
+		{token.RBRACKET, "]"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "a"},
+		{token.LOR, "||"},
+		{token.IDENT, "b"},
+		{token.LAND, "&&"},
+		{token.IDENT, "c"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT_LITERAL, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.MOD_ASSIGN, "%="},
+		{token.IDENT, "i"},
+		{token.INCREMENT, "++"},
+		{token.IDENT, "j"},
+		{token.DECREMENT, "--"},
+		{token.IMPORT, "import"},
+		{token.STRING_LITERAL, "lib"},
+		{token.AS, "as"},
+		{token.IDENT, "lib"},
+		{token.EXPORT, "export"},
+		{token.IDENT, "lib"},
+		{token.DOT, "."},
+		{token.IDENT, "add"},
+		{token.FLOAT_LITERAL, ".5"},
 		{token.EOF, ""},
 	}
 
@@ -130,3 +178,306 @@ if (5 < 10) {
 	}
 }
 
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a || b && c | d & e |"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LOR, "||"},
+		{token.IDENT, "b"},
+		{token.LAND, "&&"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "d"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "e"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  if (x) {\n\t\"a b\" }"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"if", 2, 3},
+		{"(", 2, 6},
+		{"x", 2, 7},
+		{")", 2, 8},
+		{"{", 2, 10},
+		{"a b", 3, 2},
+		{"}", 3, 8},
+		{"", 3, 9},
+	}
+
+	l := NewFile("pos.lueder", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Filename != "pos.lueder" || tok.Pos.Line != tt.expectedLine ||
+			tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=pos.lueder:%d:%d, got=%s",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "0xFF 0b1010 0o17 1_000 .5 1e9 2.5E-3 0x1e-2 010 1.2.3 12ab x.5"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT_LITERAL, "0xFF"},
+		{token.INT_LITERAL, "0b1010"},
+		{token.INT_LITERAL, "0o17"},
+		{token.INT_LITERAL, "1_000"},
+		{token.FLOAT_LITERAL, ".5"},
+		{token.FLOAT_LITERAL, "1e9"},
+		{token.FLOAT_LITERAL, "2.5E-3"},
+		// in hex the e is a digit, so this is a minus
+		{token.INT_LITERAL, "0x1e"},
+		{token.MINUS, "-"},
+		{token.INT_LITERAL, "2"},
+		{token.INT_LITERAL, "010"},
+		// the parser says what is wrong with these
+		{token.FLOAT_LITERAL, "1.2.3"},
+		{token.INT_LITERAL, "12ab"},
+		{token.IDENT, "x"},
+		{token.FLOAT_LITERAL, ".5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "x // one\t\"two\"\n// three\ny"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.IDENT, "x", 1},
+		{token.COMMENT, "// one\t\"two\"", 1},
+		{token.COMMENT, "// three", 2},
+		{token.IDENT, "y", 3},
+		{token.EOF, "", 3},
+	}
+
+	l := NewWithComments(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral || tok.Pos.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q at line %d, got=%s %q at line %d",
+				i, tt.expectedType, tt.expectedLiteral, tt.expectedLine, tok.Type, tok.Literal, tok.Pos.Line)
+		}
+	}
+
+	// without comments the tab doesn't end the comment either
+	l = New(input)
+	if tok := l.NextToken(); tok.Literal != "x" {
+		t.Fatalf("wrong first token. got=%q", tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Literal != "y" {
+		t.Errorf("comment was not skipped. got=%s %q", tok.Type, tok.Literal)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb\tc\r"`, "a\nb\tc\r"},
+		{`"say \"hi\" \\ 'bye'\'"`, `say "hi" \ 'bye''`},
+		{`"\x41\x7a\0"`, "Az\x00"},
+		{`"caf\u{e9} \u{1F600}"`, "café 😀"},
+		{"\"two\nlines\"", "two\nlines"},
+		{"`C:\\dir\\n \"x\"`", `C:\dir\n "x"`},
+		{"`one\ntwo`", "one\ntwo"},
+		{"\"\"\"\nsay \"hi\"\\t\n  \"\"\"", "say \"hi\"\t\n  "},
+		{`"""a ""b"" c"""`, `a ""b"" c`},
+		{`""`, ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING_LITERAL || tok.Literal != tt.expected {
+			t.Errorf("%s | wrong token. expected=STRING_LITERAL %q, got=%s %q", tt.input, tt.expected, tok.Type, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("%s | unexpected error %q", tt.input, l.Errors()[0].Message)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%s | string did not end at the closing quote. got=%s %q", tt.input, tok.Type, tok.Literal)
+		}
+
+		// the formatter gets them as written
+		if tok := NewWithComments(tt.input).NextToken(); tok.Literal != tt.input {
+			t.Errorf("%s | wrong source. got=%q", tt.input, tok.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"a\qb" x`, `a\qb`, `1:3: unknown escape sequence \q`},
+		{`"\x4" x`, `\x4`, `1:2: \x needs two hex digits, like \x41`},
+		{`"\u41" x`, `\u41`, `1:2: \u needs a code point in braces, like \u{e9}`},
+		{`"\u{D800}" x`, ``, `1:2: invalid code point \u{D800}`},
+		{`"\u{1234567}" x`, `\u{1234567}`, `1:2: \u needs a code point in braces, like \u{e9}`},
+		{"x \"abc\ndef", "abc\ndef", "1:3: unterminated string"},
+		{"x \"abc\\", "abc\\", "1:3: unterminated string"},
+		{"x `abc", "abc", "1:3: unterminated raw string"},
+		{"x \"\"\"abc\"\"", "abc\"\"", "1:3: unterminated string"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		var str token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.STRING_LITERAL {
+				str = tok
+			}
+		}
+		if str.Literal != tt.expectedLiteral {
+			t.Errorf("%s | wrong literal. expected=%q, got=%q", tt.input, tt.expectedLiteral, str.Literal)
+		}
+		errors := l.Errors()
+		if len(errors) != 1 || errors[0].Pos.String()+": "+errors[0].Message != tt.expectedError {
+			t.Errorf("%s | expected error %q. got=%v", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	input := "\"a ${x} b ${ {1: \"c${y}\"}[1] } \\${d}\" }"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedSource  string
+	}{
+		{token.STRING_START, "a ", `"a ${`},
+		{token.IDENT, "x", "x"},
+		{token.STRING_MIDDLE, " b ", "} b ${"},
+		{token.LBRACE, "{", "{"},
+		{token.INT_LITERAL, "1", "1"},
+		{token.COLON, ":", ":"},
+		{token.STRING_START, "c", `"c${`},
+		{token.IDENT, "y", "y"},
+		{token.STRING_END, "", `}"`},
+		{token.RBRACE, "}", "}"},
+		{token.LBRACKET, "[", "["},
+		{token.INT_LITERAL, "1", "1"},
+		{token.RBRACKET, "]", "]"},
+		{token.STRING_END, " ${d}", `} \${d}"`},
+		{token.RBRACE, "}", "}"},
+		{token.EOF, "", ""},
+	}
+
+	l := New(input)
+	source := NewWithComments(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok := source.NextToken(); tok.Literal != tt.expectedSource {
+			t.Errorf("tests[%d] - wrong source. expected=%q, got=%q", i, tt.expectedSource, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected error %q", l.Errors()[0].Message)
+	}
+
+	// the strings still open at the end only get one error, for the last ${
+	l = New(`"a ${f("b ${x`)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	errors := l.Errors()
+	if len(errors) != 1 || errors[0].Pos.Column != 11 || errors[0].Message != "missing } in ${...}" || !errors[0].Unterminated {
+		t.Errorf("wrong errors for open strings. got=%v", errors)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF || len(l.Errors()) != 1 {
+		t.Errorf("EOF again reported more. got=%s %v", tok.Type, l.Errors())
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let café = \"naïve 😀\";\nπ € 名前 \"\xff\""
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "café", 1, 5},
+		{token.ASSIGN, "=", 1, 11},
+		{token.STRING_LITERAL, "naïve 😀", 1, 13},
+		{token.SEMICOLON, ";", 1, 26},
+		{token.IDENT, "π", 2, 1},
+		{token.ILLEGAL, "€", 2, 4},
+		{token.IDENT, "名前", 2, 8},
+		{token.STRING_LITERAL, "\xff", 2, 15},
+		{token.EOF, "", 2, 18},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%s",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+}
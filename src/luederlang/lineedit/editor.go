@@ -0,0 +1,360 @@
+package lineedit
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// ReadLine returns this when ctrl-c throws the line away
+var ErrInterrupted = errors.New("interrupted")
+
+// Keys that come in as escape sequences, below zero so no rune collides
+const (
+    keyUp rune = -(iota + 1)
+    keyDown
+    keyLeft
+    keyRight
+    keyHome
+    keyEnd
+    keyDelete
+    keyUnknown
+)
+
+func ctrl(r rune) rune { return r & 0x1f }
+
+// An emacs style line editor. Keys are read from in and the line is drawn to
+// out with ANSI escapes, so tests can drive it with plain strings.
+type Editor struct {
+    in  *bufio.Reader
+    out io.Writer
+    fd  int // of the terminal in is, -1 if it is none
+
+    // candidates that start with prefix, nil turns completion off
+    Complete func(prefix string) []string
+    // leaves the history to AddHistory, for input that spans several lines
+    ManualHistory bool
+
+    history     []string
+    historyFile string
+
+    prompt string
+    line   []rune
+    pos    int
+}
+
+func New(in io.Reader, out io.Writer) *Editor {
+    e := &Editor{in: bufio.NewReader(in), out: out, fd: -1}
+    if f, ok := in.(*os.File); ok && IsTerminal(f) {
+        e.fd = int(f.Fd())
+    }
+    return e
+}
+
+// Reads one line. A terminal is in raw mode only while this runs, so output
+// in between behaves as usual. Lines that aren't blank go into the history
+// unless ManualHistory is set.
+// Returns io.EOF for ctrl-d on an empty line or at the end of the input.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+    if e.fd >= 0 {
+        restore, err := makeRaw(e.fd)
+        if err != nil {
+            return "", err
+        }
+        defer restore()
+    }
+
+    e.prompt = prompt
+    e.line = e.line[:0]
+    e.pos = 0
+    // where up and down are in the history, len(history) is the new line
+    historyPos := len(e.history)
+    pending := ""
+    e.refresh()
+
+    for {
+        r, err := e.readKey()
+        if err == io.EOF && len(e.line) > 0 {
+            break
+        }
+        if err != nil {
+            return "", err
+        }
+
+        if r == ctrl('R') {
+            if r, err = e.search(); err != nil {
+                return "", err
+            }
+            e.refresh()
+        }
+
+        switch r {
+        case 0:
+        case '\r', '\n':
+            fmt.Fprint(e.out, "\r\n")
+            line := string(e.line)
+            if !e.ManualHistory {
+                e.AddHistory(line)
+            }
+            return line, nil
+        case ctrl('C'):
+            fmt.Fprint(e.out, "^C\r\n")
+            return "", ErrInterrupted
+        case ctrl('D'):
+            if len(e.line) == 0 {
+                fmt.Fprint(e.out, "\r\n")
+                return "", io.EOF
+            }
+            e.delete(e.pos, e.pos+1)
+        case keyDelete:
+            e.delete(e.pos, e.pos+1)
+        case 127, ctrl('H'):
+            e.delete(e.pos-1, e.pos)
+        case keyLeft, ctrl('B'):
+            e.moveTo(e.pos - 1)
+        case keyRight, ctrl('F'):
+            e.moveTo(e.pos + 1)
+        case keyHome, ctrl('A'):
+            e.moveTo(0)
+        case keyEnd, ctrl('E'):
+            e.moveTo(len(e.line))
+        case ctrl('K'):
+            e.delete(e.pos, len(e.line))
+        case ctrl('U'):
+            e.delete(0, e.pos)
+        case ctrl('W'):
+            start := e.pos
+            for start > 0 && e.line[start-1] == ' ' {
+                start--
+            }
+            for start > 0 && e.line[start-1] != ' ' {
+                start--
+            }
+            e.delete(start, e.pos)
+        case keyUp, ctrl('P'):
+            if historyPos > 0 {
+                if historyPos == len(e.history) {
+                    pending = string(e.line)
+                }
+                historyPos--
+                e.setLine(e.history[historyPos])
+            }
+        case keyDown, ctrl('N'):
+            if historyPos < len(e.history) {
+                historyPos++
+                if historyPos == len(e.history) {
+                    e.setLine(pending)
+                } else {
+                    e.setLine(e.history[historyPos])
+                }
+            }
+        case '\t':
+            e.complete()
+        default:
+            if r >= ' ' {
+                e.insert(r)
+            }
+        }
+    }
+
+    return string(e.line), nil
+}
+
+// Reads a rune, turning escape sequences into the key constants. Alt plus a
+// key and sequences nobody handles come back as keyUnknown.
+func (e *Editor) readKey() (rune, error) {
+    r, _, err := e.in.ReadRune()
+    if err != nil || r != 27 {
+        return r, err
+    }
+    next, _, err := e.in.ReadRune()
+    if err != nil {
+        return 0, err
+    }
+    if next != '[' && next != 'O' {
+        return keyUnknown, nil
+    }
+
+    // parameters, then a final byte in @ to ~
+    var seq strings.Builder
+    for {
+        b, err := e.in.ReadByte()
+        if err != nil {
+            return 0, err
+        }
+        seq.WriteByte(b)
+        if b >= '@' && b <= '~' {
+            break
+        }
+    }
+
+    switch seq.String() {
+    case "A":
+        return keyUp, nil
+    case "B":
+        return keyDown, nil
+    case "C":
+        return keyRight, nil
+    case "D":
+        return keyLeft, nil
+    case "H", "1~", "7~":
+        return keyHome, nil
+    case "F", "4~", "8~":
+        return keyEnd, nil
+    case "3~":
+        return keyDelete, nil
+    }
+    return keyUnknown, nil
+}
+
+// Redraws the prompt and line, then puts the cursor back where it belongs
+func (e *Editor) refresh() {
+    e.draw(e.prompt, e.line, e.pos)
+}
+
+// A newline from a multi-line history entry shows as ↵ so the entry stays on
+// one row and the cursor math still holds
+func (e *Editor) draw(prompt string, line []rune, pos int) {
+    shown := strings.ReplaceAll(string(line), "\n", "↵")
+    fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, shown)
+    if back := len(line) - pos; back > 0 {
+        fmt.Fprintf(e.out, "\x1b[%dD", back)
+    }
+}
+
+func (e *Editor) insert(runes ...rune) {
+    line := append([]rune{}, e.line[:e.pos]...)
+    line = append(line, runes...)
+    e.line = append(line, e.line[e.pos:]...)
+    e.pos += len(runes)
+    e.refresh()
+}
+
+// Removes line[from:to], clamped to the line
+func (e *Editor) delete(from int, to int) {
+    from = max(from, 0)
+    to = min(to, len(e.line))
+    if from >= to {
+        return
+    }
+    e.line = append(e.line[:from], e.line[to:]...)
+    e.pos = from
+    e.refresh()
+}
+
+func (e *Editor) moveTo(pos int) {
+    if pos < 0 || pos > len(e.line) {
+        return
+    }
+    e.pos = pos
+    e.refresh()
+}
+
+func (e *Editor) setLine(line string) {
+    e.line = []rune(line)
+    e.pos = len(e.line)
+    e.refresh()
+}
+
+// Completes the word in front of the cursor. One candidate is filled in, more
+// fill in what they share and a second tab lists them.
+func (e *Editor) complete() {
+    start := e.pos
+    for start > 0 && isWordRune(e.line[start-1]) {
+        start--
+    }
+    prefix := string(e.line[start:e.pos])
+    if e.Complete == nil || prefix == "" {
+        return
+    }
+
+    candidates := e.Complete(prefix)
+    sort.Strings(candidates)
+    switch len(candidates) {
+    case 0:
+        fmt.Fprint(e.out, "\a")
+    case 1:
+        e.insert([]rune(strings.TrimPrefix(candidates[0], prefix))...)
+    default:
+        shared := candidates[0]
+        for _, c := range candidates[1:] {
+            for !strings.HasPrefix(c, shared) {
+                _, size := utf8.DecodeLastRuneInString(shared)
+                shared = shared[:len(shared)-size]
+            }
+        }
+        if len(shared) > len(prefix) {
+            e.insert([]rune(shared[len(prefix):])...)
+            return
+        }
+        fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+        e.refresh()
+    }
+}
+
+// Identifiers are letters and underscores, like the lexer says
+func isWordRune(r rune) bool {
+    return r == '_' || unicode.IsLetter(r)
+}
+
+// Ctrl-r searches the history backwards for lines containing what is typed,
+// another ctrl-r goes further back. Any other key keeps the match as the line
+// and is handed back to ReadLine, ctrl-g and ctrl-c give up on the search.
+func (e *Editor) search() (rune, error) {
+    original, originalPos := e.line, e.pos
+    query := []rune{}
+    match := len(e.history)
+    failed := false
+
+    // looks for query from the entry at from backwards
+    find := func(from int) {
+        for i := min(from, len(e.history)-1); i >= 0; i-- {
+            if at := strings.Index(e.history[i], string(query)); at >= 0 {
+                match = i
+                e.line = []rune(e.history[i])
+                e.pos = len([]rune(e.history[i][:at]))
+                failed = false
+                return
+            }
+        }
+        failed = true
+    }
+
+    for {
+        prompt := "(reverse-i-search)`"
+        if failed {
+            prompt = "(failed reverse-i-search)`"
+        }
+        e.draw(prompt+string(query)+"': ", e.line, e.pos)
+
+        r, err := e.readKey()
+        if err != nil {
+            return 0, err
+        }
+        switch {
+        case r == ctrl('R'):
+            if len(query) > 0 {
+                find(match - 1)
+            }
+        case r == 127 || r == ctrl('H'):
+            if len(query) > 0 {
+                query = query[:len(query)-1]
+                find(len(e.history) - 1)
+            }
+        case r == ctrl('G') || r == ctrl('C'):
+            e.line, e.pos = original, originalPos
+            return 0, nil
+        case r >= ' ':
+            query = append(query, r)
+            find(match)
+        default:
+            return r, nil
+        }
+    }
+}
@@ -0,0 +1,190 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Feeds keys to a fresh editor and returns every line it reads
+func readLines(t *testing.T, e *Editor, keys string, count int) []string {
+	e.in.Reset(strings.NewReader(keys))
+	lines := []string{}
+	for i := 0; i < count; i++ {
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Fatalf("%q | unexpected error: %v", keys, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"bc\x1b[Ha\x1b[F!\r", "abc!"},
+		{"abcd\x7f\x7f\r", "ab"},
+		{"abcd\x1b[D\x1b[D\x1b[3~\r", "abd"},
+		{"abcd\x02\x02\x0b\r", "ab"},
+		{"abcd\x02\x15\r", "d"},
+		{"let x = fun\x17\r", "let x = "},
+		{"ab\x1b[C\x1b[Cc\r", "abc"},
+		{"héllo\x02\x02\x02\x7f\r", "hllo"},
+	}
+
+	for _, tt := range tests {
+		e := New(nil, io.Discard)
+		got := readLines(t, e, tt.keys, 1)[0]
+		if got != tt.expected {
+			t.Errorf("%q | wrong line. expected=%q, got=%q", tt.keys, tt.expected, got)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := New(nil, io.Discard)
+	lines := readLines(t, e, "first\rsecond\rsecond\r\r\x1b[A\x1b[A\r\x1b[A\x1b[A\x1b[Bx\r\x1b[A\x1b[By\r", 7)
+
+	expected := []string{"first", "second", "second", "", "first", "firstx", "y"}
+	if strings.Join(lines, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong lines. expected=%q, got=%q", expected, lines)
+	}
+
+	// blank lines and repeats stay out of the history
+	if got := strings.Join(e.History(), ","); got != "first,second,first,firstx,y" {
+		t.Errorf("wrong history. got=%q", e.History())
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12let\r", "let y = 2"},
+		{"\x12let\x12\r", "let x = 1"},
+		{"\x12prx\x7f\r", "print(x)"},
+		// another key keeps the match and goes on editing
+		{"\x12int\x05;\r", "print(x);"},
+		{"old\x12let\x07\r", "old"},
+	}
+
+	for _, tt := range tests {
+		e := New(nil, io.Discard)
+		e.history = []string{"let x = 1", "print(x)", "let y = 2"}
+		got := readLines(t, e, tt.keys, 1)[0]
+		if got != tt.expected {
+			t.Errorf("%q | wrong line. expected=%q, got=%q", tt.keys, tt.expected, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"print", "keys", "keyword", "let", "café", "cafè", "naïve"}
+
+	tests := []struct {
+		keys     string
+		expected string
+		listed   bool
+	}{
+		{"pr\t(1)\r", "print(1)", false},
+		{"ke\t\r", "key", false},
+		{"key\t\r", "key", true},
+		{"x\t\r", "x", false},
+		{"len(pri\t)\r", "len(print)", false},
+		{"ca\t\r", "caf", false},
+		{"naï\t\r", "naïve", false},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := New(nil, &out)
+		e.Complete = func(prefix string) []string {
+			candidates := []string{}
+			for _, w := range words {
+				if strings.HasPrefix(w, prefix) {
+					candidates = append(candidates, w)
+				}
+			}
+			return candidates
+		}
+
+		got := readLines(t, e, tt.keys, 1)[0]
+		if got != tt.expected {
+			t.Errorf("%q | wrong line. expected=%q, got=%q", tt.keys, tt.expected, got)
+		}
+		if listed := strings.Contains(out.String(), "keys  keyword"); listed != tt.listed {
+			t.Errorf("%q | expected listed=%t, got output %q", tt.keys, tt.listed, out.String())
+		}
+	}
+}
+
+func TestInterruptAndEOF(t *testing.T) {
+	e := New(strings.NewReader("abc\x03\x04"), io.Discard)
+	if _, err := e.ReadLine(">> "); err != ErrInterrupted {
+		t.Errorf("expected ErrInterrupted. got=%v", err)
+	}
+	if _, err := e.ReadLine(">> "); err != io.EOF {
+		t.Errorf("expected io.EOF. got=%v", err)
+	}
+
+	// input that ends without a newline still gives its last line
+	e = New(strings.NewReader("last"), io.Discard)
+	if line, err := e.ReadLine(">> "); line != "last" || err != nil {
+		t.Errorf("expected last line. got=%q, %v", line, err)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := New(nil, io.Discard)
+	if err := e.UseHistoryFile(path); err != nil {
+		t.Fatalf("UseHistoryFile: %v", err)
+	}
+	readLines(t, e, "one\rtwo\r", 2)
+
+	again := New(nil, io.Discard)
+	if err := again.UseHistoryFile(path); err != nil {
+		t.Fatalf("UseHistoryFile: %v", err)
+	}
+	if got := readLines(t, again, "\x1b[A\x1b[A\r", 1)[0]; got != "one" {
+		t.Errorf("history was not loaded. got=%q", got)
+	}
+}
+
+func TestMultiLineHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := New(nil, io.Discard)
+	e.ManualHistory = true
+	if err := e.UseHistoryFile(path); err != nil {
+		t.Fatalf("UseHistoryFile: %v", err)
+	}
+	lines := readLines(t, e, "let f = fun() {\r  \"a\\\\n\"\r}\r", 3)
+	if len(e.History()) != 0 {
+		t.Errorf("lines went into the history by themselves. got=%q", e.History())
+	}
+	e.AddHistory(strings.Join(lines, "\n"))
+
+	var out bytes.Buffer
+	again := New(nil, &out)
+	if err := again.UseHistoryFile(path); err != nil {
+		t.Fatalf("UseHistoryFile: %v", err)
+	}
+	expected := "let f = fun() {\n  \"a\\\\n\"\n}"
+	if got := readLines(t, again, "\x1b[A\r", 1)[0]; got != expected {
+		t.Errorf("wrong entry. expected=%q, got=%q", expected, got)
+	}
+	if strings.Contains(out.String(), "{\n") || !strings.Contains(out.String(), "{↵") {
+		t.Errorf("newlines were not drawn as ↵. got=%q", out.String())
+	}
+}
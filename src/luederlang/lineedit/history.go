@@ -0,0 +1,75 @@
+package lineedit
+
+import (
+    "os"
+    "strings"
+)
+
+// How many lines of history are kept around
+const historyLimit = 1000
+
+// Skips blank lines and repeats of the previous line. With a history file
+// the line is appended to it right away, so a crash doesn't lose it. A line
+// may hold newlines, it is still one entry.
+func (e *Editor) AddHistory(line string) {
+    if strings.TrimSpace(line) == "" {
+        return
+    }
+    if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+        return
+    }
+    e.history = append(e.history, line)
+    if len(e.history) > historyLimit {
+        e.history = e.history[len(e.history)-historyLimit:]
+    }
+
+    if e.historyFile != "" {
+        f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+        if err != nil {
+            return
+        }
+        defer f.Close()
+        f.WriteString(escapeNewlines(line) + "\n")
+    }
+}
+
+func (e *Editor) History() []string {
+    return e.history
+}
+
+// Loads the history saved in path and keeps adding to it. A file that
+// doesn't exist yet is fine, one that outgrew the limit gets cut down.
+func (e *Editor) UseHistoryFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return err
+    }
+
+    lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+    if len(data) == 0 {
+        lines = nil
+    }
+    if len(lines) > historyLimit {
+        lines = lines[len(lines)-historyLimit:]
+        if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+            return err
+        }
+    }
+
+    for i, line := range lines {
+        lines[i] = unescapeNewlines(line)
+    }
+    e.history = append(lines, e.history...)
+    e.historyFile = path
+    return nil
+}
+
+// The file has an entry per line, so newlines inside one are written as \n
+// and backslashes as \\
+func escapeNewlines(line string) string {
+    return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(line)
+}
+
+func unescapeNewlines(line string) string {
+    return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(line)
+}
@@ -0,0 +1,8 @@
+package lineedit
+
+import "syscall"
+
+const (
+    ioctlGetTermios = syscall.TIOCGETA
+    ioctlSetTermios = syscall.TIOCSETA
+)
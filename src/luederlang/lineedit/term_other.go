@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package lineedit
+
+import (
+    "errors"
+    "os"
+)
+
+// Without termios the repl falls back to reading plain lines
+func IsTerminal(f *os.File) bool {
+    return false
+}
+
+func makeRaw(fd int) (func(), error) {
+    return nil, errors.New("raw mode is not supported here")
+}
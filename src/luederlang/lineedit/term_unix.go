@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package lineedit
+
+import (
+    "os"
+    "syscall"
+    "unsafe"
+)
+
+func IsTerminal(f *os.File) bool {
+    var t syscall.Termios
+    return ioctl(int(f.Fd()), ioctlGetTermios, &t) == nil
+}
+
+// Turns off echo, line buffering, signals and output processing so every key
+// reaches the editor as it is pressed. The returned func undoes it.
+func makeRaw(fd int) (func(), error) {
+    var old syscall.Termios
+    if err := ioctl(fd, ioctlGetTermios, &old); err != nil {
+        return nil, err
+    }
+
+    raw := old
+    raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+    raw.Oflag &^= syscall.OPOST
+    raw.Cflag |= syscall.CS8
+    raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+    raw.Cc[syscall.VMIN] = 1
+    raw.Cc[syscall.VTIME] = 0
+    if err := ioctl(fd, ioctlSetTermios, &raw); err != nil {
+        return nil, err
+    }
+
+    return func() { ioctl(fd, ioctlSetTermios, &old) }, nil
+}
+
+func ioctl(fd int, request uintptr, t *syscall.Termios) error {
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(t)))
+    if errno != 0 {
+        return errno
+    }
+    return nil
+}
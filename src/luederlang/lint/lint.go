@@ -0,0 +1,284 @@
+package lint
+
+import (
+    "fmt"
+    "luederlang/ast"
+    "luederlang/evaluator"
+    "luederlang/lexer"
+    "luederlang/parser"
+    "luederlang/token"
+    "sort"
+    "strings"
+)
+
+// The checks, their IDs are what // lint:ignore takes and never change
+const (
+    SHADOWED_BUILTIN   = "shadowed-builtin"
+    SHADOWED_VARIABLE  = "shadowed-variable"
+    UNUSED_VARIABLE    = "unused-variable"
+    UNUSED_PARAMETER   = "unused-parameter"
+    UNREACHABLE_CODE   = "unreachable-code"
+    CONSTANT_CONDITION = "constant-condition"
+)
+
+type Issue struct {
+    Pos     token.Position
+    Check   string
+    Message string
+}
+
+func (i *Issue) String() string {
+    return i.Pos.String() + ": " + i.Message + " (" + i.Check + ")"
+}
+
+// Parses input and returns what looks wrong with it, in source order. An
+// issue is left out when the line it is on ends in // lint:ignore ID, or the
+// line before is only that comment. Several IDs are separated by commas.
+func Lint(filename string, input string) ([]*Issue, []*parser.ParseError) {
+    p := parser.New(lexer.NewFile(filename, input))
+    program := p.ParseProgram()
+    if len(p.ErrorList()) != 0 {
+        return nil, p.ErrorList()
+    }
+
+    l := &linter{builtins: map[string]bool{}, scope: newScope(nil)}
+    for _, name := range evaluator.BuiltinNames() {
+        l.builtins[name] = true
+    }
+    // globals can be used by importers and the test runner, only the
+    // builtin check applies to them
+    l.hoist(program, global)
+    l.unreachable(program.Statements)
+    for _, s := range program.Statements {
+        l.walk(s)
+    }
+
+    ignored := ignoredChecks(input)
+    issues := []*Issue{}
+    for _, issue := range l.issues {
+        if !ignored[issue.Pos.Line][issue.Check] {
+            issues = append(issues, issue)
+        }
+    }
+    sort.SliceStable(issues, func(i, j int) bool {
+        a, b := issues[i].Pos, issues[j].Pos
+        return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+    })
+    return issues, nil
+}
+
+// The checks each line has turned off
+func ignoredChecks(input string) map[int]map[string]bool {
+    ignored := map[int]map[string]bool{}
+    l := lexer.NewWithComments(input)
+    previous := 0 // line of the last token that isn't a comment
+    for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+        if tok.Type != token.COMMENT {
+            previous = tok.Pos.Line
+            continue
+        }
+        text := strings.TrimSpace(strings.TrimPrefix(tok.Literal, "//"))
+        ids, ok := strings.CutPrefix(text, "lint:ignore")
+        if !ok {
+            continue
+        }
+
+        line := tok.Pos.Line
+        if previous != line {
+            line++
+        }
+        if ignored[line] == nil {
+            ignored[line] = map[string]bool{}
+        }
+        for _, id := range strings.Split(ids, ",") {
+            ignored[line][strings.TrimSpace(id)] = true
+        }
+    }
+    return ignored
+}
+
+// What declared a name, only locals and parameters have to be used
+const (
+    global = iota
+    local
+    parameter
+    other // loop and catch variables, imports
+)
+
+type variable struct {
+    name *ast.Identifier
+    kind int
+    used bool
+}
+
+// Like in the evaluator only function bodies get a scope, blocks share the
+// one they are in
+type scope struct {
+    vars  map[string]*variable
+    order []*variable
+    outer *scope
+}
+
+func newScope(outer *scope) *scope {
+    return &scope{vars: map[string]*variable{}, outer: outer}
+}
+
+type linter struct {
+    builtins map[string]bool
+    scope    *scope
+    issues   []*Issue
+}
+
+func (l *linter) report(pos token.Position, check string, format string, a ...interface{}) {
+    l.issues = append(l.issues, &Issue{Pos: pos, Check: check, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) declare(name *ast.Identifier, kind int) {
+    existing, inScope := l.scope.vars[name.Value]
+    switch {
+    case l.builtins[name.Value]:
+        l.report(name.Pos(), SHADOWED_BUILTIN, "%s shadows the builtin %s", name.Value, name.Value)
+    case inScope && existing.kind == parameter && kind != parameter:
+        // the let overwrites what was passed in
+        l.report(name.Pos(), SHADOWED_VARIABLE, "%s shadows the parameter %s", name.Value, name.Value)
+    case !inScope && l.scope.outer.lookup(name.Value) != nil:
+        l.report(name.Pos(), SHADOWED_VARIABLE, "%s shadows %s from an outer scope", name.Value, name.Value)
+    }
+    // declaring it again in the same scope changes the same variable
+    if inScope {
+        return
+    }
+    v := &variable{name: name, kind: kind}
+    l.scope.vars[name.Value] = v
+    l.scope.order = append(l.scope.order, v)
+}
+
+func (l *linter) use(name string) {
+    if v := l.scope.lookup(name); v != nil {
+        v.used = true
+    }
+}
+
+// The variable name is in s or a scope around it, nil if there is none
+func (s *scope) lookup(name string) *variable {
+    for ; s != nil; s = s.outer {
+        if v, ok := s.vars[name]; ok {
+            return v
+        }
+    }
+    return nil
+}
+
+// Declares every name node binds in the current scope up front, a function
+// can use a name that is only declared further down
+func (l *linter) hoist(node ast.Node, kind int) {
+    ast.Inspect(node, func(n ast.Node) bool {
+        switch n := n.(type) {
+        case *ast.FunctionLiteral:
+            return false
+        case *ast.ForInStatement:
+            l.declare(n.Variable, other)
+        case *ast.TryStatement:
+            if n.CatchVariable != nil {
+                l.declare(n.CatchVariable, other)
+            }
+        case *ast.ImportStatement:
+            l.declare(n.Name, other)
+        case ast.Statement:
+            if name, _, _, ok := ast.DeclarationOf(n); ok {
+                l.declare(name, kind)
+            }
+        }
+        return true
+    })
+}
+
+// Marks the names node reads as used and runs the checks on what is inside
+func (l *linter) walk(node ast.Node) {
+    ast.Inspect(node, func(n ast.Node) bool {
+        switch n := n.(type) {
+        case *ast.FunctionLiteral:
+            l.function(n)
+            return false
+        case *ast.Identifier:
+            l.use(n.Value)
+        case *ast.AssignStatement:
+            // assigning to a name doesn't use it
+            l.walk(n.Value)
+            return false
+        case *ast.ForInStatement:
+            l.walk(n.Iterable)
+            l.walk(n.Body)
+            return false
+        case *ast.TryStatement:
+            l.walk(n.Body)
+            l.walk(n.Catch)
+            l.walk(n.Finally)
+            return false
+        case *ast.ImportStatement:
+            return false
+        case *ast.BlockStatement:
+            l.unreachable(n.Statements)
+        case *ast.IfExpression:
+            if value, ok := constant(n.Condition); ok {
+                l.report(ast.Start(n.Condition), CONSTANT_CONDITION, "if condition is always %t", value)
+            }
+        case ast.Statement:
+            if _, value, _, ok := ast.DeclarationOf(n); ok {
+                l.walk(value)
+                return false
+            }
+        }
+        return true
+    })
+}
+
+func (l *linter) function(fl *ast.FunctionLiteral) {
+    outer := l.scope
+    l.scope = newScope(outer)
+
+    for _, param := range fl.Parameters {
+        l.declare(param, parameter)
+    }
+    l.hoist(fl.Body, local)
+    l.walk(fl.Body)
+
+    for _, v := range l.scope.order {
+        // a leading _ says it is unused on purpose
+        if v.used || strings.HasPrefix(v.name.Value, "_") {
+            continue
+        }
+        switch v.kind {
+        case local:
+            l.report(v.name.Pos(), UNUSED_VARIABLE, "%s is declared but never used", v.name.Value)
+        case parameter:
+            l.report(v.name.Pos(), UNUSED_PARAMETER, "parameter %s is never used", v.name.Value)
+        }
+    }
+
+    l.scope = outer
+}
+
+// Statements after a return, throw, break or continue never run
+func (l *linter) unreachable(statements []ast.Statement) {
+    for i, s := range statements[:max(len(statements)-1, 0)] {
+        switch s.(type) {
+        case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BreakStatement, *ast.ContinueStatement:
+            l.report(statements[i+1].Pos(), UNREACHABLE_CODE, "unreachable code after %s", s.TokenLiteral())
+            return
+        }
+    }
+}
+
+// The value of true, false and any number of ! in front of them
+func constant(e ast.Expression) (bool, bool) {
+    switch e := e.(type) {
+    case *ast.Boolean:
+        return e.Value, true
+    case *ast.PrefixExpression:
+        if value, ok := constant(e.Right); ok && e.Operator == "!" {
+            return !value, true
+        }
+    }
+    return false, false
+}
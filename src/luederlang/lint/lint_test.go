@@ -0,0 +1,89 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fun(x) { x * 2 }; print(f(1))", []string{}},
+		{"let len = 1; let f = fun(print) { print }", []string{
+			"1:5: len shadows the builtin len (shadowed-builtin)",
+			"1:26: print shadows the builtin print (shadowed-builtin)",
+		}},
+		// a let can't reuse a parameter or a name from around the function
+		{"let f = fun(a) { let a = 1; a }", []string{
+			"1:22: a shadows the parameter a (shadowed-variable)",
+		}},
+		{"let x = 1; let f = fun(x) { let g = fun() { for (x in [1]) { x } }; g() + x }; f(x)", []string{
+			"1:24: x shadows x from an outer scope (shadowed-variable)",
+			"1:50: x shadows x from an outer scope (shadowed-variable)",
+		}},
+		{"let f = fun(a, b) { let c = 1; let d = 2; a + d }", []string{
+			"1:16: parameter b is never used (unused-parameter)",
+			"1:25: c is declared but never used (unused-variable)",
+		}},
+		// assigning isn't using, closures and later functions are
+		{"let f = fun() { let x = 0; x = 1; let y = 0; let g = fun() { y + h() }; let h = fun() { 1 }; g }", []string{
+			"1:21: x is declared but never used (unused-variable)",
+		}},
+		// globals, loop variables and names starting with _ aren't reported
+		{"let unused = 1; let f = fun(_x) { for (v in [1]) {}; try { 1 } catch (e) {} }", []string{}},
+		{"let f = fun(x) { if (x) { let y = 1 }; y }", []string{}},
+		{"let f = fun(x) { return x; print(x); return 1 }", []string{
+			"1:28: unreachable code after return (unreachable-code)",
+		}},
+		{"while (true) { break; print(1) } throw \"x\"; 1", []string{
+			"1:23: unreachable code after break (unreachable-code)",
+			"1:45: unreachable code after throw (unreachable-code)",
+		}},
+		{"if (true) { 1 } else if (!true) { 2 }; if (x == true) { 3 }", []string{
+			"1:5: if condition is always true (constant-condition)",
+			"1:26: if condition is always false (constant-condition)",
+		}},
+	}
+
+	for _, tt := range tests {
+		issues, errs := Lint("", tt.input)
+		if len(errs) != 0 {
+			t.Errorf("Lint(%q) failed: %s", tt.input, errs[0])
+			continue
+		}
+		got := []string{}
+		for _, issue := range issues {
+			got = append(got, issue.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("Lint(%q) wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	input := `let len = 1 // lint:ignore shadowed-builtin
+// lint:ignore unused-parameter, unused-variable
+let f = fun(a) { let b = 1; 2 }
+let g = fun(a) { 3 } // lint:ignore unreachable-code
+let print = 1
+`
+
+	issues, errs := Lint("ignore.lueder", input)
+	if len(errs) != 0 {
+		t.Fatalf("Lint failed: %s", errs[0])
+	}
+	got := []string{}
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	expected := []string{
+		"ignore.lueder:4:13: parameter a is never used (unused-parameter)",
+		"ignore.lueder:5:5: print shadows the builtin print (shadowed-builtin)",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong issues.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
@@ -0,0 +1,303 @@
+package lsp
+
+import (
+    "luederlang/ast"
+    "luederlang/lexer"
+    "luederlang/parser"
+    "luederlang/token"
+    "luederlang/typechecker"
+    "sort"
+    "strings"
+    "unicode/utf8"
+)
+
+// Where a name comes from. kind is the keyword that declared it, or one of
+// parameter, for, catch and import.
+type declaration struct {
+    name  *ast.Identifier
+    kind  string
+    value ast.Expression // nil unless a declaration statement made it
+    // the function literal whose scope it is in, nil at the top level
+    scope *ast.FunctionLiteral
+}
+
+// An open file and what the server worked out about it
+type document struct {
+    uri         string
+    diagnostics []Diagnostic
+
+    // from the last update, the program is what the parser recovered when
+    // the text has syntax errors
+    lines   []string
+    program *ast.Program
+    tokens  []token.Token
+    types   map[*ast.Identifier]typechecker.Type
+    // the declaration every identifier names, declarations name themselves
+    refs   map[*ast.Identifier]*declaration
+    idents []*ast.Identifier // the keys of refs, to find them by position
+    // the declarations each function literal's scope holds, nil for the top level
+    scopes map[*ast.FunctionLiteral][]*declaration
+}
+
+func newDocument(uri string, text string) *document {
+    d := &document{uri: uri}
+    d.update(text)
+    return d
+}
+
+// Parses the new text. Syntax errors become diagnostics, names still resolve
+// in what the parser recovered but only a program without them gets types.
+func (d *document) update(text string) {
+    d.diagnostics = []Diagnostic{}
+    d.lines = strings.Split(text, "\n")
+
+    p := parser.New(lexer.New(text))
+    d.program = p.ParseProgram()
+    d.types = map[*ast.Identifier]typechecker.Type{}
+    if len(p.ErrorList()) != 0 {
+        for _, err := range p.ErrorList() {
+            d.diagnostics = append(d.diagnostics, diagnostic(d.lines, err.Pos, err.Message, "parser"))
+        }
+    } else {
+        checker := typechecker.New()
+        for _, err := range checker.Check(d.program) {
+            d.diagnostics = append(d.diagnostics, diagnostic(d.lines, err.Pos, err.Message, "typechecker"))
+        }
+        d.types = checker.Types
+    }
+
+    d.tokens = []token.Token{}
+    l := lexer.New(text)
+    for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+        d.tokens = append(d.tokens, tok)
+    }
+    d.resolve()
+}
+
+func diagnostic(lines []string, pos token.Position, message string, source string) Diagnostic {
+    start := toPosition(lines, pos)
+    end := start
+    end.Character++
+    return Diagnostic{Range: Range{start, end}, Severity: SeverityError, Source: source, Message: message}
+}
+
+// token positions are lines and bytes from one
+func toPosition(lines []string, pos token.Position) Position {
+    line := pos.Line - 1
+    if line < 0 || line >= len(lines) {
+        return Position{Line: max(line, 0)}
+    }
+    text := lines[line]
+    column := min(max(pos.Column-1, 0), len(text))
+    return Position{Line: line, Character: utf16Length(text[:column])}
+}
+
+func fromPosition(lines []string, pos Position) token.Position {
+    if pos.Line < 0 || pos.Line >= len(lines) {
+        return token.Position{Line: pos.Line + 1, Column: 1}
+    }
+    text := lines[pos.Line]
+    units, column := 0, 0
+    for column < len(text) && units < pos.Character {
+        r, size := utf8.DecodeRuneInString(text[column:])
+        units += utf16Length(string(r))
+        column += size
+    }
+    return token.Position{Line: pos.Line + 1, Column: column + 1}
+}
+
+func utf16Length(s string) int {
+    n := 0
+    for _, r := range s {
+        n++
+        if r >= 0x10000 {
+            n++
+        }
+    }
+    return n
+}
+
+// The range text covers when it starts at start
+func (d *document) textRange(start token.Position, text string) Range {
+    end := start
+    end.Column += len(text)
+    return Range{toPosition(d.lines, start), toPosition(d.lines, end)}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+    return d.textRange(ident.Pos(), ident.Value)
+}
+
+// The identifier at pos, nil if there is none
+func (d *document) identAt(pos Position) *ast.Identifier {
+    at := fromPosition(d.lines, pos)
+    for _, ident := range d.idents {
+        p := ident.Pos()
+        if p.Line == at.Line && at.Column >= p.Column && at.Column <= p.Column+len(ident.Value) {
+            return ident
+        }
+    }
+    return nil
+}
+
+// Just past the } that closes block
+func (d *document) blockEnd(block *ast.BlockStatement) token.Position {
+    depth := 0
+    for _, tok := range d.tokens {
+        if tok.Pos.Line < block.Pos().Line || (tok.Pos.Line == block.Pos().Line && tok.Pos.Column < block.Pos().Column) {
+            continue
+        }
+        switch tok.Type {
+        case token.LPAREN, token.LBRACKET, token.LBRACE:
+            depth++
+        case token.RPAREN, token.RBRACKET, token.RBRACE:
+            depth--
+            if depth == 0 {
+                end := tok.Pos
+                end.Column++
+                return end
+            }
+        }
+    }
+    return token.Position{Line: len(d.lines), Column: len(d.lines[len(d.lines)-1]) + 1}
+}
+
+// Whether pos is inside of fl
+func (d *document) contains(fl *ast.FunctionLiteral, pos token.Position) bool {
+    start, end := fl.Pos(), d.blockEnd(fl.Body)
+    after := pos.Line > start.Line || (pos.Line == start.Line && pos.Column >= start.Column)
+    before := pos.Line < end.Line || (pos.Line == end.Line && pos.Column <= end.Column)
+    return after && before
+}
+
+// The declarations visible at pos, inner ones first
+func (d *document) visible(pos token.Position) []*declaration {
+    seen := map[string]bool{}
+    names := []*declaration{}
+    add := func(decls []*declaration) {
+        for _, decl := range decls {
+            if !seen[decl.name.Value] {
+                seen[decl.name.Value] = true
+                names = append(names, decl)
+            }
+        }
+    }
+
+    functions := []*ast.FunctionLiteral{}
+    for fl := range d.scopes {
+        if fl != nil && d.contains(fl, pos) {
+            functions = append(functions, fl)
+        }
+    }
+    // they are nested in each other, the innermost one starts last
+    sort.Slice(functions, func(i, j int) bool {
+        a, b := functions[i].Pos(), functions[j].Pos()
+        return a.Line > b.Line || (a.Line == b.Line && a.Column > b.Column)
+    })
+    for _, fl := range functions {
+        add(d.scopes[fl])
+    }
+    add(d.scopes[nil])
+    return names
+}
+
+// Scopes work like in the evaluator, only function bodies get one
+type scope struct {
+    names map[string]*declaration
+    fn    *ast.FunctionLiteral
+    outer *scope
+}
+
+// Works out what every identifier in the program refers to
+func (d *document) resolve() {
+    d.refs = map[*ast.Identifier]*declaration{}
+    d.idents = []*ast.Identifier{}
+    d.scopes = map[*ast.FunctionLiteral][]*declaration{}
+
+    s := &scope{names: map[string]*declaration{}}
+    d.hoist(s, d.program)
+    d.walk(s, d.program)
+}
+
+func (d *document) reference(ident *ast.Identifier, decl *declaration) {
+    d.refs[ident] = decl
+    d.idents = append(d.idents, ident)
+}
+
+func (d *document) declare(s *scope, name *ast.Identifier, kind string, value ast.Expression) {
+    decl, ok := s.names[name.Value]
+    // declaring a name again in the same scope changes the same variable
+    if !ok {
+        decl = &declaration{name: name, kind: kind, value: value, scope: s.fn}
+        s.names[name.Value] = decl
+        d.scopes[s.fn] = append(d.scopes[s.fn], decl)
+    }
+    d.reference(name, decl)
+}
+
+// Declares every name node binds up front, functions can use names declared
+// further down
+func (d *document) hoist(s *scope, node ast.Node) {
+    ast.Inspect(node, func(n ast.Node) bool {
+        switch n := n.(type) {
+        case *ast.FunctionLiteral:
+            return false
+        case *ast.ForInStatement:
+            d.declare(s, n.Variable, "for", nil)
+        case *ast.TryStatement:
+            if n.CatchVariable != nil {
+                d.declare(s, n.CatchVariable, "catch", nil)
+            }
+        case *ast.ImportStatement:
+            d.declare(s, n.Name, "import", nil)
+        case ast.Statement:
+            if name, value, typeName, ok := ast.DeclarationOf(n); ok {
+                if typeName == "" {
+                    typeName = "let"
+                }
+                d.declare(s, name, typeName, value)
+            }
+        }
+        return true
+    })
+}
+
+func (d *document) walk(s *scope, node ast.Node) {
+    ast.Inspect(node, func(n ast.Node) bool {
+        switch n := n.(type) {
+        case *ast.FunctionLiteral:
+            inner := &scope{names: map[string]*declaration{}, fn: n, outer: s}
+            d.scopes[n] = []*declaration{}
+            for _, param := range n.Parameters {
+                d.declare(inner, param, "parameter", nil)
+            }
+            d.hoist(inner, n.Body)
+            d.walk(inner, n.Body)
+            return false
+        case *ast.Identifier:
+            for at := s; at != nil; at = at.outer {
+                if decl, ok := at.names[n.Value]; ok {
+                    d.reference(n, decl)
+                    break
+                }
+            }
+        case *ast.ForInStatement:
+            d.walk(s, n.Iterable)
+            d.walk(s, n.Body)
+            return false
+        case *ast.TryStatement:
+            d.walk(s, n.Body)
+            d.walk(s, n.Catch)
+            d.walk(s, n.Finally)
+            return false
+        case *ast.ImportStatement:
+            return false
+        case ast.Statement:
+            if _, value, _, ok := ast.DeclarationOf(n); ok {
+                d.walk(s, value)
+                return false
+            }
+        }
+        return true
+    })
+}
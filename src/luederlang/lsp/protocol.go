@@ -0,0 +1,106 @@
+package lsp
+
+// The parts of the LSP types the server uses. Lines and characters count from
+// zero, characters in UTF-16 code units.
+
+type Position struct {
+    Line      int `json:"line"`
+    Character int `json:"character"`
+}
+
+type Range struct {
+    Start Position `json:"start"`
+    End   Position `json:"end"`
+}
+
+type Location struct {
+    URI   string `json:"uri"`
+    Range Range  `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+    URI string `json:"uri"`
+}
+
+type TextDocumentPositionParams struct {
+    TextDocument TextDocumentIdentifier `json:"textDocument"`
+    Position     Position               `json:"position"`
+}
+
+type DidOpenTextDocumentParams struct {
+    TextDocument struct {
+        URI  string `json:"uri"`
+        Text string `json:"text"`
+    } `json:"textDocument"`
+}
+
+// Only full syncs, so the last change is the whole document
+type DidChangeTextDocumentParams struct {
+    TextDocument   TextDocumentIdentifier `json:"textDocument"`
+    ContentChanges []struct {
+        Text string `json:"text"`
+    } `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+    TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DocumentSymbolParams struct {
+    TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+const (
+    SeverityError   = 1
+    SeverityWarning = 2
+)
+
+type Diagnostic struct {
+    Range    Range  `json:"range"`
+    Severity int    `json:"severity"`
+    Source   string `json:"source"`
+    Message  string `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+    URI         string       `json:"uri"`
+    Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type MarkupContent struct {
+    Kind  string `json:"kind"`
+    Value string `json:"value"`
+}
+
+type Hover struct {
+    Contents MarkupContent `json:"contents"`
+    Range    Range         `json:"range"`
+}
+
+const (
+    CompletionFunction = 3
+    CompletionVariable = 6
+    CompletionModule   = 9
+    CompletionKeyword  = 14
+)
+
+type CompletionItem struct {
+    Label  string `json:"label"`
+    Kind   int    `json:"kind"`
+    Detail string `json:"detail,omitempty"`
+}
+
+const (
+    SymbolModule   = 2
+    SymbolFunction = 12
+    SymbolVariable = 13
+)
+
+type DocumentSymbol struct {
+    Name           string           `json:"name"`
+    Detail         string           `json:"detail,omitempty"`
+    Kind           int              `json:"kind"`
+    Range          Range            `json:"range"`
+    SelectionRange Range            `json:"selectionRange"`
+    Children       []DocumentSymbol `json:"children,omitempty"`
+}
@@ -0,0 +1,70 @@
+package lsp
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// JSON-RPC 2.0 as LSP frames it: a Content-Length header, a blank line, then
+// that many bytes of JSON
+
+// A request has an ID and a Method, a notification only the Method
+type message struct {
+    ID     json.RawMessage `json:"id,omitempty"`
+    Method string          `json:"method"`
+    Params json.RawMessage `json:"params,omitempty"`
+}
+
+type responseError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+const (
+    parseError     = -32700
+    methodNotFound = -32601
+    invalidParams  = -32602
+)
+
+func readMessage(in *bufio.Reader) ([]byte, error) {
+    length := -1
+    for {
+        line, err := in.ReadString('\n')
+        if err != nil {
+            return nil, err
+        }
+        line = strings.TrimRight(line, "\r\n")
+        if line == "" {
+            break
+        }
+        name, value, ok := strings.Cut(line, ":")
+        if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+            length, err = strconv.Atoi(strings.TrimSpace(value))
+            if err != nil {
+                return nil, fmt.Errorf("bad Content-Length %q", value)
+            }
+        }
+    }
+    if length < 0 {
+        return nil, fmt.Errorf("message without a Content-Length")
+    }
+
+    body := make([]byte, length)
+    if _, err := io.ReadFull(in, body); err != nil {
+        return nil, err
+    }
+    return body, nil
+}
+
+func writeMessage(out io.Writer, v interface{}) error {
+    body, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+    return err
+}
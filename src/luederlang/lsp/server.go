@@ -0,0 +1,282 @@
+package lsp
+
+import (
+    "bufio"
+    "encoding/json"
+    "io"
+    "luederlang/ast"
+    "luederlang/evaluator"
+    "luederlang/token"
+    "sort"
+)
+
+// A language server for .lueder files that talks LSP over in and out. It
+// keeps the open documents in memory and reparses them on every change.
+type Server struct {
+    in  *bufio.Reader
+    out io.Writer
+
+    docs     map[string]*document
+    shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+    return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+// Handles messages until the client says exit or closes in. Returns the exit
+// code, which is 1 unless the client asked for a shutdown first.
+func (s *Server) Run() int {
+    for {
+        body, err := readMessage(s.in)
+        if err != nil {
+            break
+        }
+
+        var msg message
+        if err := json.Unmarshal(body, &msg); err != nil {
+            s.replyError(json.RawMessage("null"), parseError, err.Error())
+            continue
+        }
+        if msg.Method == "exit" {
+            break
+        }
+        s.handle(&msg)
+    }
+
+    if s.shutdown {
+        return 0
+    }
+    return 1
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}) {
+    writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, message string) {
+    err := responseError{Code: code, Message: message}
+    writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "id": id, "error": err})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+    writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *Server) handle(msg *message) {
+    var result interface{}
+    var err error
+
+    switch msg.Method {
+    case "initialize":
+        result = map[string]interface{}{
+            "capabilities": map[string]interface{}{
+                "textDocumentSync":       1, // the whole document on every change
+                "definitionProvider":     true,
+                "hoverProvider":          true,
+                "completionProvider":     map[string]interface{}{},
+                "documentSymbolProvider": true,
+            },
+            "serverInfo": map[string]string{"name": "luederlang"},
+        }
+    case "shutdown":
+        s.shutdown = true
+    case "textDocument/didOpen":
+        var params DidOpenTextDocumentParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            doc := newDocument(params.TextDocument.URI, params.TextDocument.Text)
+            s.docs[doc.uri] = doc
+            s.publish(doc)
+        }
+    case "textDocument/didChange":
+        var params DidChangeTextDocumentParams
+        err = json.Unmarshal(msg.Params, &params)
+        doc := s.docs[params.TextDocument.URI]
+        if err == nil && doc != nil && len(params.ContentChanges) > 0 {
+            doc.update(params.ContentChanges[len(params.ContentChanges)-1].Text)
+            s.publish(doc)
+        }
+    case "textDocument/didClose":
+        var params DidCloseTextDocumentParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            delete(s.docs, params.TextDocument.URI)
+            s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+        }
+    case "textDocument/definition":
+        var params TextDocumentPositionParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            result = s.definition(params)
+        }
+    case "textDocument/hover":
+        var params TextDocumentPositionParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            result = s.hover(params)
+        }
+    case "textDocument/completion":
+        var params TextDocumentPositionParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            result = s.completion(params)
+        }
+    case "textDocument/documentSymbol":
+        var params DocumentSymbolParams
+        if err = json.Unmarshal(msg.Params, &params); err == nil {
+            result = s.symbols(params)
+        }
+    default:
+        // notifications nobody handles are fine to drop, requests need an answer
+        if msg.ID != nil {
+            s.replyError(msg.ID, methodNotFound, "method not found: "+msg.Method)
+        }
+        return
+    }
+
+    if msg.ID == nil {
+        return
+    }
+    if err != nil {
+        s.replyError(msg.ID, invalidParams, err.Error())
+        return
+    }
+    s.reply(msg.ID, result)
+}
+
+func (s *Server) publish(doc *document) {
+    s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: doc.uri, Diagnostics: doc.diagnostics})
+}
+
+// The document and the identifier at the position, nil for either if there is none
+func (s *Server) lookup(params TextDocumentPositionParams) (*document, *ast.Identifier) {
+    doc := s.docs[params.TextDocument.URI]
+    if doc == nil || doc.program == nil {
+        return nil, nil
+    }
+    return doc, doc.identAt(params.Position)
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) interface{} {
+    doc, ident := s.lookup(params)
+    if ident == nil {
+        return nil
+    }
+    decl := doc.refs[ident]
+    return Location{URI: doc.uri, Range: doc.identRange(decl.name)}
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) interface{} {
+    doc, ident := s.lookup(params)
+    if doc == nil {
+        return nil
+    }
+
+    var text string
+    var r Range
+    if ident != nil {
+        text, r = doc.describe(doc.refs[ident]), doc.identRange(ident)
+    } else if name, pos, ok := doc.builtinAt(params.Position); ok {
+        text, r = "(builtin) "+name, doc.textRange(pos, name)
+    } else {
+        return nil
+    }
+    return Hover{Contents: MarkupContent{Kind: "markdown", Value: "```\n" + text + "\n```"}, Range: r}
+}
+
+// e.g. int x: int, or (parameter) f: fun(int) int
+func (d *document) describe(decl *declaration) string {
+    text := decl.kind + " " + decl.name.Value
+    switch decl.kind {
+    case "parameter", "for", "catch":
+        text = "(" + decl.kind + ") " + decl.name.Value
+    case "import":
+        return text
+    }
+    if t, ok := d.types[decl.name]; ok {
+        text += ": " + t.String()
+    }
+    return text
+}
+
+// A builtin that isn't shadowed at pos, for hover
+func (d *document) builtinAt(pos Position) (string, token.Position, bool) {
+    at := fromPosition(d.lines, pos)
+    for _, tok := range d.tokens {
+        if tok.Type != token.IDENT || tok.Pos.Line != at.Line || at.Column < tok.Pos.Column || at.Column > tok.Pos.Column+len(tok.Literal) {
+            continue
+        }
+        if _, ok := evaluator.LookupBuiltin(tok.Literal); ok {
+            return tok.Literal, tok.Pos, true
+        }
+    }
+    return "", token.Position{}, false
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) interface{} {
+    items := []CompletionItem{}
+    seen := map[string]bool{}
+
+    if doc := s.docs[params.TextDocument.URI]; doc != nil && doc.program != nil {
+        for _, decl := range doc.visible(fromPosition(doc.lines, params.Position)) {
+            item := CompletionItem{Label: decl.name.Value, Kind: CompletionVariable, Detail: doc.describe(decl)}
+            if _, ok := decl.value.(*ast.FunctionLiteral); ok {
+                item.Kind = CompletionFunction
+            } else if decl.kind == "import" {
+                item.Kind = CompletionModule
+            }
+            items = append(items, item)
+            seen[decl.name.Value] = true
+        }
+    }
+    for _, name := range evaluator.BuiltinNames() {
+        if !seen[name] {
+            items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "(builtin) " + name})
+        }
+    }
+    keywords := token.Keywords()
+    sort.Strings(keywords)
+    for _, keyword := range keywords {
+        items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+    }
+    return items
+}
+
+func (s *Server) symbols(params DocumentSymbolParams) interface{} {
+    doc := s.docs[params.TextDocument.URI]
+    if doc == nil || doc.program == nil {
+        return []DocumentSymbol{}
+    }
+    return doc.symbolsIn(doc.program.Statements)
+}
+
+// Declarations among statements, a function's children are the ones in its body
+func (d *document) symbolsIn(statements []ast.Statement) []DocumentSymbol {
+    symbols := []DocumentSymbol{}
+    for _, s := range statements {
+        if bad, ok := s.(*ast.BadStatement); ok {
+            s = bad.Partial
+        }
+        if export, ok := s.(*ast.ExportStatement); ok {
+            s = export.Statement
+        }
+        if imp, ok := s.(*ast.ImportStatement); ok {
+            r := d.identRange(imp.Name)
+            symbols = append(symbols, DocumentSymbol{Name: imp.Name.Value, Detail: imp.Path, Kind: SymbolModule, Range: r, SelectionRange: r})
+            continue
+        }
+        name, value, _, ok := ast.DeclarationOf(s)
+        if !ok {
+            continue
+        }
+
+        symbol := DocumentSymbol{Name: name.Value, Kind: SymbolVariable, SelectionRange: d.identRange(name)}
+        symbol.Range = Range{toPosition(d.lines, s.Pos()), symbol.SelectionRange.End}
+        if t, ok := d.types[name]; ok {
+            symbol.Detail = t.String()
+        }
+        if fl, ok := value.(*ast.FunctionLiteral); ok {
+            symbol.Kind = SymbolFunction
+            symbol.Range.End = toPosition(d.lines, d.blockEnd(fl.Body))
+            symbol.Children = d.symbolsIn(fl.Body.Statements)
+        }
+        symbols = append(symbols, symbol)
+    }
+    return symbols
+}
@@ -0,0 +1,285 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///ryan.lueder"
+
+const source = `import "lib.lueder" as lib
+int x = 1
+let add = fun(int a, b) float {
+    let sum = a + b
+    sum + x
+}
+print(add(x, 2))
+`
+
+// Sends the messages to a server and returns what it wrote back, in order
+func script(t *testing.T, messages ...map[string]interface{}) []map[string]interface{} {
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if code := NewServer(&in, &out).Run(); code != 0 {
+		t.Errorf("wrong exit code. got=%d", code)
+	}
+
+	replies := []map[string]interface{}{}
+	reader := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(reader)
+		if err != nil {
+			break
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatalf("bad reply %q: %v", body, err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func request(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"id": id, "method": method, "params": params}
+}
+
+func notification(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"method": method, "params": params}
+}
+
+func open(text string) map[string]interface{} {
+	return notification("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "lueder", "version": 1, "text": text},
+	})
+}
+
+func at(id int, method string, line int, character int) map[string]interface{} {
+	return request(id, method, map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+	})
+}
+
+// Wraps the messages in the handshake and the shutdown
+func session(t *testing.T, messages ...map[string]interface{}) []map[string]interface{} {
+	all := []map[string]interface{}{request(0, "initialize", map[string]interface{}{}), notification("initialized", map[string]interface{}{})}
+	all = append(all, messages...)
+	all = append(all, request(99, "shutdown", nil), notification("exit", nil))
+
+	replies := script(t, all...)
+	if len(replies) < 2 {
+		t.Fatalf("expected at least the initialize and shutdown replies. got=%v", replies)
+	}
+	if _, ok := replies[0]["result"].(map[string]interface{})["capabilities"]; !ok {
+		t.Errorf("initialize has no capabilities. got=%v", replies[0])
+	}
+	return replies[1 : len(replies)-1]
+}
+
+// A JSON value as compact text, to compare it with what the test expects
+func compact(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestDiagnostics(t *testing.T) {
+	replies := session(t,
+		open("let x = ;"),
+		notification("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": "let x = 1;\nint y = \"a\""}},
+		}),
+		notification("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 3},
+			"contentChanges": []map[string]string{{"text": "let x = 1;"}},
+		}),
+	)
+
+	expected := []string{
+		`[{"message":"no prefix parse function for ; found","range":{"end":{"character":9,"line":0},"start":{"character":8,"line":0}},"severity":1,"source":"parser"}]`,
+		`[{"message":"cannot assign string to int y","range":{"end":{"character":9,"line":1},"start":{"character":8,"line":1}},"severity":1,"source":"typechecker"}]`,
+		`[]`,
+	}
+	if len(replies) != len(expected) {
+		t.Fatalf("wrong number of messages. expected=%d, got=%v", len(expected), replies)
+	}
+	for i, reply := range replies {
+		if reply["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("expected diagnostics. got=%v", reply)
+		}
+		got := compact(reply["params"].(map[string]interface{})["diagnostics"])
+		if got != expected[i] {
+			t.Errorf("wrong diagnostics.\nexpected=%s\ngot=     %s", expected[i], got)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line      int
+		character int
+		expected  string
+	}{
+		{4, 11, `{"end":{"character":5,"line":1},"start":{"character":4,"line":1}}`},  // x in the body
+		{4, 5, `{"end":{"character":11,"line":3},"start":{"character":8,"line":3}}`},  // sum
+		{3, 14, `{"end":{"character":19,"line":2},"start":{"character":18,"line":2}}`}, // a
+		{6, 7, `{"end":{"character":7,"line":2},"start":{"character":4,"line":2}}`},    // add
+		{1, 4, `{"end":{"character":5,"line":1},"start":{"character":4,"line":1}}`},    // x itself
+		{6, 1, `null`}, // print is a builtin
+	}
+
+	messages := []map[string]interface{}{open(source)}
+	for i, tt := range tests {
+		messages = append(messages, at(i+1, "textDocument/definition", tt.line, tt.character))
+	}
+	replies := session(t, messages...)[1:]
+
+	for i, tt := range tests {
+		result := replies[i]["result"]
+		got := "null"
+		if result != nil {
+			location := result.(map[string]interface{})
+			if location["uri"] != uri {
+				t.Errorf("wrong uri. got=%v", location["uri"])
+			}
+			got = compact(location["range"])
+		}
+		if got != tt.expected {
+			t.Errorf("definition at %d:%d wrong.\nexpected=%s\ngot=     %s", tt.line, tt.character, tt.expected, got)
+		}
+	}
+}
+
+func TestDefinitionWithSyntaxErrors(t *testing.T) {
+	text := "let x = 1\nlet y = (x +\nlet z = y"
+	replies := session(t, open(text), at(1, "textDocument/definition", 1, 9), at(2, "textDocument/definition", 2, 8))
+	if len(replies[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})) != 1 {
+		t.Errorf("expected one diagnostic. got=%v", replies[0])
+	}
+
+	// both in and after the statement that doesn't parse
+	expected := []string{
+		`{"end":{"character":5,"line":0},"start":{"character":4,"line":0}}`,
+		`{"end":{"character":5,"line":1},"start":{"character":4,"line":1}}`,
+	}
+	for i, reply := range replies[1:] {
+		location, ok := reply["result"].(map[string]interface{})
+		if !ok {
+			t.Errorf("no definition. got=%v", reply)
+			continue
+		}
+		if got := compact(location["range"]); got != expected[i] {
+			t.Errorf("definition wrong.\nexpected=%s\ngot=     %s", expected[i], got)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line      int
+		character int
+		expected  string
+	}{
+		{1, 4, "int x: int"},
+		{2, 5, "let add: fun(int, any) float"},
+		{3, 14, "(parameter) a: int"},
+		{3, 18, "(parameter) b: any"},
+		{4, 6, "let sum: any"},
+		{0, 24, "import lib"},
+		{6, 2, "(builtin) print"},
+		{6, 13, ""},
+	}
+
+	messages := []map[string]interface{}{open(source)}
+	for i, tt := range tests {
+		messages = append(messages, at(i+1, "textDocument/hover", tt.line, tt.character))
+	}
+	replies := session(t, messages...)[1:]
+
+	for i, tt := range tests {
+		got := ""
+		if hover, ok := replies[i]["result"].(map[string]interface{}); ok {
+			got = hover["contents"].(map[string]interface{})["value"].(string)
+			got = strings.TrimSuffix(strings.TrimPrefix(got, "```\n"), "\n```")
+		}
+		if got != tt.expected {
+			t.Errorf("hover at %d:%d wrong. expected=%q, got=%q", tt.line, tt.character, tt.expected, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	replies := session(t, open(source), at(1, "textDocument/completion", 4, 4), at(2, "textDocument/completion", 6, 0))[1:]
+
+	labels := func(reply map[string]interface{}) string {
+		names := []string{}
+		for _, item := range reply["result"].([]interface{}) {
+			names = append(names, item.(map[string]interface{})["label"].(string))
+		}
+		return " " + strings.Join(names, " ") + " "
+	}
+
+	inside, outside := labels(replies[0]), labels(replies[1])
+	for _, name := range []string{"a", "b", "sum", "add", "x", "lib", "len", "print", "while"} {
+		if !strings.Contains(inside, " "+name+" ") {
+			t.Errorf("%s missing from the completions inside of add:%s", name, inside)
+		}
+	}
+	if !strings.HasPrefix(inside, " a b sum ") {
+		t.Errorf("the innermost names should come first. got=%s", inside)
+	}
+	for _, name := range []string{"a", "sum"} {
+		if strings.Contains(outside, " "+name+" ") {
+			t.Errorf("%s is out of scope but was completed:%s", name, outside)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	replies := session(t, open(source), request(1, "textDocument/documentSymbol", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+	}))[1:]
+
+	var describe func(symbols []interface{}) string
+	describe = func(symbols []interface{}) string {
+		parts := []string{}
+		for _, s := range symbols {
+			symbol := s.(map[string]interface{})
+			r := symbol["range"].(map[string]interface{})
+			part := fmt.Sprintf("%s(%v) %v-%v", symbol["name"], symbol["kind"],
+				r["start"].(map[string]interface{})["line"], r["end"].(map[string]interface{})["line"])
+			if children, ok := symbol["children"].([]interface{}); ok {
+				part += " [" + describe(children) + "]"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	expected := "lib(2) 0-0, x(13) 1-1, add(12) 2-5 [sum(13) 3-3]"
+	if got := describe(replies[0]["result"].([]interface{})); got != expected {
+		t.Errorf("wrong symbols.\nexpected=%s\ngot=     %s", expected, got)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	replies := session(t, request(1, "textDocument/rename", map[string]interface{}{}), notification("$/cancelRequest", map[string]interface{}{}))
+	if len(replies) != 1 {
+		t.Fatalf("expected one reply. got=%v", replies)
+	}
+	if code := replies[0]["error"].(map[string]interface{})["code"]; code != float64(methodNotFound) {
+		t.Errorf("wrong error code. got=%v", code)
+	}
+}
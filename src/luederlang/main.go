@@ -2,39 +2,271 @@ package main
 
 import (
 	"fmt"
+    "flag"
     "io"
     "os"
+    "strings"
 	"luederlang/repl"
 	"luederlang/lexer"
 	"luederlang/parser"
+    "luederlang/compiler"
     "luederlang/evaluator"
+    "luederlang/formatter"
+    "luederlang/lint"
+    "luederlang/lsp"
     "luederlang/object"
+    "luederlang/testrunner"
+    "luederlang/token"
+    "luederlang/typechecker"
+    "luederlang/vm"
 )
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+var engine = flag.String("engine", "tree", "how to run files: tree (the evaluator) or vm (bytecode)")
+var maxDepth = flag.Int("max-depth", evaluator.MaxCallDepth, "how deep calls can nest before a RecursionError")
+
+func printParserErrors(out io.Writer, input string, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, token.FormatError(input, err.Pos, err.Message))
 	}
 }
 
-func executeFile(file string) {
+func printTypeErrors(out io.Writer, input string, errors []*typechecker.TypeError) {
+	for _, err := range errors {
+		io.WriteString(out, token.FormatError(input, err.Pos, err.Message))
+	}
+}
+
+// The error with its kind, then the calls it unwound through. Errors from
+// inside a module point into that module's file.
+func printRuntimeError(out io.Writer, filename string, input string, err *object.Error) {
+    if err.Pos.Filename != filename {
+        source, readErr := os.ReadFile(err.Pos.Filename)
+        input = string(source)
+        if readErr != nil {
+            input = ""
+        }
+    }
+    io.WriteString(out, token.FormatError(input, err.Pos, err.Describe()))
+    for _, line := range err.TraceLines() {
+        io.WriteString(out, "\t"+line+"\n")
+    }
+}
+
+// Returns false if the file did not parse or stopped with an error
+func executeFile(filename string, input string) bool {
     env := object.NewEnvironment()
-    l := lexer.New(file)
+    l := lexer.NewFile(filename, input)
     p := parser.New(l)
 
     program := p.ParseProgram()
     if len(p.Errors()) != 0 {
-        for _, msg := range p.Errors() {
-            io.WriteString(os.Stdout, "\t"+msg+"\n")
+        printParserErrors(os.Stderr, input, p.ErrorList())
+        return false
+    }
+
+    if errors := typechecker.New().Check(program); len(errors) != 0 {
+        printTypeErrors(os.Stderr, input, errors)
+        return false
+    }
+
+    var result object.Object
+    if *engine == "vm" {
+        c := compiler.New()
+        if err := c.Compile(program); err != nil {
+            compileErr := err.(*compiler.CompileError)
+            io.WriteString(os.Stderr, token.FormatError(input, compileErr.Pos, compileErr.Message))
+            return false
+        }
+        result = vm.New(c.Bytecode()).Run()
+    } else {
+        result = evaluator.Eval(program, env)
+    }
+
+    if err, ok := result.(*object.Error); ok {
+        printRuntimeError(os.Stderr, filename, input, err)
+        return false
+    }
+    return true
+}
+
+// luederlang test [--junit report.xml] [paths], returns the exit code
+func runTests(args []string) int {
+    flags := flag.NewFlagSet("test", flag.ExitOnError)
+    junit := flags.String("junit", "", "also write the results as JUnit XML to this file")
+    flags.Parse(args)
+
+    paths := flags.Args()
+    if len(paths) == 0 {
+        paths = []string{"."}
+    }
+    files, err := testrunner.Discover(paths)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+    if len(files) == 0 {
+        fmt.Println("no test files")
+        return 0
+    }
+
+    results := []testrunner.Result{}
+    passed, failed := 0, 0
+    for _, file := range files {
+        for _, r := range testrunner.Run(file) {
+            results = append(results, r)
+            name := r.Name + " (" + r.Pos.String() + ")"
+            if r.Name == "" {
+                name = r.File
+            }
+            if r.Passed() {
+                passed++
+                fmt.Printf("PASS %s\n", name)
+                continue
+            }
+            failed++
+            fmt.Printf("FAIL %s\n", name)
+            for _, line := range strings.Split(strings.TrimRight(r.Failure, "\n"), "\n") {
+                fmt.Printf("    %s\n", line)
+            }
+        }
+    }
+    fmt.Printf("%d passed, %d failed\n", passed, failed)
+
+    if *junit != "" {
+        f, err := os.Create(*junit)
+        if err == nil {
+            err = testrunner.WriteJUnit(f, results)
+            f.Close()
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            return 1
+        }
+    }
+
+    if failed > 0 {
+        return 1
+    }
+    return 0
+}
+
+// luederlang fmt [-w] [-d] [paths], returns the exit code. Without paths it
+// formats stdin to stdout.
+func formatFiles(args []string) int {
+    flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+    write := flags.Bool("w", false, "write the result back to the files instead of printing it")
+    diff := flags.Bool("d", false, "print a diff of the changes instead of the formatted files")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 {
+        if *write {
+            fmt.Fprintln(os.Stderr, "can't use -w on stdin")
+            return 2
+        }
+        input, err := io.ReadAll(os.Stdin)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            return 1
+        }
+        return formatFile("<stdin>", string(input), false, *diff)
+    }
+
+    files, err := formatter.Files(flags.Args())
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+    code := 0
+    for _, file := range files {
+        input, err := os.ReadFile(file)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            code = 1
+            continue
         }
-        return
+        code = max(code, formatFile(file, string(input), *write, *diff))
+    }
+    return code
+}
+
+func formatFile(filename string, input string, write bool, diff bool) int {
+    output, errors := formatter.Format(filename, input)
+    if len(errors) != 0 {
+        printParserErrors(os.Stderr, input, errors)
+        return 1
+    }
+
+    if diff {
+        fmt.Print(formatter.Diff(filename, input, output))
+    }
+    if write && output != input {
+        if err := os.WriteFile(filename, []byte(output), 0644); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            return 1
+        }
+    }
+    if !write && !diff {
+        fmt.Print(output)
     }
+    return 0
+}
 
-    evaluator.Eval(program, env)
+// luederlang lint [paths], returns the exit code
+func lintFiles(args []string) int {
+    paths := args
+    if len(paths) == 0 {
+        paths = []string{"."}
+    }
+    files, err := formatter.Files(paths)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+
+    code := 0
+    for _, file := range files {
+        input, err := os.ReadFile(file)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            code = 1
+            continue
+        }
+        issues, errors := lint.Lint(file, string(input))
+        if len(errors) != 0 {
+            printParserErrors(os.Stderr, string(input), errors)
+            code = 1
+            continue
+        }
+        for _, issue := range issues {
+            fmt.Println(issue)
+            code = 1
+        }
+    }
+    return code
 }
 
 func main() {
-    args := os.Args[1:]
+    flag.Parse()
+    if *engine != "tree" && *engine != "vm" {
+        fmt.Fprintf(os.Stderr, "unknown engine %q, use tree or vm\n", *engine)
+        os.Exit(2)
+    }
+    evaluator.MaxCallDepth = *maxDepth
+
+    args := flag.Args()
+    if len(args) > 0 && args[0] == "test" {
+        os.Exit(runTests(args[1:]))
+    }
+    if len(args) > 0 && args[0] == "fmt" {
+        os.Exit(formatFiles(args[1:]))
+    }
+    if len(args) > 0 && args[0] == "lint" {
+        os.Exit(lintFiles(args[1:]))
+    }
+    if len(args) > 0 && args[0] == "lsp" {
+        os.Exit(lsp.NewServer(os.Stdin, os.Stdout).Run())
+    }
+
     switch len(args) {
     case 0:
         fmt.Printf("type help() for help\n")
@@ -43,8 +275,11 @@ func main() {
         bytes, err := os.ReadFile(args[0])
         if err != nil {
             fmt.Println(err)
+            os.Exit(1)
+        }
+        if !executeFile(args[0], string(bytes)) {
+            os.Exit(1)
         }
-        executeFile(string(bytes))
     default:
         fmt.Println(fmt.Errorf("Too many arguments: %v\n", args))
     } 
@@ -0,0 +1,154 @@
+package modules
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "luederlang/ast"
+    "luederlang/lexer"
+    "luederlang/object"
+    "luederlang/parser"
+    "luederlang/typechecker"
+)
+
+// Finds, parses and caches the files import statements refer to. Running a
+// module is up to the engine, the tree walker and the vm each bring their own.
+
+const Extension = ".lueder"
+
+// Directories searched after the importing file's own, separated like PATH
+const PathVariable = "LUEDERPATH"
+
+// Runs a parsed module and returns where its variables ended up
+type RunFunc func(program *ast.Program) (object.Scope, *object.Error)
+
+type Loader struct {
+    run RunFunc
+    cache map[string]*object.Module
+
+    // the files being imported right now, outermost first, to catch cycles
+    loading []string
+}
+
+func NewLoader(run RunFunc) *Loader {
+    return &Loader{run: run, cache: make(map[string]*object.Module)}
+}
+
+// Imports path for the file from, which is "" for code that is not in a file.
+// Every file runs once, importing it again hands back the same module.
+func (l *Loader) Import(path string, from string) (*object.Module, *object.Error) {
+    filename, ok := Resolve(path, from)
+    if !ok {
+        return nil, importError("cannot find module %q", path)
+    }
+    if module, ok := l.cache[filename]; ok {
+        return module, nil
+    }
+
+    // the file that started it all is part of any cycle too
+    if len(l.loading) == 0 && from != "" {
+        if root, err := filepath.Abs(from); err == nil {
+            l.loading = append(l.loading, root)
+            defer func() { l.loading = l.loading[:0] }()
+        }
+    }
+
+    for i, loading := range l.loading {
+        if loading == filename {
+            chain := []string{}
+            for _, f := range append(l.loading[i:], filename) {
+                chain = append(chain, display(f))
+            }
+            return nil, importError("import cycle: %s", strings.Join(chain, " -> "))
+        }
+    }
+
+    program, err := parse(filename)
+    if err != nil {
+        return nil, err
+    }
+
+    l.loading = append(l.loading, filename)
+    scope, err := l.run(program)
+    l.loading = l.loading[:len(l.loading)-1]
+    if err != nil {
+        return nil, err
+    }
+
+    module := &object.Module{Name: display(filename), Exports: ast.ExportedNames(program), Scope: scope}
+    l.cache[filename] = module
+    return module, nil
+}
+
+// The absolute path of the file an import refers to. The extension may be
+// left out. Paths starting with ./ or ../ are relative to the importing file,
+// others are looked for next to it first and then on LUEDERPATH.
+func Resolve(path string, from string) (string, bool) {
+    if filepath.Ext(path) == "" {
+        path += Extension
+    }
+
+    candidates := []string{}
+    if filepath.IsAbs(path) {
+        candidates = append(candidates, path)
+    } else {
+        dir := "."
+        if from != "" {
+            dir = filepath.Dir(from)
+        }
+        candidates = append(candidates, filepath.Join(dir, path))
+
+        if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+            for _, dir := range filepath.SplitList(os.Getenv(PathVariable)) {
+                if dir != "" {
+                    candidates = append(candidates, filepath.Join(dir, path))
+                }
+            }
+        }
+    }
+
+    for _, candidate := range candidates {
+        if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+            if abs, err := filepath.Abs(candidate); err == nil {
+                return abs, true
+            }
+        }
+    }
+    return "", false
+}
+
+// Modules have to parse and type check before they run, like the main file
+func parse(filename string) (*ast.Program, *object.Error) {
+    input, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, importError("cannot read module %s: %s", display(filename), err)
+    }
+
+    p := parser.New(lexer.NewFile(display(filename), string(input)))
+    program := p.ParseProgram()
+    if errors := p.ErrorList(); len(errors) != 0 {
+        return nil, importError("%s: %s", errors[0].Pos, errors[0].Message)
+    }
+    if errors := typechecker.New().Check(program); len(errors) != 0 {
+        return nil, importError("%s: %s", errors[0].Pos, errors[0].Message)
+    }
+    return program, nil
+}
+
+// Paths below the working directory are shown relative to it
+func display(filename string) string {
+    wd, err := os.Getwd()
+    if err != nil {
+        return filename
+    }
+    rel, err := filepath.Rel(wd, filename)
+    if err != nil || strings.HasPrefix(rel, "..") {
+        return filename
+    }
+    return rel
+}
+
+func importError(format string, a ...interface{}) *object.Error {
+    return &object.Error{Kind: object.IMPORT_ERROR, Message: fmt.Sprintf(format, a...)}
+}
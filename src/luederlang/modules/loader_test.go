@@ -0,0 +1,46 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.lueder", "near.lueder", "lib/far.lueder", "lib/near.lueder"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(""), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv(PathVariable, filepath.Join(dir, "missing")+string(os.PathListSeparator)+filepath.Join(dir, "lib"))
+
+	from := filepath.Join(dir, "main.lueder")
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"near.lueder", "near.lueder"},
+		{"near", "near.lueder"},
+		{"far", "lib/far.lueder"},
+		{"./far", ""},
+		{"lib/far", "lib/far.lueder"},
+		{filepath.Join(dir, "lib", "near"), "lib/near.lueder"},
+		{"nowhere", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := Resolve(tt.path, from)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("%s | should not resolve. got=%s", tt.path, got)
+			}
+			continue
+		}
+		if want := filepath.Join(dir, tt.expected); !ok || got != want {
+			t.Errorf("%s | wrong file. expected=%s, got=%s", tt.path, want, got)
+		}
+	}
+}
@@ -2,12 +2,13 @@ package object
 
 type Environment struct {
     store map[string]Object;
+    types map[string]string // declared types, only for names declared with one
     outer *Environment
 }
 
 func NewEnvironment() *Environment {
     s := make(map[string]Object)
-    return &Environment{store: s, outer: nil}
+    return &Environment{store: s, types: make(map[string]string), outer: nil}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -17,10 +18,21 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Set(name string, value Object) Object {
-    e.store[name] = value 
+    e.store[name] = value
     return value
 }
 
+// Declares name in this environment, typeName is "" for let and the type
+// later assignments have to keep otherwise
+func (e *Environment) Declare(name string, value Object, typeName string) Object {
+    if typeName == "" {
+        delete(e.types, name)
+    } else {
+        e.types[name] = typeName
+    }
+    return e.Set(name, value)
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
     obj, ok := e.store[name]
     if !ok && e.outer != nil {
@@ -29,3 +41,28 @@ func (e *Environment) Get(name string) (Object, bool) {
     return obj, ok
 }
 
+// The environment name is bound in, walking outwards like Get
+func (e *Environment) Resolve(name string) (*Environment, bool) {
+    if _, ok := e.store[name]; ok {
+        return e, true
+    }
+    if e.outer == nil {
+        return nil, false
+    }
+    return e.outer.Resolve(name)
+}
+
+func (e *Environment) DeclaredType(name string) string {
+    return e.types[name]
+}
+
+// Every name visible from here, including the outer environments
+func (e *Environment) Names() []string {
+    names := []string{}
+    for env := e; env != nil; env = env.outer {
+        for name := range env.store {
+            names = append(names, name)
+        }
+    }
+    return names
+}
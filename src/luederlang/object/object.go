@@ -4,7 +4,11 @@ import (
     "fmt"
     "bytes"
     "strings"
+    "hash/fnv"
+    "math"
+    "math/big"
     "luederlang/ast"
+    "luederlang/token"
 )
 
 type ObjectType string
@@ -16,23 +20,78 @@ type Object interface {
 
 const (
     INTEGER_OBJ = "INTEGER"
+    BIGINT_OBJ = "BIGINT"
     BOOLEAN_OBJ = "BOOLEAN"
     FLOAT_OBJ = "FLOAT"
     NULL_OBJ = "NULL"
     STRING_OBJ = "STRING"
     LIST_OBJ = "LIST"
+    MAP_OBJ = "MAP"
     RETURN_VALUE_OBJ = "RETURN_VALUE"
+    BREAK_OBJ = "BREAK"
+    CONTINUE_OBJ = "CONTINUE"
     ERROR_OBJ = "ERROR"
+    ERROR_VALUE_OBJ = "ERROR_VALUE"
     FUNCTION_OBJ = "FUNCTION"
     BUILTIN_OBJ = "BUILTIN"
+    COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+    MODULE_OBJ = "MODULE"
 )
 
+// The name the language uses for obj's type, the one a declaration like
+// int x = 1 uses. Errors show this so they read like the type checker's.
+func TypeName(obj Object) string {
+    switch obj.Type() {
+    case INTEGER_OBJ, BIGINT_OBJ:
+        return "int"
+    case FLOAT_OBJ:
+        return "float"
+    case BOOLEAN_OBJ:
+        return "bool"
+    case STRING_OBJ:
+        return "string"
+    case FUNCTION_OBJ, BUILTIN_OBJ, COMPILED_FUNCTION_OBJ:
+        return "fun"
+    case ERROR_VALUE_OBJ:
+        return "error"
+    }
+    return strings.ToLower(string(obj.Type()))
+}
+
+// Objects that can be used as map keys
+type Hashable interface {
+    HashKey() HashKey
+}
+
+type HashKey struct {
+    Type ObjectType
+    Value uint64
+}
+
 type Integer struct {
     Value int64
 }
 
 func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) HashKey() HashKey {
+    return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// An int that outgrew int64. Math on ints switches to these when it overflows
+// and back to Integer once the result fits again, so a BigInt is never a
+// number an Integer could hold.
+type BigInt struct {
+    Value *big.Int
+}
+
+func (b *BigInt) Inspect() string { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) HashKey() HashKey {
+    h := fnv.New64a()
+    h.Write([]byte(b.Value.String()))
+    return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
 
 type String struct {
     Value string
@@ -40,6 +99,11 @@ type String struct {
 
 func (s *String) Inspect() string { return s.Value }
 func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) HashKey() HashKey {
+    h := fnv.New64a()
+    h.Write([]byte(s.Value))
+    return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
 
 type Float struct {
     Value float64
@@ -47,6 +111,18 @@ type Float struct {
 
 func (f *Float) Inspect() string { return fmt.Sprintf("%v", f.Value) }
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+// A whole float is the same key as the int it equals, since 1 == 1.0. That
+// covers -0.0 and 0.0 too.
+func (f *Float) HashKey() HashKey {
+    if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+        if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+            return (&Integer{Value: int64(f.Value)}).HashKey()
+        }
+        n, _ := big.NewFloat(f.Value).Int(nil)
+        return (&BigInt{Value: n}).HashKey()
+    }
+    return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
 
 type Boolean struct {
     Value bool
@@ -54,6 +130,171 @@ type Boolean struct {
 
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%v", b.Value) }
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) HashKey() HashKey {
+    var value uint64
+    if b.Value {
+        value = 1
+    }
+    return HashKey{Type: b.Type(), Value: value}
+}
+
+type List struct {
+    Elements []Object
+}
+
+func (l *List) Type() ObjectType { return LIST_OBJ }
+func (l *List) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range l.Elements {
+		elements = append(elements, el.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type MapPair struct {
+    Key Object
+    Value Object
+}
+
+// Map remembers insertion order so that printing and keys() are stable. Keys
+// with the same HashKey share a bucket, a key is only found if it is equal
+// to the one there, so two keys that happen to hash the same stay apart.
+type Map struct {
+    buckets map[HashKey][]*MapPair
+    order   []*MapPair
+}
+
+func NewMap() *Map {
+    return &Map{buckets: make(map[HashKey][]*MapPair)}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range m.Ordered() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (m *Map) Len() int { return len(m.order) }
+
+// The pair for key in its bucket, nil if there is none
+func (m *Map) find(key Hashable, hashed HashKey) *MapPair {
+    for _, pair := range m.buckets[hashed] {
+        if sameKey(pair.Key, key.(Object)) {
+            return pair
+        }
+    }
+    return nil
+}
+
+func (m *Map) Get(key Hashable) (MapPair, bool) {
+    if pair := m.find(key, key.HashKey()); pair != nil {
+        return *pair, true
+    }
+    return MapPair{}, false
+}
+
+// A key that is already there stays as it was, {1: "a", 1.0: "b"} is {1: "b"}
+func (m *Map) Set(key Hashable, value Object) {
+    hashed := key.HashKey()
+    if pair := m.find(key, hashed); pair != nil {
+        pair.Value = value
+        return
+    }
+    pair := &MapPair{Key: key.(Object), Value: value}
+    m.buckets[hashed] = append(m.buckets[hashed], pair)
+    m.order = append(m.order, pair)
+}
+
+func (m *Map) Delete(key Hashable) (MapPair, bool) {
+    hashed := key.HashKey()
+    pair := m.find(key, hashed)
+    if pair == nil {
+        return MapPair{}, false
+    }
+    m.buckets[hashed] = removePair(m.buckets[hashed], pair)
+    if len(m.buckets[hashed]) == 0 {
+        delete(m.buckets, hashed)
+    }
+    m.order = removePair(m.order, pair)
+    return *pair, true
+}
+
+func removePair(pairs []*MapPair, pair *MapPair) []*MapPair {
+    for i, p := range pairs {
+        if p == pair {
+            return append(pairs[:i], pairs[i+1:]...)
+        }
+    }
+    return pairs
+}
+
+// Pairs in the order they were first inserted
+func (m *Map) Ordered() []MapPair {
+    pairs := make([]MapPair, 0, len(m.order))
+    for _, pair := range m.order {
+        pairs = append(pairs, *pair)
+    }
+    return pairs
+}
+
+// Whether two map keys are the same key, which is when == says they are
+// equal. Numbers are compared exactly, whatever type they are.
+func sameKey(a, b Object) bool {
+    switch a := a.(type) {
+    case *String:
+        b, ok := b.(*String)
+        return ok && a.Value == b.Value
+    case *Boolean:
+        b, ok := b.(*Boolean)
+        return ok && a.Value == b.Value
+    case *Integer:
+        if b, ok := b.(*Integer); ok {
+            return a.Value == b.Value
+        }
+    case *Float:
+        if b, ok := b.(*Float); ok {
+            return a.Value == b.Value
+        }
+    }
+    x, xOk := exactNumber(a)
+    y, yOk := exactNumber(b)
+    if xOk && yOk {
+        return x.Cmp(y) == 0
+    }
+    // anything else is only the same key as itself
+    return a == b
+}
+
+// obj as an exact fraction, NaN and the infinities aren't one
+func exactNumber(obj Object) (*big.Rat, bool) {
+    switch obj := obj.(type) {
+    case *Integer:
+        return new(big.Rat).SetInt64(obj.Value), true
+    case *BigInt:
+        return new(big.Rat).SetInt(obj.Value), true
+    case *Float:
+        r := new(big.Rat).SetFloat64(obj.Value)
+        return r, r != nil
+    }
+    return nil, false
+}
 
 type Null struct {}
 
@@ -67,15 +308,131 @@ type ReturnValue struct {
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// Break and Continue travel up through block statements like ReturnValue
+// until the closest enclosing loop consumes them
+type Break struct {}
+
+func (b *Break) Inspect() string { return "break" }
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+type Continue struct {}
+
+func (c *Continue) Inspect() string { return "continue" }
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
+// Kinds of runtime errors, a catch can tell them apart with e["kind"]
+const (
+    TYPE_ERROR = "TypeError"
+    NAME_ERROR = "NameError"
+    INDEX_ERROR = "IndexError"
+    ARGUMENT_ERROR = "ArgumentError"
+    ZERO_DIVISION_ERROR = "ZeroDivisionError"
+    INTERNAL_ERROR = "InternalError"
+    IMPORT_ERROR = "ImportError"
+    RECURSION_ERROR = "RecursionError"
+    ASSERTION_ERROR = "AssertionError"
+    THROWN_ERROR = "Error" // throw "message"
+)
+
+// Errors unwind everything until a catch or the top of the program. Trace
+// collects the calls they unwound through, innermost first.
 type Error struct {
+    Kind string
     Message string
+    Pos token.Position // where the error happened, filled in by the evaluator
+    Trace []TraceEntry
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string { return "ERROR: " + e.Message }
 
+// "NameError: identifier not found: x"
+func (e *Error) Describe() string {
+    if e.Kind == "" {
+        return e.Message
+    }
+    return e.Kind + ": " + e.Message
+}
+
+// How many lines of a trace get printed, a deep recursion is mostly noise
+const traceLimit = 20
+
+// The trace as it gets printed. A run of the same call shows up three times
+// and a count for the rest, a trace that is still too long keeps its ends.
+func (e *Error) TraceLines() []string {
+    lines := []string{}
+    for i := 0; i < len(e.Trace); {
+        j := i
+        for j < len(e.Trace) && e.Trace[j] == e.Trace[i] {
+            j++
+        }
+        for k := i; k < j && k < i+3; k++ {
+            lines = append(lines, "in "+e.Trace[k].String())
+        }
+        if j-i > 3 {
+            lines = append(lines, fmt.Sprintf("... repeated %d more times", j-i-3))
+        }
+        i = j
+    }
+
+    if len(lines) > traceLimit {
+        trimmed := append([]string{}, lines[:traceLimit/2]...)
+        trimmed = append(trimmed, fmt.Sprintf("... %d more lines", len(lines)-traceLimit))
+        lines = append(trimmed, lines[len(lines)-traceLimit/2:]...)
+    }
+    return lines
+}
+
+// Function is "" for functions that were never bound to a name, Pos is
+// where the function was called
+type TraceEntry struct {
+    Function string
+    Pos token.Position
+}
+
+func (te TraceEntry) String() string {
+    name := te.Function
+    if name == "" {
+        name = "<anonymous>"
+    }
+    return name + " called at " + te.Pos.String()
+}
+
+// An error a catch got hold of. Unlike Error it is a plain value that does
+// not unwind anything until it is thrown again.
+type ErrorValue struct {
+    Err *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ }
+func (ev *ErrorValue) Inspect() string { return ev.Err.Describe() }
+
+// e["kind"], e["message"], e["line"], e["column"] and e["trace"]
+func (ev *ErrorValue) Field(name string) (Object, bool) {
+    switch name {
+    case "kind":
+        return &String{Value: ev.Err.Kind}, true
+    case "message":
+        return &String{Value: ev.Err.Message}, true
+    case "line":
+        return &Integer{Value: int64(ev.Err.Pos.Line)}, true
+    case "column":
+        return &Integer{Value: int64(ev.Err.Pos.Column)}, true
+    case "trace":
+        entries := []Object{}
+        for _, entry := range ev.Err.Trace {
+            entries = append(entries, &String{Value: entry.String()})
+        }
+        return &List{Elements: entries}, true
+    }
+    return nil, false
+}
+
 type Function struct {
+    Name string // the name a let gave the literal, for stack traces
     Parameters []*ast.Identifier
+    ParameterTypes []*ast.TypeAnnotation
+    ReturnType *ast.TypeAnnotation
     Body *ast.BlockStatement
     Env *Environment
 }
@@ -85,14 +442,22 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if i < len(f.ParameterTypes) && f.ParameterTypes[i] != nil {
+			params = append(params, f.ParameterTypes[i].String()+" "+p.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString("fun")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
+	if f.ReturnType != nil {
+		out.WriteString(f.ReturnType.String() + " ")
+	}
+	out.WriteString("{\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
@@ -108,7 +473,118 @@ type Builtin struct {
 func (bn *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (bi *Builtin) Inspect() string { return "built in function" }
 
+// A function lowered to bytecode by the compiler. Positions maps instruction
+// offsets back to the source so the vm can report errors like the evaluator.
+type CompiledFunction struct {
+    Instructions []byte
+    Name string
+    LocalNames []string // the parameters come first
+    NumParameters int
+    ParameterTypes []string // "" for untyped parameters
+    ReturnType string
+    Positions []InstructionPosition
+}
+
+type InstructionPosition struct {
+    Offset int
+    Pos token.Position
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for i, p := range cf.LocalNames[:cf.NumParameters] {
+		if cf.ParameterTypes[i] != "" {
+			params = append(params, cf.ParameterTypes[i]+" "+p)
+		} else {
+			params = append(params, p)
+		}
+	}
+
+	out.WriteString("fun")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	if cf.ReturnType != "" {
+		out.WriteString(cf.ReturnType + " ")
+	}
+	out.WriteString("{ <compiled> }")
 
+	return out.String()
+}
 
+// The source position of the instruction at offset
+func (cf *CompiledFunction) PositionAt(offset int) token.Position {
+    var pos token.Position
+    for _, p := range cf.Positions {
+        if p.Offset > offset {
+            break
+        }
+        pos = p.Pos
+    }
+    return pos
+}
 
+// The local variables of one call of a compiled function. Outer is the call
+// the function was created in, so closures share variables by reference the
+// same way an Environment does.
+type Locals struct {
+    Fn *CompiledFunction
+    Slots []Object
+    Outer *Locals
+}
 
+// The constants and globals of one compiled file. Closures keep the unit
+// they were created in, so a function imported from a module still sees
+// the module's globals when someone else calls it.
+type Unit struct {
+    Constants []Object
+    Globals []Object
+    GlobalNames []string
+}
+
+func (u *Unit) Get(name string) (Object, bool) {
+    for i, n := range u.GlobalNames {
+        if n == name && u.Globals[i] != nil {
+            return u.Globals[i], true
+        }
+    }
+    return nil, false
+}
+
+type Closure struct {
+    Fn *CompiledFunction
+    Free *Locals
+    Unit *Unit
+}
+
+// Closures are just functions as far as user code can tell
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string { return c.Fn.Inspect() }
+
+// Where the variables of a module live, an Environment for the tree walker
+type Scope interface {
+    Get(name string) (Object, bool)
+}
+
+// An imported file. Exports are read from its scope when they are used, so
+// importers see the module change its variables.
+type Module struct {
+    Name string // the file, as the import found it
+    Exports []string
+    Scope Scope
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string { return "<module " + m.Name + ">" }
+
+func (m *Module) Get(name string) (Object, bool) {
+    for _, export := range m.Exports {
+        if export == name {
+            return m.Scope.Get(name)
+        }
+    }
+    return nil, false
+}
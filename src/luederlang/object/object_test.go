@@ -0,0 +1,135 @@
+package object
+
+import (
+	"luederlang/token"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+
+	if (&Integer{Value: 1}).HashKey() != (&Float{Value: 1}).HashKey() {
+		t.Errorf("1 and 1.0 have different hash keys")
+	}
+	if (&Integer{Value: 1}).HashKey() == (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("1 and 1.5 have same hash keys")
+	}
+	if (&Integer{Value: math.MinInt64}).HashKey() != (&Float{Value: math.MinInt64}).HashKey() {
+		t.Errorf("the smallest int and its float have different hash keys")
+	}
+	two64, _ := new(big.Int).SetString("18446744073709551616", 10)
+	if (&BigInt{Value: two64}).HashKey() != (&Float{Value: 1 << 64}).HashKey() {
+		t.Errorf("2**64 as a big int and as a float have different hash keys")
+	}
+
+	if (&Integer{Value: 1}).HashKey() == (&Boolean{Value: true}).HashKey() {
+		t.Errorf("integer and boolean have same hash keys")
+	}
+
+	big1, _ := new(big.Int).SetString("100000000000000000000", 10)
+	big2, _ := new(big.Int).SetString("100000000000000000000", 10)
+	if (&BigInt{Value: big1}).HashKey() != (&BigInt{Value: big2}).HashKey() {
+		t.Errorf("big ints with same value have different hash keys")
+	}
+	if (&BigInt{Value: big1}).HashKey() == (&BigInt{Value: new(big.Int).Neg(big1)}).HashKey() {
+		t.Errorf("big ints with different values have same hash keys")
+	}
+
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+}
+
+func TestMapOrder(t *testing.T) {
+	m := NewMap()
+	m.Set(&String{Value: "b"}, &Integer{Value: 1})
+	m.Set(&String{Value: "a"}, &Integer{Value: 2})
+	m.Set(&String{Value: "c"}, &Integer{Value: 3})
+	m.Set(&String{Value: "b"}, &Integer{Value: 4})
+	m.Delete(&String{Value: "a"})
+
+	if m.Inspect() != "{b: 4, c: 3}" {
+		t.Errorf("map has wrong order. got=%q", m.Inspect())
+	}
+}
+
+// Every colliding key has the same HashKey
+type colliding struct{ name string }
+
+func (c *colliding) Type() ObjectType { return "COLLIDING" }
+func (c *colliding) Inspect() string  { return c.name }
+func (c *colliding) HashKey() HashKey { return HashKey{Type: "COLLIDING", Value: 1} }
+
+func TestMapHashCollisions(t *testing.T) {
+	a, b := &colliding{"a"}, &colliding{"b"}
+	m := NewMap()
+	m.Set(a, &Integer{Value: 1})
+	m.Set(b, &Integer{Value: 2})
+
+	if m.Len() != 2 || m.Inspect() != "{a: 1, b: 2}" {
+		t.Fatalf("colliding keys overwrote each other. got=%q", m.Inspect())
+	}
+	if pair, ok := m.Get(a); !ok || pair.Value.Inspect() != "1" {
+		t.Errorf("wrong value for a. got=%v %v", pair.Value, ok)
+	}
+
+	m.Delete(a)
+	if _, ok := m.Get(a); ok {
+		t.Errorf("a is still there after deleting it")
+	}
+	if pair, ok := m.Get(b); !ok || pair.Value.Inspect() != "2" {
+		t.Errorf("deleting a took b with it. got=%v %v", pair.Value, ok)
+	}
+}
+
+func TestMapNumberKeys(t *testing.T) {
+	two64, _ := new(big.Int).SetString("18446744073709551616", 10)
+	m := NewMap()
+	m.Set(&Integer{Value: 1}, &String{Value: "int"})
+	m.Set(&Float{Value: 1}, &String{Value: "float"})
+	m.Set(&BigInt{Value: two64}, &String{Value: "big"})
+
+	if m.Inspect() != "{1: float, 18446744073709551616: big}" {
+		t.Errorf("wrong map. got=%q", m.Inspect())
+	}
+	if pair, ok := m.Get(&Float{Value: 1 << 64}); !ok || pair.Value.Inspect() != "big" {
+		t.Errorf("2**64 as a float doesn't find the big int key")
+	}
+	if _, ok := m.Get(&String{Value: "1"}); ok {
+		t.Errorf("the string 1 found the int key")
+	}
+}
+
+func TestTraceLines(t *testing.T) {
+	f := TraceEntry{Function: "f", Pos: token.Position{Line: 2, Column: 3}}
+	g := TraceEntry{Function: "g", Pos: token.Position{Line: 5, Column: 1}}
+
+	err := &Error{Trace: []TraceEntry{f, f, f, f, f, f, g}}
+	expected := []string{"in f called at 2:3", "in f called at 2:3", "in f called at 2:3", "... repeated 3 more times", "in g called at 5:1"}
+	if got := err.TraceLines(); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace lines. expected=%q, got=%q", expected, got)
+	}
+
+	// alternating calls don't repeat, only the ends of the trace are kept
+	err = &Error{}
+	for i := 0; i < 30; i++ {
+		err.Trace = append(err.Trace, f, g)
+	}
+	lines := err.TraceLines()
+	if len(lines) != traceLimit+1 || lines[traceLimit/2] != "... 40 more lines" {
+		t.Errorf("trace not trimmed. got=%q", lines)
+	}
+}
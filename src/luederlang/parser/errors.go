@@ -0,0 +1,14 @@
+package parser
+
+import (
+    "luederlang/token"
+)
+
+type ParseError struct {
+    Pos     token.Position
+    Message string
+}
+
+func (e *ParseError) Error() string {
+    return e.Pos.String() + ": " + e.Message
+}
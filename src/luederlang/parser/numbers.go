@@ -0,0 +1,91 @@
+package parser
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+)
+
+// What is wrong with a number literal, offset is how many bytes into it
+type numberError struct {
+    offset  int
+    message string
+}
+
+// Checks the number literal lit and returns its digits ready for strconv,
+// without the prefix and underscores, and the base the prefix says.
+// Decimal numbers keep their . and exponent.
+func numberDigits(lit string) (string, int, *numberError) {
+    base, kind := 10, "decimal"
+    if len(lit) > 1 && lit[0] == '0' {
+        switch lit[1] {
+        case 'x', 'X':
+            base, kind = 16, "hex"
+        case 'b', 'B':
+            base, kind = 2, "binary"
+        case 'o', 'O':
+            base, kind = 8, "octal"
+        }
+    }
+    start := 0
+    if base != 10 {
+        start = 2
+    }
+
+    var digits strings.Builder
+    dot, exponent := false, false
+    for i := start; i < len(lit); i++ {
+        c := lit[i]
+        switch {
+        case isDigitOf(c, base):
+            digits.WriteByte(c)
+        case c == '_':
+            // 1_000 but not _1, 1__0, 1_ or 1_.5
+            if i == start || !isDigitOf(lit[i-1], base) || i+1 == len(lit) || !isDigitOf(lit[i+1], base) {
+                return "", 0, &numberError{i, "_ must be between digits"}
+            }
+        case base == 10 && c == '.' && !dot && !exponent:
+            dot = true
+            digits.WriteByte(c)
+        case base == 10 && c == '.':
+            if exponent {
+                return "", 0, &numberError{i, "an exponent can't have a fraction"}
+            }
+            return "", 0, &numberError{i, "a number can only have one ."}
+        case base == 10 && (c == 'e' || c == 'E') && !exponent:
+            exponent = true
+            e := i
+            digits.WriteByte('e')
+            if i+1 < len(lit) && (lit[i+1] == '+' || lit[i+1] == '-') {
+                i++
+                digits.WriteByte(lit[i])
+            }
+            if i+1 == len(lit) || !isDigitOf(lit[i+1], 10) {
+                return "", 0, &numberError{e, "exponent has no digits"}
+            }
+        default:
+            r, _ := utf8.DecodeRuneInString(lit[i:])
+            if exponent {
+                return "", 0, &numberError{i, fmt.Sprintf("invalid digit %q in exponent", r)}
+            }
+            return "", 0, &numberError{i, fmt.Sprintf("invalid digit %q in %s number", r, kind)}
+        }
+    }
+
+    if digits.Len() == 0 {
+        return "", 0, &numberError{0, fmt.Sprintf("%s has no digits", lit)}
+    }
+    return digits.String(), base, nil
+}
+
+func isDigitOf(c byte, base int) bool {
+    switch base {
+    case 2:
+        return c == '0' || c == '1'
+    case 8:
+        return c >= '0' && c <= '7'
+    case 16:
+        return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+    }
+    return c >= '0' && c <= '9'
+}
@@ -2,22 +2,25 @@ package parser
 
 import (
     "fmt"
+    "math/big"
     "strconv"
     "luederlang/ast"
     "luederlang/lexer"
     "luederlang/token"
 )
 
+// From loosest to tightest, PRODUCT after SUM so a + b * c is a + (b * c)
 const (
     _ int = iota
     LOWEST
     LOGIC
     EQUALS
     LESSGREATER
-    PRODUCT
     SUM
+    PRODUCT
     PREFIX
     CALL
+    INDEX
 )
 
 var precedences = map[token.TokenType]int{
@@ -30,10 +33,22 @@ var precedences = map[token.TokenType]int{
 	token.GT:       LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
+    token.INCREMENT: SUM,
+    token.DECREMENT: SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
     token.MOD:      PRODUCT,
 	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+}
+
+// How tightly an infix operator binds, LOWEST for tokens that aren't one
+func Precedence(t token.TokenType) int {
+    if p, ok := precedences[t]; ok {
+        return p
+    }
+    return LOWEST
 }
 
 type (
@@ -43,10 +58,26 @@ type (
 
 type Parser struct {
     l *lexer.Lexer
-    errors []string
+    errors []*ParseError
+
+    // how many loops enclose the current token, break/continue need at least one
+    loopDepth int
+    // how many blocks, export only works outside of all of them
+    blockDepth int
+
+    // set by the first syntax error of a statement until the parser has skipped
+    // to the next one, errors in between are follow-ons and get dropped
+    panicking bool
+    // where the statement being parsed starts, backUp never goes before it
+    statementStart token.Position
 
     peekToken token.Token
     curToken token.Token
+    prevToken token.Token
+    // a token backUp gave back, nextToken hands it out before lexing more
+    held *token.Token
+    // how many of the lexer's errors are reported already
+    lexerErrors int
 
     prefixParseFns map[token.TokenType]prefixParseFn
     infixParseFns map[token.TokenType]infixParseFn
@@ -55,7 +86,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
     p := &Parser{
         l: l,
-        errors: []string{},
+        errors: []*ParseError{},
     }
 
     p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -63,17 +94,23 @@ func New(l *lexer.Lexer) *Parser {
     p.registerPrefix(token.INT_LITERAL, p.parseIntLiteral)
     p.registerPrefix(token.FLOAT_LITERAL, p.parseFloatLiteral)
     p.registerPrefix(token.STRING_LITERAL, p.parseStringLiteral)
+    p.registerPrefix(token.STRING_START, p.parseInterpolatedString)
     p.registerPrefix(token.BANG, p.parsePrefixExpression)
     p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+    p.registerPrefix(token.DECREMENT, p.parseDoubleSignPrefix)
     p.registerPrefix(token.TRUE, p.parseBoolean)
     p.registerPrefix(token.FALSE, p.parseBoolean)
     p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
     p.registerPrefix(token.IF, p.parseIfExpression)
     p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+    p.registerPrefix(token.LBRACKET, p.parseListLiteral)
+    p.registerPrefix(token.LBRACE, p.parseMapLiteral)
 
     p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
+    p.registerInfix(token.INCREMENT, p.parseDoubleSignInfix)
+    p.registerInfix(token.DECREMENT, p.parseDoubleSignInfix)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
     p.registerInfix(token.MOD, p.parseInfixExpression)
@@ -87,6 +124,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 
     p.registerInfix(token.LPAREN, p.parseCallExpression)
+    p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+    p.registerInfix(token.DOT, p.parseMemberExpression)
 
     p.nextToken()
     p.nextToken()
@@ -103,14 +142,39 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) nextToken() {
+    p.prevToken = p.curToken
 	p.curToken = p.peekToken
+    if p.held != nil {
+        p.peekToken, p.held = *p.held, nil
+        return
+    }
 	p.peekToken = p.l.NextToken()
     
-    if (p.curToken.Type == token.ILLEGAL || p.peekToken.Type == token.ILLEGAL) {
-        p.illegalTokenError()
+    // every token passes through peekToken first, so this reports each one once
+    if p.peekToken.Type == token.ILLEGAL {
+        p.illegalTokenError(p.peekToken)
+    }
+    // and what the lexer found wrong with it
+    for ; p.lexerErrors < len(p.l.Errors()); p.lexerErrors++ {
+        err := p.l.Errors()[p.lexerErrors]
+        // running out of input in the middle of something ends the statement,
+        // and is often what an error before it already led to
+        if err.Unterminated {
+            p.syntaxError(err.Pos, "%s", err.Message)
+            continue
+        }
+        p.addError(err.Pos, "%s", err.Message)
     }
 }
 
+// Undoes one nextToken, only once until the next one
+func (p *Parser) backUp() {
+    held := p.peekToken
+    p.held = &held
+    p.peekToken = p.curToken
+    p.curToken = p.prevToken
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -129,24 +193,52 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// Errors formatted as "file.lueder:12:7: message"
 func (p *Parser) Errors() []string {
-	return p.errors
+    errors := []string{}
+    for _, err := range p.errors {
+        errors = append(errors, err.Error())
+    }
+	return errors
+}
+
+func (p *Parser) ErrorList() []*ParseError {
+    return p.errors
+}
+
+func (p *Parser) addError(pos token.Position, format string, a ...interface{}) {
+    p.errors = append(p.errors, &ParseError{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// Only the first syntax error of a statement is reported, see panicking
+func (p *Parser) syntaxError(pos token.Position, format string, a ...interface{}) {
+    if p.panicking {
+        return
+    }
+    p.panicking = true
+    p.addError(pos, format, a...)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.syntaxError(p.peekToken.Pos, "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+    switch t {
+    case token.ILLEGAL:
+        // nextToken already reported the illegal token itself
+        p.panicking = true
+        return
+    case token.STRING_MIDDLE, token.STRING_END:
+        p.syntaxError(p.curToken.Pos, "missing expression before } in ${...}")
+        return
+    }
+	p.syntaxError(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
-func (p *Parser) illegalTokenError() {
-	msg := fmt.Sprintf("illegal token found")
-	p.errors = append(p.errors, msg)
+func (p *Parser) illegalTokenError(tok token.Token) {
+	p.addError(tok.Pos, "illegal token %q found", tok.Literal)
 }
 
 /*
@@ -158,7 +250,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseRecoveringStatement()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -168,6 +260,66 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// Parses a statement like parseStatement. When that runs into a syntax error
+// it skips to where the next statement probably starts and returns what it
+// got as a BadStatement, so one mistake gives one error.
+func (p *Parser) parseRecoveringStatement() ast.Statement {
+    tok := p.curToken
+    outer := p.statementStart
+    p.statementStart = tok.Pos
+    stmt := p.parseStatement()
+    p.statementStart = outer
+
+    if !p.panicking {
+        return stmt
+    }
+    p.synchronize()
+    p.panicking = false
+    if bad, ok := stmt.(*ast.BadStatement); ok {
+        return bad
+    }
+    return &ast.BadStatement{Token: tok, Partial: stmt}
+}
+
+var statementKeywords = map[token.TokenType]bool{
+    token.LET: true,
+    token.INT: true,
+    token.FLOAT: true,
+    token.BOOL: true,
+    token.STRING: true,
+    token.LIST: true,
+    token.MAP: true,
+    token.RETURN: true,
+    token.WHILE: true,
+    token.FOR: true,
+    token.BREAK: true,
+    token.CONTINUE: true,
+    token.TRY: true,
+    token.IMPORT: true,
+    token.EXPORT: true,
+    token.THROW: true,
+}
+
+// Skips tokens until p.curToken is a ';' or p.peekToken is a '}', a statement
+// keyword or the first token on a line, the callers' nextToken moves on from
+// there. Blocks are skipped whole.
+func (p *Parser) synchronize() {
+    depth := 0
+    for !p.peekTokenIs(token.EOF) {
+        if depth == 0 && (p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) ||
+            statementKeywords[p.peekToken.Type] || p.peekToken.Pos.Line > p.curToken.Pos.Line) {
+            return
+        }
+        switch p.peekToken.Type {
+        case token.LBRACE:
+            depth++
+        case token.RBRACE:
+            depth--
+        }
+        p.nextToken()
+    }
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
     case token.LET:
@@ -176,29 +328,52 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseIntStatement()
     case token.FLOAT:
 		return p.parseFloatStatement()
+    case token.BOOL, token.STRING, token.LIST, token.MAP:
+        return p.parseTypedStatement()
+    case token.FUNCTION:
+        if p.peekTokenIs(token.IDENT) {
+            return p.parseTypedStatement()
+        }
+        return p.parseExpressionStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+    case token.WHILE:
+        return p.parseWhileStatement()
+    case token.FOR:
+        return p.parseForStatement()
+    case token.BREAK, token.CONTINUE:
+        return p.parseLoopControlStatement()
+    case token.TRY:
+        return p.parseTryStatement()
+    case token.IMPORT:
+        return p.parseImportStatement()
+    case token.EXPORT:
+        return p.parseExportStatement()
+    case token.THROW:
+        return p.parseThrowStatement()
     case token.IDENT:
         return p.parseAssignStatement()
+    case token.LBRACE:
+        if p.peekMapLiteral() {
+            return p.parseExpressionStatement()
+        }
+        return p.parseBlockStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseAssignStatement() ast.Statement {
-    var stmt ast.Statement
-    identToken := p.curToken
-
-	if !p.peekTokenIs(token.ASSIGN) {
+	if !p.peekAssignOperator() || p.peekDoubleSign() {
 	    return p.parseExpressionStatement()
 	}
 
-    p.nextToken() // after this line: p.curToken -> '='
-    p.nextToken() // after this line: p.curToken -> expression
+	stmt := &ast.AssignStatement{Token: p.curToken}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	stmt = &ast.LetStatement{Token: identToken}
-	stmt.(*ast.LetStatement).Name = &ast.Identifier{Token: identToken, Value: identToken.Literal}
-    stmt.(*ast.LetStatement).Value = p.parseExpression(LOWEST) 
+    p.nextToken() // after this line: p.curToken -> '=', '+=', '++', ...
+    stmt.Operator = p.curToken.Literal
+    stmt.Value = p.parseAssignValue()
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -207,7 +382,32 @@ func (p *Parser) parseAssignStatement() ast.Statement {
 	return stmt
 }
 
-func (p *Parser) parseIntStatement() *ast.IntStatement {
+var assignOperators = map[token.TokenType]bool{
+    token.ASSIGN: true,
+    token.PLUS_ASSIGN: true,
+    token.MINUS_ASSIGN: true,
+    token.ASTERISK_ASSIGN: true,
+    token.SLASH_ASSIGN: true,
+    token.MOD_ASSIGN: true,
+    token.INCREMENT: true,
+    token.DECREMENT: true,
+}
+
+func (p *Parser) peekAssignOperator() bool {
+    return assignOperators[p.peekToken.Type]
+}
+
+// p.curToken is the assignment operator, ++ and -- have no value
+func (p *Parser) parseAssignValue() ast.Expression {
+    if p.curTokenIs(token.INCREMENT) || p.curTokenIs(token.DECREMENT) {
+        return nil
+    }
+
+    p.nextToken() // after this line: p.curToken -> expression
+    return p.parseExpression(LOWEST)
+}
+
+func (p *Parser) parseIntStatement() ast.Statement {
 	stmt := &ast.IntStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -231,7 +431,7 @@ func (p *Parser) parseIntStatement() *ast.IntStatement {
 	return stmt
 }
 
-func (p *Parser) parseFloatStatement() *ast.FloatStatement {
+func (p *Parser) parseFloatStatement() ast.Statement {
 	stmt := &ast.FloatStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -255,7 +455,43 @@ func (p *Parser) parseFloatStatement() *ast.FloatStatement {
 	return stmt
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+// bool, string, list, map and fun declarations, which look just like int ones
+func (p *Parser) parseTypedStatement() ast.Statement {
+    tok := p.curToken
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	value := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+    switch tok.Type {
+    case token.BOOL:
+        return &ast.BoolStatement{Token: tok, Name: name, Value: value}
+    case token.STRING:
+        return &ast.StringStatement{Token: tok, Name: name, Value: value}
+    case token.LIST:
+        return &ast.ListStatement{Token: tok, Name: name, Value: value}
+    case token.MAP:
+        return &ast.MapStatement{Token: tok, Name: name, Value: value}
+    default:
+        return &ast.FunStatement{Token: tok, Name: name, Value: value}
+    }
+}
+
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -280,7 +516,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 }
 
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+func (p *Parser) parseReturnStatement() ast.Statement {
     // we dont need to check if current token is 'return', it is
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -295,11 +531,252 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseWhileStatement() ast.Statement {
+    stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+    stmt.Body = p.parseLoopBody()
+
+    return stmt
+}
+
+// Parses both for (init; condition; post) { } and for (x in iterable) { }
+func (p *Parser) parseForStatement() ast.Statement {
+    tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+    if p.peekTokenIs(token.SEMICOLON) {
+        p.nextToken()
+        return p.parseForClauses(tok, nil)
+    }
+
+    p.nextToken()
+
+    if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+        return p.parseForInStatement(tok)
+    }
+
+    init := p.parseStatement()
+    if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return p.parseForClauses(tok, init)
+}
+
+// p.curToken is the ';' after the init statement
+func (p *Parser) parseForClauses(tok token.Token, init ast.Statement) ast.Statement {
+    stmt := &ast.ForStatement{Token: tok, Init: init}
+
+    if !p.peekTokenIs(token.SEMICOLON) {
+        p.nextToken()
+        stmt.Condition = p.parseExpression(LOWEST)
+    }
+
+    if !p.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    if !p.peekTokenIs(token.RPAREN) {
+        p.nextToken()
+        stmt.Post = p.parseStatement()
+    }
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+    stmt.Body = p.parseLoopBody()
+
+    return stmt
+}
+
+// p.curToken is the loop variable
+func (p *Parser) parseForInStatement(tok token.Token) ast.Statement {
+    stmt := &ast.ForInStatement{Token: tok}
+    stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+    p.nextToken() // after this line: p.curToken -> 'in'
+    p.nextToken() // after this line: p.curToken -> expression
+
+    stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+    stmt.Body = p.parseLoopBody()
+
+    return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+    p.loopDepth++
+    body := p.parseBlockStatement()
+    p.loopDepth--
+
+    // loops are statements, but a ; after one is harmless
+    if p.peekTokenIs(token.SEMICOLON) {
+        p.nextToken()
+    }
+    return body
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+    var stmt ast.Statement
+    if p.curTokenIs(token.BREAK) {
+        stmt = &ast.BreakStatement{Token: p.curToken}
+    } else {
+        stmt = &ast.ContinueStatement{Token: p.curToken}
+    }
+
+    if p.loopDepth == 0 {
+        p.addError(p.curToken.Pos, "%s outside of loop", p.curToken.Literal)
+    }
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+    return stmt
+}
+
+func (p *Parser) parseTryStatement() ast.Statement {
+    stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+    stmt.Body = p.parseBlockStatement()
+
+    if p.peekTokenIs(token.CATCH) {
+        p.nextToken()
+        if !p.expectPeek(token.LPAREN) {
+            return nil
+        }
+        if !p.expectPeek(token.IDENT) {
+            return nil
+        }
+        stmt.CatchVariable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+        if !p.expectPeek(token.RPAREN) {
+            return nil
+        }
+        if !p.expectPeek(token.LBRACE) {
+            return nil
+        }
+        stmt.Catch = p.parseBlockStatement()
+    }
+
+    if p.peekTokenIs(token.FINALLY) {
+        p.nextToken()
+        if !p.expectPeek(token.LBRACE) {
+            return nil
+        }
+        stmt.Finally = p.parseBlockStatement()
+    }
+
+    // like loops, a ; after a try is harmless
+    if p.peekTokenIs(token.SEMICOLON) {
+        p.nextToken()
+    }
+
+    if stmt.Catch == nil && stmt.Finally == nil {
+        p.addError(stmt.Token.Pos, "try needs a catch or a finally")
+        return &ast.BadStatement{Token: stmt.Token, Partial: stmt}
+    }
+    return stmt
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+    stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING_LITERAL) {
+		return nil
+	}
+    stmt.Path = p.curToken.Literal
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+    stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+    return stmt
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+    stmt := &ast.ExportStatement{Token: p.curToken}
+
+    if p.blockDepth > 0 {
+        p.addError(p.curToken.Pos, "export outside of the top level")
+    }
+
+    p.nextToken()
+    stmt.Statement = p.parseStatement()
+    if p.panicking {
+        return stmt
+    }
+    if _, _, _, ok := ast.DeclarationOf(stmt.Statement); !ok {
+        p.addError(stmt.Token.Pos, "export needs a declaration like let or int")
+        return &ast.BadStatement{Token: stmt.Token, Partial: stmt}
+    }
+
+    return stmt
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+    stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+    return stmt
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+    if target, ok := stmt.Expression.(*ast.IndexExpression); ok && p.peekAssignOperator() {
+        return p.parseIndexAssignStatement(stmt.Token, target)
+    }
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -307,11 +784,71 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+func (p *Parser) parseIndexAssignStatement(tok token.Token, target *ast.IndexExpression) ast.Statement {
+    stmt := &ast.IndexAssignStatement{Token: tok, Target: target}
+
+    p.nextToken() // after this line: p.curToken -> '=', '+=', '++', ...
+    stmt.Operator = p.curToken.Literal
+    stmt.Value = p.parseAssignValue()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+    return stmt
+}
+
+// A '{' starting a statement is a map literal when it is empty or when its first
+// entry has a top level ':', otherwise it starts a block. The lexer is copied so
+// the lookahead does not consume any tokens.
+func (p *Parser) peekMapLiteral() bool {
+    if p.peekTokenIs(token.RBRACE) {
+        return true
+    }
+
+    lookahead := *p.l
+    tok := p.peekToken
+    depth := 0
+
+    for tok.Type != token.EOF {
+        switch tok.Type {
+        case token.LPAREN, token.LBRACKET, token.LBRACE:
+            depth++
+        case token.RPAREN, token.RBRACKET:
+            depth--
+        case token.RBRACE:
+            if depth == 0 {
+                return false
+            }
+            depth--
+        case token.SEMICOLON:
+            if depth == 0 {
+                return false
+            }
+        case token.COLON:
+            if depth == 0 {
+                return true
+            }
+        }
+        tok = lookahead.NextToken()
+    }
+
+    return false
+}
+
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+        bad := &ast.BadExpression{Token: p.curToken}
+        // a ) or } here most likely ends something around the missing
+        // expression and a keyword starts the next statement, either way it
+        // is left for whatever comes after
+        stops := closers[p.curToken.Type] || statementKeywords[p.curToken.Type]
+        if stops && p.curToken.Pos != p.statementStart && p.held == nil {
+            p.backUp()
+        }
+		return bad
 	}
 	leftExp := prefix()
 
@@ -329,7 +866,21 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+var closers = map[token.TokenType]bool{
+    token.RPAREN: true,
+    token.RBRACKET: true,
+    token.RBRACE: true,
+    token.SEMICOLON: true,
+    token.COMMA: true,
+    token.STRING_MIDDLE: true,
+    token.STRING_END: true,
+    token.EOF: true,
+}
+
 func (p *Parser) peekPrecedence() int {
+    if (p.peekTokenIs(token.INCREMENT) || p.peekTokenIs(token.DECREMENT)) && !p.peekDoubleSign() {
+        return LOWEST
+    }
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
 	}
@@ -360,14 +911,43 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return lit
 }
 
+// p.curToken is the STRING_START, the lexer has split the string up already
+func (p *Parser) parseInterpolatedString() ast.Expression {
+    s := &ast.InterpolatedString{Token: p.curToken}
+    s.Parts = []ast.Expression{&ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}}
+
+    for !p.curTokenIs(token.STRING_END) {
+        if p.peekTokenIs(token.STRING_MIDDLE) || p.peekTokenIs(token.STRING_END) {
+            p.syntaxError(p.peekToken.Pos, "empty ${} in string")
+            s.Parts = append(s.Parts, &ast.BadExpression{Token: p.peekToken})
+        } else {
+            p.nextToken()
+            s.Parts = append(s.Parts, p.parseExpression(LOWEST))
+        }
+
+        if !p.peekTokenIs(token.STRING_MIDDLE) && !p.peekTokenIs(token.STRING_END) {
+            p.syntaxError(p.peekToken.Pos, "missing } in ${...}")
+            return s
+        }
+        p.nextToken()
+        s.Parts = append(s.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+    }
+
+    return s
+}
+
 func (p *Parser) parseIntLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+    digits, base, ok := p.numberDigits()
+    if !ok {
+        return &ast.BadExpression{Token: p.curToken}
+    }
+	value, err := strconv.ParseInt(digits, base, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+        // the digits are fine, so it's too big for an int64
+        n, _ := new(big.Int).SetString(digits, base)
+		return &ast.BigIntegerLiteral{Token: p.curToken, Value: n}
 	}
 
 	lit.Value = value
@@ -378,11 +958,14 @@ func (p *Parser) parseIntLiteral() ast.Expression {
 func (p *Parser) parseFloatLiteral() ast.Expression {
 	lit := &ast.FloatLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+    digits, _, ok := p.numberDigits()
+    if !ok {
+        return &ast.BadExpression{Token: p.curToken}
+    }
+	value, err := strconv.ParseFloat(digits, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.addError(p.curToken.Pos, "%s is too big for a float", p.curToken.Literal)
+		return &ast.BadExpression{Token: p.curToken}
 	}
 
 	lit.Value = value
@@ -390,6 +973,18 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	return lit
 }
 
+// The digits of the number in curToken, or an error at the char that is wrong
+func (p *Parser) numberDigits() (string, int, bool) {
+    digits, base, err := numberDigits(p.curToken.Literal)
+    if err != nil {
+        pos := p.curToken.Pos
+        pos.Column += err.offset
+        p.addError(pos, "%s", err.message)
+        return "", 0, false
+    }
+    return digits, base, true
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -403,6 +998,41 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// Whether p.peekToken is a -- or ++ that is two signs, like in 5--3, and not
+// the end of an x-- statement. It is when an operand follows on the same line.
+func (p *Parser) peekDoubleSign() bool {
+    if !p.peekTokenIs(token.INCREMENT) && !p.peekTokenIs(token.DECREMENT) || p.held != nil {
+        return false
+    }
+    lookahead := *p.l
+    next := lookahead.NextToken()
+    return p.prefixParseFns[next.Type] != nil && next.Pos.Line == p.peekToken.Pos.Line
+}
+
+// Turns the -- or ++ in p.curToken into its two signs
+func (p *Parser) splitDoubleSign() {
+    var sign token.TokenType = token.MINUS
+    if p.curTokenIs(token.INCREMENT) {
+        sign = token.PLUS
+    }
+    second := token.Token{Type: sign, Literal: string(sign), Pos: p.curToken.Pos}
+    second.Pos.Column++
+    held := p.peekToken
+    p.held = &held
+    p.peekToken = second
+    p.curToken = token.Token{Type: sign, Literal: string(sign), Pos: p.curToken.Pos}
+}
+
+func (p *Parser) parseDoubleSignPrefix() ast.Expression {
+    p.splitDoubleSign()
+    return p.parsePrefixExpression()
+}
+
+func (p *Parser) parseDoubleSignInfix(left ast.Expression) ast.Expression {
+    p.splitDoubleSign()
+    return p.parseInfixExpression(left)
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -426,9 +1056,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 	exp := p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
+    // without the ) the statement ends up bad, but what is inside stays
+	p.expectPeek(token.RPAREN)
 
 	return exp
 }
@@ -456,6 +1085,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+        if p.peekTokenIs(token.IF) {
+            p.nextToken()
+
+            elseIf, ok := p.parseIfExpression().(*ast.IfExpression)
+            if !ok {
+                return nil
+            }
+
+            expression.ElseIf = elseIf
+            return expression
+        }
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -471,15 +1112,22 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block.Statements = []ast.Statement{}
 
 	p.nextToken()
+	p.blockDepth++
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseRecoveringStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	p.blockDepth--
+
+    if p.curTokenIs(token.EOF) {
+        p.syntaxError(block.Token.Pos, "this { is never closed")
+    }
+
 	return block
 }
 
@@ -490,71 +1138,179 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+
+    if token.IsTypeName(p.peekToken.Type) {
+        p.nextToken()
+        lit.ReturnType = p.parseTypeAnnotation()
+    }
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+    // a loop around the function literal does not make break legal inside of it
+    loopDepth := p.loopDepth
+    p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+    p.loopDepth = loopDepth
+
+    ast.MarkTailCalls(lit)
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// Parameters are either "x" or "int x", types is nil wherever there is no type
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
 	identifiers := []*ast.Identifier{}
+	types := []*ast.TypeAnnotation{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, types
 	}
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident, typ := p.parseFunctionParameter()
 	identifiers = append(identifiers, ident)
+	types = append(types, typ)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident, typ := p.parseFunctionParameter()
 		identifiers = append(identifiers, ident)
+		types = append(types, typ)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	return identifiers, types
+}
+
+func (p *Parser) parseFunctionParameter() (*ast.Identifier, *ast.TypeAnnotation) {
+    var typ *ast.TypeAnnotation
+    if token.IsTypeName(p.curToken.Type) && p.peekTokenIs(token.IDENT) {
+        typ = p.parseTypeAnnotation()
+        p.nextToken()
+    }
+
+    return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, typ
+}
+
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+    return &ast.TypeAnnotation{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
 
-	if p.peekTokenIs(token.RPAREN) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	p.expectPeek(end)
+
+	return list
+}
+
+func (p *Parser) parseListLiteral() ast.Expression {
+    list := &ast.ListLiteral{Token: p.curToken}
+    list.Elements = p.parseExpressionList(token.RBRACKET)
+    return list
+}
+
+// xs[i] is an IndexExpression, xs[a:b] (either side optional) is a SliceExpression
+// lib.name is the same as lib["name"]
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+    tok := p.curToken
+
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
-	return args
+    name := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+    return &ast.IndexExpression{Token: tok, Left: left, Index: name}
 }
 
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+    tok := p.curToken
+    var start ast.Expression
+
+    if !p.peekTokenIs(token.COLON) {
+        p.nextToken()
+        start = p.parseExpression(LOWEST)
+
+        if p.peekTokenIs(token.RBRACKET) {
+            p.nextToken()
+            return &ast.IndexExpression{Token: tok, Left: left, Index: start}
+        }
+    }
+
+    if !p.expectPeek(token.COLON) {
+        return nil
+    }
+
+    exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+    if !p.peekTokenIs(token.RBRACKET) {
+        p.nextToken()
+        exp.End = p.parseExpression(LOWEST)
+    }
+
+    if !p.expectPeek(token.RBRACKET) {
+        return nil
+    }
+
+    return exp
+}
+
+func (p *Parser) parseMapLiteral() ast.Expression {
+    m := &ast.MapLiteral{Token: p.curToken}
+    m.Keys = []ast.Expression{}
+    m.Values = []ast.Expression{}
+
+    for !p.peekTokenIs(token.RBRACE) {
+        p.nextToken()
+        key := p.parseExpression(LOWEST)
+
+        if !p.expectPeek(token.COLON) {
+            return nil
+        }
+
+        p.nextToken()
+        value := p.parseExpression(LOWEST)
+
+        m.Keys = append(m.Keys, key)
+        m.Values = append(m.Values, value)
+
+        if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+
+    if !p.expectPeek(token.RBRACE) {
+        return nil
+    }
+
+    return m
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"luederlang/ast"
 	"luederlang/lexer"
+	"strings"
 	"testing"
 )
 
@@ -207,6 +208,92 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0xFF", int64(255)},
+		{"0Xff", int64(255)},
+		{"0b1010", int64(10)},
+		{"0o17", int64(15)},
+		{"1_000_000", int64(1000000)},
+		{"0x_FF", nil},
+		{"010", int64(10)},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"9223372036854775808", "9223372036854775808"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+		{".5", 0.5},
+		{"1.", 1.0},
+		{"1e9", 1e9},
+		{"2.5E-3", 2.5e-3},
+		{"1_0.0_1e+0_1", 100.1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expected == nil {
+			if len(p.Errors()) == 0 {
+				t.Errorf("%s | expected an error", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+		switch expected := tt.expected.(type) {
+		case int64:
+			literal, ok := exp.(*ast.IntegerLiteral)
+			if !ok || literal.Value != expected {
+				t.Errorf("%s | expected integer %d. got=%#v", tt.input, expected, exp)
+			}
+		case string:
+			literal, ok := exp.(*ast.BigIntegerLiteral)
+			if !ok || literal.Value.String() != expected {
+				t.Errorf("%s | expected big integer %s. got=%#v", tt.input, expected, exp)
+			}
+		case float64:
+			literal, ok := exp.(*ast.FloatLiteral)
+			if !ok || literal.Value != expected {
+				t.Errorf("%s | expected float %g. got=%#v", tt.input, expected, exp)
+			}
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"x = 1.2.3", "1:8: a number can only have one ."},
+		{"x = 0b102", "1:9: invalid digit '2' in binary number"},
+		{"x = 0o8", "1:7: invalid digit '8' in octal number"},
+		{"x = 0xFG", "1:8: invalid digit 'G' in hex number"},
+		{"x = 12ab", "1:7: invalid digit 'a' in decimal number"},
+		{"x = 0x", "1:5: 0x has no digits"},
+		{"x = 1__0", "1:6: _ must be between digits"},
+		{"x = 1_", "1:6: _ must be between digits"},
+		{"x = 1_.5", "1:6: _ must be between digits"},
+		{"x = 1e", "1:6: exponent has no digits"},
+		{"x = 1e-", "1:6: exponent has no digits"},
+		{"x = 1e5.5", "1:8: an exponent can't have a fraction"},
+		{"x = 1e5e5", "1:8: invalid digit 'e' in exponent"},
+		{"x = 1e400", "1:5: 1e400 is too big for a float"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("%q | expected error %q. got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -317,6 +404,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"5--3",
+			"(5 - (-3))",
+		},
+		{
+			"--a * b",
+			"((-(-a)) * b)",
+		},
+		{
+			"a--b * c",
+			"(a - ((-b) * c))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -341,6 +440,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
 		},
+		{
+			"a - b % c * d",
+			"(a - ((b % c) * d))",
+		},
 		{
 			"3 + 4; -5 * 5",
 			"(3 + 4)((-5) * 5)",
@@ -409,6 +512,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"xs[1:-1] + xs[:2]",
+			"((xs[1:(-1)]) + (xs[:2]))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -976,5 +1091,756 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedParts int
+	}{
+		{`"a ${x} b"`, "a ${x} b", 3},
+		{`"${x + 1}${f(y)}"`, "${(x + 1)}${f(y)}", 5},
+		{`"a ${"b ${c}"}"`, "a ${b ${c}}", 3},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		s, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+		if len(s.Parts) != tt.expectedParts {
+			t.Errorf("%s | wrong number of parts. expected=%d, got=%d", tt.input, tt.expectedParts, len(s.Parts))
+		}
+		if s.String() != tt.expected {
+			t.Errorf("%s | String() wrong. expected=%q, got=%q", tt.input, tt.expected, s.String())
+		}
+	}
+
+	p := New(lexer.New(`"a ${} b"`))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != "1:6: empty ${} in string" {
+		t.Errorf("wrong errors for an empty ${}. got=%q", errors)
+	}
+}
+
+
+
+
+func TestParsingListLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	list, ok := stmt.Expression.(*ast.ListLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ListLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(list.Elements) != 3 {
+		t.Fatalf("len(list.Elements) not 3. got=%d", len(list.Elements))
+	}
+
+	testIntegerLiteral(t, list.Elements[0], 1)
+	testInfixExpression(t, list.Elements[1], 2, "*", 2)
+	testInfixExpression(t, list.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyListLiterals(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	list, ok := stmt.Expression.(*ast.ListLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ListLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(list.Elements) != 0 {
+		t.Fatalf("len(list.Elements) not 0. got=%d", len(list.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myList[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myList") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart interface{}
+		expectedEnd   interface{}
+	}{
+		{"xs[1:2]", 1, 2},
+		{"xs[:2]", nil, 2},
+		{"xs[1:]", 1, nil},
+		{"xs[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, sliceExp.Left, "xs") {
+			return
+		}
+
+		if tt.expectedStart == nil {
+			if sliceExp.Start != nil {
+				t.Errorf("%s | sliceExp.Start not nil. got=%s", tt.input, sliceExp.Start)
+			}
+		} else {
+			testLiteralExpression(t, sliceExp.Start, tt.expectedStart)
+		}
+
+		if tt.expectedEnd == nil {
+			if sliceExp.End != nil {
+				t.Errorf("%s | sliceExp.End not nil. got=%s", tt.input, sliceExp.End)
+			}
+		} else {
+			testLiteralExpression(t, sliceExp.End, tt.expectedEnd)
+		}
+	}
+}
+
+func TestParsingMapLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, 3: 0 + 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	m, ok := stmt.Expression.(*ast.MapLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.MapLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(m.Keys) != 3 || len(m.Values) != 3 {
+		t.Fatalf("map has wrong number of pairs. got=%d", len(m.Keys))
+	}
+
+	if m.String() != `{one: 1, two: 2, 3: (0 + 3)}` {
+		t.Errorf("map.String() wrong. got=%q", m.String())
+	}
+
+	testIntegerLiteral(t, m.Values[0], 1)
+	testIntegerLiteral(t, m.Values[1], 2)
+	testIntegerLiteral(t, m.Keys[2], 3)
+	testInfixExpression(t, m.Values[2], 0, "+", 3)
+}
+
+func TestParsingEmptyMapLiteral(t *testing.T) {
+	input := "let m = {}; {}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if m, ok := let.Value.(*ast.MapLiteral); !ok || len(m.Keys) != 0 {
+		t.Errorf("let value is not an empty ast.MapLiteral. got=%T", let.Value)
+	}
+
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	if m, ok := stmt.Expression.(*ast.MapLiteral); !ok || len(m.Keys) != 0 {
+		t.Errorf("exp is not an empty ast.MapLiteral. got=%T", stmt.Expression)
+	}
+}
+
+func TestMapLiteralOrBlockStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": 1}`, "*ast.ExpressionStatement"},
+		{`{x: [1, 2][0:1]}`, "*ast.ExpressionStatement"},
+		{`{ let x = 1; x }`, "*ast.BlockStatement"},
+		{`{ xs[1:2] }`, "*ast.BlockStatement"},
+		{`{ print({"a": 1}) }`, "*ast.BlockStatement"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s | program has wrong number of statements. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		if got := fmt.Sprintf("%T", program.Statements[0]); got != tt.expected {
+			t.Errorf("%s | wrong statement type. expected=%s, got=%s",
+				tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIndexAssignStatement(t *testing.T) {
+	input := `m["a" + "b"] = 5 * 2;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.IndexAssignStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.IndexAssignStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Target.Left, "m") {
+		return
+	}
+
+	testInfixExpression(t, stmt.Value, 5, "*", 2)
+
+	if program.String() != "m[(a + b)] = (5 * 2);" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedName     string
+		expectedOperator string
+		expectedString   string
+	}{
+		{"x = 5;", "x", "=", "x = 5;"},
+		{"x += y * 2", "x", "+=", "x += (y * 2);"},
+		{"total -= 1", "total", "-=", "total -= 1;"},
+		{"x *= 2", "x", "*=", "x *= 2;"},
+		{"x /= 2", "x", "/=", "x /= 2;"},
+		{"x %= 2", "x", "%=", "x %= 2;"},
+		{"i++;", "i", "++", "i++;"},
+		{"i--", "i", "--", "i--;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s | program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("%s | stmt is not *ast.AssignStatement. got=%T", tt.input, program.Statements[0])
+		}
+		if stmt.Name.Value != tt.expectedName {
+			t.Errorf("%s | wrong name. got=%q", tt.input, stmt.Name.Value)
+		}
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("%s | wrong operator. got=%q", tt.input, stmt.Operator)
+		}
+		if program.String() != tt.expectedString {
+			t.Errorf("%s | program.String() wrong. got=%q", tt.input, program.String())
+		}
+	}
+}
+
+func TestCompoundIndexAssignStatement(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"xs[0] += 2", "xs[0] += 2;"},
+		{`m["a"]++`, "m[a]++;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if _, ok := program.Statements[0].(*ast.IndexAssignStatement); !ok {
+			t.Fatalf("%s | stmt is not *ast.IndexAssignStatement. got=%T", tt.input, program.Statements[0])
+		}
+		if program.String() != tt.expectedString {
+			t.Errorf("%s | program.String() wrong. got=%q", tt.input, program.String())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x = x + 1; }`
 
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; next(i)) { print(i); }", "for(let i = 0; (i < 10); next(i)) print(i)"},
+		{"for (;;) { break; }", "for(; ; ) break;"},
+		{"for (int i = 0; ; ) { continue }", "for(int i = 0; ; ) continue;"},
+		{"for (; i < 3;) { }", "for(; (i < 3); ) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s | program has wrong number of statements. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		if _, ok := program.Statements[0].(*ast.ForStatement); !ok {
+			t.Fatalf("stmt is not *ast.ForStatement. got=%T", program.Statements[0])
+		}
 
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (x in [1, 2, 3]) { print(x); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ForInStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+
+	if _, ok := stmt.Iterable.(*ast.ListLiteral); !ok {
+		t.Errorf("iterable is not *ast.ListLiteral. got=%T", stmt.Iterable)
+	}
+}
+
+func TestLoopControlOutsideOfLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (true) { continue; }", "1:13: continue outside of loop"},
+		{"while (true) { let f = fun() { break; }; }", "1:32: break outside of loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("%s | expected error %q. got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestIfElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else if (x == 1) { 1 } else { 0 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Alternative != nil || exp.ElseIf == nil {
+		t.Fatalf("first if does not continue into an else-if")
+	}
+
+	if !testInfixExpression(t, exp.ElseIf.Condition, "x", ">", "y") {
+		return
+	}
+
+	last := exp.ElseIf.ElseIf
+	if last == nil || last.ElseIf != nil || last.Alternative == nil {
+		t.Fatalf("else-if chain does not end in an else")
+	}
+
+	expected := "if(x < y) xelse if(x > y) yelse if(x == 1) 1else 0"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "test.lueder:1:7: expected next token to be =, got INT_LITERAL instead"},
+		{"let x = 5;\nlet = 10;", "test.lueder:2:5: expected next token to be IDENT, got = instead"},
+		{"let x = 5;\n  @", "test.lueder:2:3: illegal token \"@\" found"},
+		{"add(1,\n\t2", "test.lueder:2:3: expected next token to be ), got EOF instead"},
+		{"let s = \"a\\qb\";", "test.lueder:1:11: unknown escape sequence \\q"},
+		{"let s = `abc;\nprint(s)", "test.lueder:1:9: unterminated raw string"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("test.lueder", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("%q | expected first error %q. got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"print(1 +)\nlet x = 2", []string{"1:10: no prefix parse function for ) found"}},
+		{"let f = fun(a) {\n  let b = a +\n}\nlet = 3\nprint(f(1)", []string{
+			"3:1: no prefix parse function for } found",
+			"4:5: expected next token to be IDENT, got = instead",
+			"5:11: expected next token to be ), got EOF instead",
+		}},
+		{"if (x { 1 } else { 2 }\nlet y = [1, 2,, 3]; y", []string{
+			"1:7: expected next token to be ), got { instead",
+			"2:15: no prefix parse function for , found",
+		}},
+		{"let x = 1 @ 2; x", []string{"1:11: illegal token \"@\" found"}},
+		{"let f = fun() {\n  print(1)", []string{"1:15: this { is never closed"}},
+		{"try { 1 }; break; let x = ;", []string{
+			"1:1: try needs a catch or a finally",
+			"1:12: break outside of loop",
+			"1:27: no prefix parse function for ; found",
+		}},
+		{`let s = "${unclosed";`, []string{"1:20: missing } in ${...}"}},
+		{`let s = "${x`, []string{"1:10: missing } in ${...}"}},
+		{`"${ 1 + }"`, []string{"1:9: missing expression before } in ${...}"}},
+		{"let s = \"a ${x y} b\"\nlet = 3", []string{
+			"1:16: missing } in ${...}",
+			"2:5: expected next token to be IDENT, got = instead",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if strings.Join(errors, "\n") != strings.Join(tt.expectedErrors, "\n") {
+			t.Errorf("%q | wrong errors.\nexpected=%q\ngot=     %q", tt.input, tt.expectedErrors, errors)
+		}
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	input := `let x = (1 +
+let y = 2
+print(x, )
+let z = 3`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	expected := []string{"*ast.BadStatement", "*ast.LetStatement", "*ast.BadStatement", "*ast.LetStatement"}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("wrong number of statements. expected=%d, got=%d (%q)", len(expected), len(program.Statements), program.String())
+	}
+	for i, stmt := range program.Statements {
+		if got := fmt.Sprintf("%T", stmt); got != expected[i] {
+			t.Errorf("statement %d wrong. expected=%s, got=%s", i, expected[i], got)
+		}
+	}
+
+	// what did parse is still there
+	bad := program.Statements[0].(*ast.BadStatement)
+	let, ok := bad.Partial.(*ast.LetStatement)
+	if !ok || let.Name.Value != "x" {
+		t.Fatalf("bad.Partial is not the let statement. got=%#v", bad.Partial)
+	}
+	call := program.Statements[2].(*ast.BadStatement).Partial.(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if _, ok := call.Arguments[1].(*ast.BadExpression); !ok {
+		t.Errorf("missing argument is not an ast.BadExpression. got=%T", call.Arguments[1])
+	}
+}
+
+func TestTypedStatements(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType string
+		expected     string
+	}{
+		{"bool b = true;", "*ast.BoolStatement", "bool b = true;"},
+		{`string s = "hi";`, "*ast.StringStatement", "string s = hi;"},
+		{"list xs = [1, 2];", "*ast.ListStatement", "list xs = [1, 2];"},
+		{"map m = {};", "*ast.MapStatement", "map m = {};"},
+		{"fun f = fun(x) { x };", "*ast.FunStatement", "fun f = fun(x) x;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s | program has wrong number of statements. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		if got := fmt.Sprintf("%T", program.Statements[0]); got != tt.expectedType {
+			t.Errorf("%s | wrong statement type. expected=%s, got=%s", tt.input, tt.expectedType, got)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("%s | program.String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestTypedFunctionParameters(t *testing.T) {
+	input := `fun(int x, y, fun f, list xs) float { x };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	expectedTypes := []string{"int", "", "fun", "list"}
+	expectedNames := []string{"x", "y", "f", "xs"}
+
+	if len(function.Parameters) != 4 || len(function.ParameterTypes) != 4 {
+		t.Fatalf("function literal parameters wrong. want 4, got=%d", len(function.Parameters))
+	}
+
+	for i, param := range function.Parameters {
+		testIdentifier(t, param, expectedNames[i])
+
+		typ := function.ParameterTypes[i]
+		if expectedTypes[i] == "" {
+			if typ != nil {
+				t.Errorf("parameter %d should not have a type. got=%s", i, typ)
+			}
+		} else if typ == nil || typ.Value != expectedTypes[i] {
+			t.Errorf("parameter %d has wrong type. expected=%s, got=%v", i, expectedTypes[i], typ)
+		}
+	}
+
+	if function.ReturnType == nil || function.ReturnType.Value != "float" {
+		t.Errorf("function has wrong return type. got=%v", function.ReturnType)
+	}
+
+	if function.String() != "fun(int x, y, fun f, list xs) float x" {
+		t.Errorf("function.String() wrong. got=%q", function.String())
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { f(); } catch (e) { print(e); }`, "try f() catch(e) print(e)"},
+		{`try { f(); } finally { done(); }`, "try f() finally done()"},
+		{`try { throw "oops"; } catch (e) { 1 } finally { 2 };`, "try throw oops; catch(e) 1 finally 2"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s | program has wrong number of statements. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		if _, ok := program.Statements[0].(*ast.TryStatement); !ok {
+			t.Fatalf("%s | stmt is not *ast.TryStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("%s | program.String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryWithoutCatchOrFinally(t *testing.T) {
+	l := lexer.New("try { f(); }")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:1: try needs a catch or a finally" {
+		t.Errorf("expected a single try error. got=%q", errors)
+	}
+}
+
+func TestImportExportStatements(t *testing.T) {
+	input := `import "lib/math.lueder" as math;
+export let pi = 3.14;
+export fun area = fun(r) { math.square(r) * pi };
+math.counter += 1;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path != "lib/math.lueder" || imp.Name.Value != "math" {
+		t.Errorf("import wrong. got path=%q name=%q", imp.Path, imp.Name.Value)
+	}
+
+	if names := ast.ExportedNames(program); len(names) != 2 || names[0] != "pi" || names[1] != "area" {
+		t.Errorf("wrong exported names. got=%q", names)
+	}
+
+	expected := `import "lib/math.lueder" as math;export let pi = 3.14;export fun area = fun(r) ((math.square)(r) * pi);math.counter += 1;`
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"if (true) { export let x = 1; }", "1:13: export outside of the top level"},
+		{"export x + 1;", "1:1: export needs a declaration like let or int"},
+		{`import "lib" lib;`, "1:14: expected next token to be AS, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("%s | expected error %q. got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	input := `fun(n) {
+	let x = a(n);
+	if (n) { return b(n); }
+	while (n) { return c(n) + 1; }
+	try { return d(n); } catch (e) { e(n) }
+	fun() { f(n) };
+	if (n) { g(n) } else if (x) { h(n) } else { i(n) }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tail := []string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && call.Tail {
+			tail = append(tail, call.Function.String())
+		}
+		return true
+	})
+
+	expected := []string{"b", "f", "g", "h", "i"}
+	if strings.Join(tail, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong tail calls. expected=%q, got=%q", expected, tail)
+	}
+}
@@ -4,45 +4,131 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
 	"luederlang/lexer"
+    "luederlang/lineedit"
 	"luederlang/parser"
     "luederlang/evaluator"
     "luederlang/object"
+    "luederlang/token"
 )
 
 const PROMPT = ">> "
+// shown while the input so far is missing its end
+const CONTINUE_PROMPT = ".. "
+
+// Where the line editor keeps history, in the home directory
+const HISTORY_FILE = ".lueder_history"
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-    env := object.NewEnvironment()
+    s := newSession(out)
+    readLine, remember := lineReader(in, out, func(prefix string) []string {
+        return completions(s.env, prefix)
+    })
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
-		}
-
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
-
-        eval := evaluator.Eval(program, env)
-        if eval != nil {
+		line, err := readLine(PROMPT)
+        for err == nil && incomplete(line) {
+            var more string
+            more, err = readLine(CONTINUE_PROMPT)
+            line += "\n" + more
+        }
+        if err == lineedit.ErrInterrupted {
+            continue
+        }
+        if err != nil {
+            return
+        }
+        remember(line)
+
+        if strings.HasPrefix(strings.TrimSpace(line), ":") {
+            s.command(line)
+            continue
+        }
+
+        if eval := s.run("", line); eval != nil {
             io.WriteString(out, eval.Inspect())
             io.WriteString(out, "\n")
         }
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// A terminal gets the line editor with complete for tab. Anything else is
+// read a plain line at a time. remember puts a whole input into the history
+// once it is complete, so a multi-line one comes back as one entry.
+func lineReader(in io.Reader, out io.Writer, complete func(string) []string) (readLine func(string) (string, error), remember func(string)) {
+    if f, ok := in.(*os.File); ok && lineedit.IsTerminal(f) {
+        editor := lineedit.New(f, out)
+        editor.Complete = complete
+        editor.ManualHistory = true
+        if home, err := os.UserHomeDir(); err == nil {
+            editor.UseHistoryFile(filepath.Join(home, HISTORY_FILE))
+        }
+        return editor.ReadLine, editor.AddHistory
+    }
+
+    scanner := bufio.NewScanner(in)
+    return func(prompt string) (string, error) {
+        fmt.Fprint(out, prompt)
+        if !scanner.Scan() {
+            return "", io.EOF
+        }
+        return scanner.Text(), nil
+    }, func(string) {}
+}
+
+// Keywords, builtins and bound names starting with prefix, each once
+func completions(env *object.Environment, prefix string) []string {
+    seen := map[string]bool{}
+    candidates := []string{}
+    for _, names := range [][]string{token.Keywords(), evaluator.BuiltinNames(), env.Names()} {
+        for _, name := range names {
+            if strings.HasPrefix(name, prefix) && !seen[name] {
+                seen[name] = true
+                candidates = append(candidates, name)
+            }
+        }
+    }
+    sort.Strings(candidates)
+    return candidates
+}
+
+func printParserErrors(out io.Writer, input string, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, token.FormatError(input, err.Pos, err.Message))
 	}
 }
+
+// Tokens that can't end an expression, the rest of it is on the next line
+var binaryOperators = map[token.TokenType]bool{
+    token.PLUS: true, token.MINUS: true, token.ASTERISK: true, token.SLASH: true, token.MOD: true,
+    token.LT: true, token.GT: true, token.EQ: true, token.NOT_EQ: true, token.LAND: true, token.LOR: true,
+    token.ASSIGN: true, token.PLUS_ASSIGN: true, token.MINUS_ASSIGN: true, token.ASTERISK_ASSIGN: true,
+    token.SLASH_ASSIGN: true, token.MOD_ASSIGN: true, token.COMMA: true, token.DOT: true,
+}
+
+// True while input has an open bracket or string, or ends in an operator
+func incomplete(input string) bool {
+    depth := 0
+    var last token.Token
+    l := lexer.New(input)
+    for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+        switch tok.Type {
+        case token.LPAREN, token.LBRACE, token.LBRACKET:
+            depth++
+        case token.RPAREN, token.RBRACE, token.RBRACKET:
+            depth--
+        }
+        last = tok
+    }
+    for _, err := range l.Errors() {
+        if err.Unterminated {
+            return true
+        }
+    }
+    return depth > 0 || binaryOperators[last.Type]
+}
+
@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"bytes"
+	"luederlang/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1", false},
+		{"let f = fun(x) {", true},
+		{"let f = fun(x) {\n  x + 1\n}", false},
+		{"print(1,", true},
+		{"[1, 2", true},
+		{"1 +", true},
+		{"x &&", true},
+		{"let x =", true},
+		{"lib.", true},
+		{"i++", false},
+		{`let s = "abc`, true},
+		{`let s = "a { b"`, false},
+		{`"a" // "`, false},
+		{`"a \" b"`, false},
+		{"let s = \"\"\"\nline", true},
+		{"let s = `a", true},
+		{"let s = \"\\x\"", false},
+		{"// (", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("%q | expected incomplete=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartReadsUntilComplete(t *testing.T) {
+	input := "let add = fun(a, b) {\n  a +\n    b\n};\nadd(1,\n2)\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> .. .. .. null\n>> .. 3\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Declare("length", &object.Integer{Value: 1}, "")
+	env.Declare("lengthy", &object.Integer{Value: 2}, "")
+
+	got := strings.Join(completions(env, "le"), " ")
+	if got != "len length lengthy let" {
+		t.Errorf("wrong completions. got=%q", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.lueder")
+	if err := os.WriteFile(file, []byte("let double = fun(x) { x * 2 };\nint base = 4;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":tokens x += 1", "1:1    IDENT           \"x\"\n1:3    +=              \"+=\"\n1:6    INT_LITERAL     \"1\"\n1:7    EOF             \"\"\n"},
+		{":ast -x", "Program 1:1\n  Statements[0]: ExpressionStatement 1:1\n    Expression: PrefixExpression Operator=\"-\" 1:1\n      Right: Identifier Value=\"x\" 1:2\n"},
+		{":type 1 + 2.5", "float\n"},
+		{":type let y = 1; y\n:type y", "int\n1:1: NameError: identifier not found: y\n\ty\n\t^\n"},
+		{"int a = 1\nlet s = \"hi\"\n:env", "null\nnull\nint a = 1\nlet s = hi\n"},
+		{":load " + file + "\ndouble(base)", "8\n"},
+		{":load " + file + "\n:reset\n:env", ""},
+		{":unknown", "unknown command :unknown, :help lists them\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		// the prompts only get in the way here
+		got := strings.ReplaceAll(out.String(), PROMPT, "")
+		if got != tt.expected {
+			t.Errorf("%q | wrong output.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
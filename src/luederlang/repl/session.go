@@ -0,0 +1,149 @@
+package repl
+
+import (
+    "fmt"
+    "io"
+    "luederlang/ast"
+    "luederlang/evaluator"
+    "luederlang/lexer"
+    "luederlang/object"
+    "luederlang/parser"
+    "luederlang/token"
+    "luederlang/typechecker"
+    "os"
+    "sort"
+    "strings"
+)
+
+// What the input typed so far has built up, :reset starts over
+type session struct {
+    env     *object.Environment
+    checker *typechecker.Checker
+    out     io.Writer
+}
+
+func newSession(out io.Writer) *session {
+    return &session{env: object.NewEnvironment(), checker: typechecker.New(), out: out}
+}
+
+// Checks and evaluates input in the session. Errors are printed and give
+// back nil, so does a program without a value.
+func (s *session) run(filename string, input string) object.Object {
+    program := s.parse(filename, input)
+    if program == nil {
+        return nil
+    }
+
+    if errors := s.checker.Check(program); len(errors) != 0 {
+        for _, err := range errors {
+            io.WriteString(s.out, token.FormatError(input, err.Pos, err.Message))
+        }
+        return nil
+    }
+
+    return s.eval(program, s.env, input)
+}
+
+func (s *session) parse(filename string, input string) *ast.Program {
+    p := parser.New(lexer.NewFile(filename, input))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        printParserErrors(s.out, input, p.ErrorList())
+        return nil
+    }
+    return program
+}
+
+func (s *session) eval(program *ast.Program, env *object.Environment, input string) object.Object {
+    eval := evaluator.Eval(program, env)
+    if err, ok := eval.(*object.Error); ok {
+        io.WriteString(s.out, token.FormatError(input, err.Pos, err.Describe()))
+        for _, line := range err.TraceLines() {
+            io.WriteString(s.out, "\t"+line+"\n")
+        }
+        return nil
+    }
+    return eval
+}
+
+// For :help, in the order they are listed
+var commandHelp = []string{
+    ":tokens <input>   the tokens the lexer makes of input",
+    ":ast <input>      the tree the parser makes of input",
+    ":type <input>     the type of the value input evaluates to",
+    ":env              the names bound in this session",
+    ":load <file>      runs a file in this session",
+    ":reset            forgets everything bound in this session",
+    ":help             this list",
+}
+
+// line is ":name" and whatever follows it
+func (s *session) command(line string) {
+    line = strings.TrimSpace(line)
+    name := line
+    if i := strings.IndexAny(line, " \t\n"); i >= 0 {
+        name = line[:i]
+    }
+    arg := strings.TrimSpace(line[len(name):])
+
+    switch name {
+    case ":tokens":
+        l := lexer.New(arg)
+        for {
+            tok := l.NextToken()
+            fmt.Fprintf(s.out, "%-6s %-15s %q\n", tok.Pos, tok.Type, tok.Literal)
+            if tok.Type == token.EOF {
+                break
+            }
+        }
+    case ":ast":
+        if program := s.parse("", arg); program != nil {
+            io.WriteString(s.out, ast.Dump(program))
+        }
+    case ":type":
+        // the value is thrown away, so are the names it binds
+        program := s.parse("", arg)
+        if program == nil {
+            return
+        }
+        if eval := s.eval(program, object.NewEnclosedEnvironment(s.env), arg); eval != nil {
+            fmt.Fprintln(s.out, object.TypeName(eval))
+        }
+    case ":env":
+        s.printEnv()
+    case ":load":
+        source, err := os.ReadFile(arg)
+        if err != nil {
+            fmt.Fprintln(s.out, err)
+            return
+        }
+        s.run(arg, string(source))
+    case ":reset":
+        *s = *newSession(s.out)
+    case ":help":
+        for _, help := range commandHelp {
+            fmt.Fprintln(s.out, help)
+        }
+    default:
+        fmt.Fprintf(s.out, "unknown command %s, :help lists them\n", name)
+    }
+}
+
+// One declaration per name like it could have been written, values that
+// span lines are cut off after the first
+func (s *session) printEnv() {
+    names := s.env.Names()
+    sort.Strings(names)
+    for _, name := range names {
+        value, _ := s.env.Get(name)
+        keyword := s.env.DeclaredType(name)
+        if keyword == "" {
+            keyword = "let"
+        }
+        inspected := value.Inspect()
+        if first, _, found := strings.Cut(inspected, "\n"); found {
+            inspected = first + " ..."
+        }
+        fmt.Fprintf(s.out, "%s %s = %s\n", keyword, name, inspected)
+    }
+}
@@ -0,0 +1,91 @@
+package testrunner
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "time"
+)
+
+// The JUnit XML most CI systems read, one testsuite per file
+
+type junitSuites struct {
+    XMLName  xml.Name     `xml:"testsuites"`
+    Tests    int          `xml:"tests,attr"`
+    Failures int          `xml:"failures,attr"`
+    Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+    Name     string      `xml:"name,attr"`
+    Tests    int         `xml:"tests,attr"`
+    Failures int         `xml:"failures,attr"`
+    Time     string      `xml:"time,attr"`
+    Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+    Name      string        `xml:"name,attr"`
+    ClassName string        `xml:"classname,attr"`
+    Time      string        `xml:"time,attr"`
+    Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+    Message string `xml:"message,attr"`
+    Text    string `xml:",chardata"`
+}
+
+// A file that could not be loaded shows up as a failed case named after it
+func WriteJUnit(w io.Writer, results []Result) error {
+    suites := junitSuites{}
+    index := map[string]int{}
+    for _, r := range results {
+        i, ok := index[r.File]
+        if !ok {
+            i = len(suites.Suites)
+            index[r.File] = i
+            suites.Suites = append(suites.Suites, junitSuite{Name: r.File})
+        }
+        suite := &suites.Suites[i]
+
+        name := r.Name
+        if name == "" {
+            name = r.File
+        }
+        c := junitCase{Name: name, ClassName: r.File, Time: seconds(r.Duration)}
+        if !r.Passed() {
+            c.Failure = &junitFailure{Message: r.Summary, Text: r.Failure}
+            suite.Failures++
+            suites.Failures++
+        }
+        suite.Cases = append(suite.Cases, c)
+        suite.Tests++
+        suites.Tests++
+    }
+
+    for i := range suites.Suites {
+        var total time.Duration
+        for _, r := range results {
+            if r.File == suites.Suites[i].Name {
+                total += r.Duration
+            }
+        }
+        suites.Suites[i].Time = seconds(total)
+    }
+
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(suites); err != nil {
+        return err
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
+
+func seconds(d time.Duration) string {
+    return fmt.Sprintf("%.3f", d.Seconds())
+}
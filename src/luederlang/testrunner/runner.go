@@ -0,0 +1,162 @@
+package testrunner
+
+import (
+    "io/fs"
+    "luederlang/ast"
+    "luederlang/evaluator"
+    "luederlang/lexer"
+    "luederlang/object"
+    "luederlang/parser"
+    "luederlang/token"
+    "luederlang/typechecker"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Files ending in this hold tests
+const Suffix = "_test.lueder"
+
+// Top level functions whose name starts with this are tests
+const TestPrefix = "test_"
+
+// How one test went. Name is "" when the file itself could not be loaded.
+type Result struct {
+    File     string
+    Name     string
+    Pos      token.Position // where the test function is declared
+    Duration time.Duration
+
+    // "" if the test passed, otherwise the error like it gets printed
+    Failure string
+    // the first line of Failure
+    Summary string
+}
+
+func (r Result) Passed() bool {
+    return r.Failure == ""
+}
+
+// The test files in paths, directories are searched all the way down
+func Discover(paths []string) ([]string, error) {
+    files := []string{}
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil {
+            return nil, err
+        }
+        if !info.IsDir() {
+            files = append(files, path)
+            continue
+        }
+        err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+            if err != nil {
+                return err
+            }
+            if !d.IsDir() && strings.HasSuffix(file, Suffix) {
+                files = append(files, file)
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return files, nil
+}
+
+// Runs every test in file. Each one gets a fresh environment that the whole
+// file runs in first, so tests can't see what the others changed.
+func Run(file string) []Result {
+    source, err := os.ReadFile(file)
+    if err != nil {
+        return []Result{failed(file, err.Error())}
+    }
+    input := string(source)
+
+    p := parser.New(lexer.NewFile(file, input))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        var out strings.Builder
+        for _, err := range p.ErrorList() {
+            out.WriteString(token.FormatError(input, err.Pos, err.Message))
+        }
+        return []Result{failed(file, out.String())}
+    }
+    if errors := typechecker.New().Check(program); len(errors) != 0 {
+        var out strings.Builder
+        for _, err := range errors {
+            out.WriteString(token.FormatError(input, err.Pos, err.Message))
+        }
+        return []Result{failed(file, out.String())}
+    }
+
+    results := []Result{}
+    for _, test := range testNames(program) {
+        result := Result{File: file, Name: test.Value, Pos: test.Pos()}
+        start := time.Now()
+
+        env := object.NewEnvironment()
+        evaluated := evaluator.Eval(program, env)
+        if _, ok := evaluated.(*object.Error); !ok {
+            fn, _ := env.Get(test.Value)
+            evaluated = evaluator.Call(fn)
+        }
+
+        result.Duration = time.Since(start)
+        if err, ok := evaluated.(*object.Error); ok {
+            result.Failure = formatFailure(file, input, err)
+            result.Summary = err.Describe()
+        }
+        results = append(results, result)
+    }
+    return results
+}
+
+// Names of the top level functions that are tests, in order
+func testNames(program *ast.Program) []*ast.Identifier {
+    names := []*ast.Identifier{}
+    for _, s := range program.Statements {
+        if export, ok := s.(*ast.ExportStatement); ok {
+            s = export.Statement
+        }
+        name, value, _, ok := ast.DeclarationOf(s)
+        if !ok || !strings.HasPrefix(name.Value, TestPrefix) {
+            continue
+        }
+        if _, ok := value.(*ast.FunctionLiteral); ok {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+func failed(file string, failure string) Result {
+    summary, _, _ := strings.Cut(failure, "\n")
+    return Result{File: file, Failure: failure, Summary: summary}
+}
+
+// The error with its excerpt and the calls it came through. The last call is
+// the runner's own, which has no position. Errors inside of an imported module
+// show that module's source.
+func formatFailure(file string, input string, err *object.Error) string {
+    if err.Pos.Filename != file {
+        source, readErr := os.ReadFile(err.Pos.Filename)
+        input = string(source)
+        if readErr != nil {
+            input = ""
+        }
+    }
+
+    var out strings.Builder
+    out.WriteString(token.FormatError(input, err.Pos, err.Describe()))
+    trace := &object.Error{Trace: err.Trace}
+    if n := len(trace.Trace); n > 0 && !trace.Trace[n-1].Pos.IsValid() {
+        trace.Trace = trace.Trace[:n-1]
+    }
+    for _, line := range trace.TraceLines() {
+        out.WriteString("\t" + line + "\n")
+    }
+    return out.String()
+}
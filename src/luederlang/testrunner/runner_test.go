@@ -0,0 +1,110 @@
+package testrunner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.lueder":     "",
+		"a.lueder":          "",
+		"sub/b_test.lueder": "",
+		"sub/notes.txt":     "",
+	})
+
+	files, err := Discover([]string{dir})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "a_test.lueder"), filepath.Join(dir, "sub/b_test.lueder")}
+	if strings.Join(files, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong files. expected=%q, got=%q", expected, files)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.lueder": `let seen = [];
+let test_passes = fun() { assert_eq(1 + 1, 2); seen = [1]; };
+let test_fresh = fun() { assert_eq(len(seen), 0, "state leaked") };
+let check = fun(x) { assert(x > 1) };
+let test_fails = fun() { check(1); 1 };
+let helper = fun() { assert(false) };
+export let test_exported = fun() { 1 };
+int test_not_a_function = 1;`,
+		"broken_test.lueder": "let test_x = fun() { 1 + };",
+	})
+
+	results := Run(filepath.Join(dir, "math_test.lueder"))
+	expected := []struct {
+		name    string
+		failure string
+	}{
+		{"test_passes", ""},
+		{"test_fresh", ""},
+		{"test_fails", "math_test.lueder:4:28: AssertionError: assertion failed\n\tlet check = fun(x) { assert(x > 1) };\n\t                           ^\n\tin check called at "},
+		{"test_exported", ""},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("wrong number of results. expected=%d, got=%d", len(expected), len(results))
+	}
+	for i, r := range results {
+		if r.Name != expected[i].name {
+			t.Errorf("results[%d] has wrong name. expected=%q, got=%q", i, expected[i].name, r.Name)
+		}
+		if !strings.Contains(r.Failure, expected[i].failure) || (expected[i].failure == "") != r.Passed() {
+			t.Errorf("results[%d] has wrong failure. expected=%q, got=%q", i, expected[i].failure, r.Failure)
+		}
+	}
+	if results[2].Summary != "AssertionError: assertion failed" {
+		t.Errorf("wrong summary. got=%q", results[2].Summary)
+	}
+
+	broken := Run(filepath.Join(dir, "broken_test.lueder"))
+	if len(broken) != 1 || broken[0].Name != "" || broken[0].Passed() {
+		t.Errorf("broken file should fail to load. got=%+v", broken)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []Result{
+		{File: "a_test.lueder", Name: "test_ok"},
+		{File: "a_test.lueder", Name: "test_bad", Failure: "a_test.lueder:2:1: AssertionError: <nope>\n", Summary: "AssertionError: <nope>"},
+		{File: "b_test.lueder", Failure: "b_test.lueder:1:1: oops\n", Summary: "b_test.lueder:1:1: oops"},
+	}
+
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, results); err != nil {
+		t.Fatalf("WriteJUnit: %v", err)
+	}
+
+	for _, want := range []string{
+		`<testsuites tests="3" failures="2">`,
+		`<testsuite name="a_test.lueder" tests="2" failures="1" time="0.000">`,
+		`<testcase name="test_ok" classname="a_test.lueder" time="0.000"></testcase>`,
+		`<failure message="AssertionError: &lt;nope&gt;">`,
+		`<testcase name="b_test.lueder" classname="b_test.lueder" time="0.000">`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("JUnit output is missing %q. got=\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package token
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Line and Column start at 1, Column counts bytes. The zero value is an
+// unknown position.
+type Position struct {
+    Filename string
+    Line     int
+    Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// file.lueder:12:7, or just 12:7 for input that did not come from a file
+func (p Position) String() string {
+    if !p.IsValid() {
+        if p.Filename != "" {
+            return p.Filename
+        }
+        return "-"
+    }
+    if p.Filename == "" {
+        return fmt.Sprintf("%d:%d", p.Line, p.Column)
+    }
+    return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Returns the line of input that p points into with a caret under p's column,
+// or "" if p is not inside of input
+func (p Position) Excerpt(input string) string {
+    if !p.IsValid() {
+        return ""
+    }
+
+    lines := strings.Split(input, "\n")
+    if p.Line > len(lines) {
+        return ""
+    }
+    line := strings.TrimRight(lines[p.Line-1], "\r")
+
+    // Column counts bytes, the caret needs one space per char in front of it
+    var caret strings.Builder
+    for i, r := range line {
+        if i >= p.Column-1 {
+            break
+        }
+        // keep tabs so the caret lines up no matter the tab width
+        if r == '\t' {
+            caret.WriteByte('\t')
+        } else {
+            caret.WriteByte(' ')
+        }
+    }
+    caret.WriteByte('^')
+
+    return line + "\n" + caret.String()
+}
+
+// The message users see for an error at pos:
+//
+//	file.lueder:12:7: message
+//		the offending line
+//		      ^
+func FormatError(input string, pos Position, message string) string {
+    var out strings.Builder
+
+    out.WriteString(pos.String() + ": " + message + "\n")
+
+    if excerpt := pos.Excerpt(input); excerpt != "" {
+        for _, line := range strings.Split(excerpt, "\n") {
+            out.WriteString("\t" + line + "\n")
+        }
+    }
+
+    return out.String()
+}
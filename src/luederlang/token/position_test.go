@@ -0,0 +1,42 @@
+package token
+
+import "testing"
+
+func TestFormatError(t *testing.T) {
+	input := "let x = 5;\n\tx + true;\n"
+	pos := Position{Filename: "ryan.lueder", Line: 2, Column: 4}
+
+	expected := "ryan.lueder:2:4: type mismatch\n\t\tx + true;\n\t\t  ^\n"
+	if got := FormatError(input, pos, "type mismatch"); got != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestExcerptAfterWideChars(t *testing.T) {
+	// é is two bytes and 😀 four, the caret still needs one space for each
+	input := "\"é😀\" + true"
+	pos := Position{Line: 1, Column: 10}
+
+	expected := "\"é😀\" + true\n     ^"
+	if got := pos.Excerpt(input); got != expected {
+		t.Errorf("wrong excerpt. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{Filename: "a.lueder", Line: 12, Column: 7}, "a.lueder:12:7"},
+		{Position{Line: 1, Column: 3}, "1:3"},
+		{Position{Filename: "a.lueder"}, "a.lueder"},
+		{Position{}, "-"},
+	}
+
+	for _, tt := range tests {
+		if tt.pos.String() != tt.expected {
+			t.Errorf("wrong position. expected=%q, got=%q", tt.expected, tt.pos.String())
+		}
+	}
+}
@@ -9,12 +9,17 @@ type TokenType string
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+    COMMENT = "COMMENT" // only from lexers that keep comments
 
 	// Identifiers + literals
 	IDENT = "IDENT"                 // add, foobar, x, y, ...
     INT_LITERAL   = "INT_LITERAL"   // 1343456
     FLOAT_LITERAL = "FLOAT_LITERAL"
     STRING_LITERAL = "STRING_LITERAL"
+    // "a ${x} b ${y} c" is STRING_START "a ", x, STRING_MIDDLE " b ", y, STRING_END " c"
+    STRING_START  = "STRING_START"
+    STRING_MIDDLE = "STRING_MIDDLE"
+    STRING_END    = "STRING_END"
 
 	// Operators
 	ASSIGN   = "="
@@ -25,6 +30,14 @@ const (
 	SLASH    = "/"
     MOD      = "%"
 
+    PLUS_ASSIGN     = "+="
+    MINUS_ASSIGN    = "-="
+    ASTERISK_ASSIGN = "*="
+    SLASH_ASSIGN    = "/="
+    MOD_ASSIGN      = "%="
+    INCREMENT       = "++"
+    DECREMENT       = "--"
+
 	LT = "<"
 	GT = ">"
 
@@ -36,11 +49,15 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -48,28 +65,84 @@ const (
     INT      = "INT"
     FLOAT    = "FLOAT" 
     BOOL     = "BOOL"
+    STRING   = "STRING"
+    LIST     = "LIST"
+    MAP      = "MAP"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
 }
 
 var keywords = map[string]TokenType{
-	"fun":    FUNCTION,
-	"let":    LET,
-    "int":    INT,
-    "float":  FLOAT,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fun":      FUNCTION,
+	"let":      LET,
+    "int":      INT,
+    "float":    FLOAT,
+    "bool":     BOOL,
+    "string":   STRING,
+    "list":     LIST,
+    "map":      MAP,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+}
+
+// The words LookupIdent turns into keywords, for completion in the repl
+func Keywords() []string {
+    words := make([]string, 0, len(keywords))
+    for word := range keywords {
+        words = append(words, word)
+    }
+    return words
+}
+
+// Tokens that name a type in declarations and function signatures
+var typeNames = map[TokenType]bool{
+    INT:      true,
+    FLOAT:    true,
+    BOOL:     true,
+    STRING:   true,
+    LIST:     true,
+    MAP:      true,
+    FUNCTION: true,
+}
+
+func IsTypeName(t TokenType) bool {
+    return typeNames[t]
 }
 
 func LookupIdent(ident string) TokenType {
@@ -79,16 +152,17 @@ func LookupIdent(ident string) TokenType {
 	return IDENT
 }
 
+// A number with a . or an exponent is a float, hex, binary and octal numbers
+// are always ints. Whether the digits make sense is up to the parser.
 func LookupNumber(number string) TokenType {
-    count := strings.Count(number, ".")
-    var tok TokenType
-    switch count {
-    case 0:
-        tok = INT_LITERAL
-    case 1:
-        tok = FLOAT_LITERAL
-    default:
-        tok = ILLEGAL
+    lower := strings.ToLower(number)
+    for _, prefix := range []string{"0x", "0b", "0o"} {
+        if strings.HasPrefix(lower, prefix) {
+            return INT_LITERAL
+        }
+    }
+    if strings.ContainsAny(lower, ".e") {
+        return FLOAT_LITERAL
     }
-    return tok
+    return INT_LITERAL
 }
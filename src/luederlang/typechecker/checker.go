@@ -0,0 +1,533 @@
+package typechecker
+
+import (
+    "fmt"
+    "luederlang/ast"
+    "luederlang/token"
+)
+
+type TypeError struct {
+    Pos     token.Position
+    Message string
+}
+
+func (e *TypeError) Error() string {
+    return e.Pos.String() + ": " + e.Message
+}
+
+// What the checker knows about the builtins, nil Params means any arguments
+var builtinTypes = map[string]Type{
+    "len":    &Function{Return: INT},
+    "help":   &Function{Return: STRING},
+    "print":  &Function{Return: NULL},
+    "keys":   &Function{Return: LIST},
+    "values": &Function{Return: LIST},
+    "bytes":  &Function{Return: LIST},
+    "runes":  &Function{Return: LIST},
+    "has":    &Function{Return: BOOL},
+    "delete": &Function{Return: ANY},
+    "error":  &Function{Return: ANY},
+    "assert":    &Function{Return: NULL},
+    "assert_eq": &Function{Return: NULL},
+    "assert_ne": &Function{Return: NULL},
+}
+
+type scope struct {
+    types map[string]Type
+    outer *scope
+}
+
+func newScope(outer *scope) *scope {
+    return &scope{types: make(map[string]Type), outer: outer}
+}
+
+func (s *scope) get(name string) (Type, bool) {
+    t, ok := s.types[name]
+    if !ok && s.outer != nil {
+        t, ok = s.outer.get(name)
+    }
+    return t, ok
+}
+
+func (s *scope) set(name string, t Type) {
+    s.types[name] = t
+}
+
+// Scopes follow the evaluator: only function bodies get a new one, blocks
+// share the scope they are in
+type Checker struct {
+    scope  *scope
+    errors []*TypeError
+
+    // declared return types of the enclosing function literals, innermost
+    // last, ANY for functions that do not declare one
+    returnTypes []Type
+
+    // the type of every name that was declared, for tools like the language
+    // server. Keyed by the identifier in the declaration.
+    Types map[*ast.Identifier]Type
+}
+
+// The checker keeps the names it has seen between calls to Check, so the
+// REPL can check one line at a time
+func New() *Checker {
+    return &Checker{scope: newScope(nil), Types: map[*ast.Identifier]Type{}}
+}
+
+// Returns the type errors in program, an empty list means it is safe to run
+func (c *Checker) Check(program *ast.Program) []*TypeError {
+    c.errors = []*TypeError{}
+
+    for _, stmt := range program.Statements {
+        c.checkStatement(stmt)
+    }
+
+    return c.errors
+}
+
+func (c *Checker) declare(name *ast.Identifier, t Type) {
+    c.scope.set(name.Value, t)
+    c.Types[name] = t
+}
+
+func (c *Checker) addError(pos token.Position, format string, a ...interface{}) {
+    c.errors = append(c.errors, &TypeError{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *Checker) checkStatement(stmt ast.Statement) {
+    if name, value, typeName, ok := ast.DeclarationOf(stmt); ok {
+        c.checkDeclaration(stmt, name, value, typeName)
+        return
+    }
+
+    switch stmt := stmt.(type) {
+    case *ast.ExpressionStatement:
+        c.typeOf(stmt.Expression)
+
+    case *ast.ReturnStatement:
+        t := c.typeOf(stmt.ReturnValue)
+        if len(c.returnTypes) > 0 {
+            want := c.returnTypes[len(c.returnTypes)-1]
+            if !assignable(want, t) {
+                c.addError(ast.Start(stmt.ReturnValue), "cannot return %s from fun returning %s", t, want)
+            }
+        }
+
+    case *ast.BlockStatement:
+        for _, s := range stmt.Statements {
+            c.checkStatement(s)
+        }
+
+    case *ast.AssignStatement:
+        c.checkAssignment(stmt)
+
+    case *ast.IndexAssignStatement:
+        current := c.typeOf(stmt.Target)
+        if stmt.Operator != "=" {
+            c.typeOfOperator(stmt.Pos(), ast.CompoundOperator(stmt.Operator), current, c.typeOfAssignedValue(stmt.Value))
+        } else {
+            c.typeOf(stmt.Value)
+        }
+
+    case *ast.WhileStatement:
+        c.typeOf(stmt.Condition)
+        c.checkStatement(stmt.Body)
+
+    case *ast.ForStatement:
+        if stmt.Init != nil {
+            c.checkStatement(stmt.Init)
+        }
+        if stmt.Condition != nil {
+            c.typeOf(stmt.Condition)
+        }
+        if stmt.Post != nil {
+            c.checkStatement(stmt.Post)
+        }
+        c.checkStatement(stmt.Body)
+
+    case *ast.ForInStatement:
+        var element Type
+        switch iterable := c.typeOf(stmt.Iterable); iterable {
+        case STRING:
+            element = STRING
+        case LIST, MAP, ANY:
+            element = ANY
+        default:
+            c.addError(ast.Start(stmt.Iterable), "cannot iterate over %s", iterable)
+            element = ANY
+        }
+        c.declare(stmt.Variable, element)
+        c.checkStatement(stmt.Body)
+
+    case *ast.TryStatement:
+        c.checkStatement(stmt.Body)
+        if stmt.Catch != nil {
+            c.declare(stmt.CatchVariable, ANY)
+            c.checkStatement(stmt.Catch)
+        }
+        if stmt.Finally != nil {
+            c.checkStatement(stmt.Finally)
+        }
+
+    case *ast.ImportStatement:
+        // modules are checked on their own when they are imported
+        c.declare(stmt.Name, ANY)
+
+    case *ast.ExportStatement:
+        c.checkStatement(stmt.Statement)
+
+    case *ast.ThrowStatement:
+        // strings and caught errors, which the checker only knows as any
+        if t := c.typeOf(stmt.Value); t != STRING && t != ANY {
+            c.addError(ast.Start(stmt.Value), "cannot throw %s", t)
+        }
+    }
+}
+
+func (c *Checker) checkDeclaration(stmt ast.Statement, name *ast.Identifier, value ast.Expression, typeName string) {
+    // let the function see itself so that recursive calls are checked too
+    if lit, ok := value.(*ast.FunctionLiteral); ok {
+        c.scope.set(name.Value, signatureOf(lit))
+    }
+
+    t := c.typeOf(value)
+
+    switch {
+    case typeName != "":
+        declared := fromName(typeName)
+        if !assignable(declared, t) {
+            c.addError(ast.Start(value), "cannot assign %s to %s %s", t, typeName, name.Value)
+        }
+        if _, ok := t.(*Function); ok && declared == anyFunction {
+            declared = t
+        }
+        c.declare(name, declared)
+
+    default:
+        c.declare(name, inferred(t))
+    }
+}
+
+// An assignment keeps the type the variable was declared with. Names the
+// checker has not seen might still be declared by the time it runs.
+func (c *Checker) checkAssignment(stmt *ast.AssignStatement) {
+    existing, declared := c.scope.get(stmt.Name.Value)
+    if !declared {
+        existing = ANY
+    }
+
+    t := c.typeOfAssignedValue(stmt.Value)
+    if stmt.Operator != "=" {
+        t = c.typeOfOperator(stmt.Pos(), ast.CompoundOperator(stmt.Operator), existing, t)
+    }
+
+    if !assignable(existing, t) {
+        pos := stmt.Pos()
+        if stmt.Operator == "=" {
+            pos = ast.Start(stmt.Value)
+        }
+        c.addError(pos, "cannot assign %s to %s %s", t, existing, stmt.Name.Value)
+    }
+}
+
+// ++ and -- have no value, they add or subtract an int
+func (c *Checker) typeOfAssignedValue(value ast.Expression) Type {
+    if value == nil {
+        return INT
+    }
+    return c.typeOf(value)
+}
+
+// A let of a null value says nothing about what the name will hold later
+func inferred(t Type) Type {
+    if t == NULL {
+        return ANY
+    }
+    return t
+}
+
+func signatureOf(lit *ast.FunctionLiteral) *Function {
+    sig := &Function{Params: []Type{}, Return: ANY}
+    for i := range lit.Parameters {
+        if i < len(lit.ParameterTypes) && lit.ParameterTypes[i] != nil {
+            sig.Params = append(sig.Params, fromName(lit.ParameterTypes[i].Value))
+        } else {
+            sig.Params = append(sig.Params, ANY)
+        }
+    }
+    if lit.ReturnType != nil {
+        sig.Return = fromName(lit.ReturnType.Value)
+    }
+    return sig
+}
+
+func (c *Checker) typeOf(exp ast.Expression) Type {
+    switch exp := exp.(type) {
+    case *ast.IntegerLiteral, *ast.BigIntegerLiteral:
+        return INT
+    case *ast.FloatLiteral:
+        return FLOAT
+    case *ast.StringLiteral:
+        return STRING
+    case *ast.InterpolatedString:
+        // anything goes inside of ${ }, it ends up as its Inspect()
+        for _, part := range exp.Parts {
+            c.typeOf(part)
+        }
+        return STRING
+    case *ast.Boolean:
+        return BOOL
+
+    case *ast.ListLiteral:
+        for _, el := range exp.Elements {
+            c.typeOf(el)
+        }
+        return LIST
+
+    case *ast.MapLiteral:
+        for i, key := range exp.Keys {
+            c.checkMapKey(key, c.typeOf(key))
+            c.typeOf(exp.Values[i])
+        }
+        return MAP
+
+    case *ast.Identifier:
+        if t, ok := c.scope.get(exp.Value); ok {
+            return t
+        }
+        if t, ok := builtinTypes[exp.Value]; ok {
+            return t
+        }
+        return ANY
+
+    case *ast.PrefixExpression:
+        return c.typeOfPrefix(exp, c.typeOf(exp.Right))
+
+    case *ast.InfixExpression:
+        return c.typeOfInfix(exp, c.typeOf(exp.Left), c.typeOf(exp.Right))
+
+    case *ast.IfExpression:
+        return c.typeOfIf(exp)
+
+    case *ast.FunctionLiteral:
+        return c.typeOfFunction(exp)
+
+    case *ast.CallExpression:
+        return c.typeOfCall(exp)
+
+    case *ast.IndexExpression:
+        return c.typeOfIndex(exp)
+
+    case *ast.SliceExpression:
+        left := c.typeOf(exp.Left)
+        for _, bound := range []ast.Expression{exp.Start, exp.End} {
+            if bound == nil {
+                continue
+            }
+            if t := c.typeOf(bound); t != INT && t != ANY {
+                c.addError(ast.Start(bound), "slice bounds must be int. got=%s", t)
+            }
+        }
+        switch left {
+        case LIST, STRING, ANY:
+            return left
+        }
+        c.addError(ast.Start(exp), "slice operator not supported: %s", left)
+        return ANY
+    }
+
+    return ANY
+}
+
+func (c *Checker) typeOfPrefix(exp *ast.PrefixExpression, right Type) Type {
+    switch exp.Operator {
+    case "!":
+        if right == BOOL || right == NULL || right == ANY {
+            return BOOL
+        }
+    case "-":
+        if isNumeric(right) || right == ANY {
+            return right
+        }
+    }
+    c.addError(ast.Start(exp), "type mismatch: %s%s", exp.Operator, right)
+    return ANY
+}
+
+func (c *Checker) typeOfInfix(exp *ast.InfixExpression, left Type, right Type) Type {
+    return c.typeOfOperator(ast.Start(exp), exp.Operator, left, right)
+}
+
+// Infix expressions and compound assignments like += share the rules
+func (c *Checker) typeOfOperator(pos token.Position, operator string, left Type, right Type) Type {
+    numeric := isNumeric(left) && isNumeric(right)
+    unknown := left == ANY || right == ANY
+
+    switch operator {
+    case "+":
+        switch {
+        case unknown:
+            return ANY
+        case numeric:
+            return widen(left, right)
+        case left == STRING && right == STRING:
+            return STRING
+        case left == LIST && right == LIST:
+            return LIST
+        }
+    case "-", "*", "/", "%":
+        if unknown && (isNumeric(left) || isNumeric(right) || left == right) {
+            return ANY
+        }
+        if numeric {
+            return widen(left, right)
+        }
+    case "<", ">":
+        if numeric || (unknown && (isNumeric(left) || isNumeric(right) || left == right)) {
+            return BOOL
+        }
+    case "==", "!=":
+        if numeric || unknown || (left == BOOL && right == BOOL) || (left == STRING && right == STRING) {
+            return BOOL
+        }
+    case "&&", "||":
+        if (left == BOOL || left == ANY) && (right == BOOL || right == ANY) {
+            return BOOL
+        }
+    }
+
+    c.addError(pos, "type mismatch: %s %s %s", left, operator, right)
+    return ANY
+}
+
+// int op float is a float, int op int stays an int
+func widen(left Type, right Type) Type {
+    if left == FLOAT || right == FLOAT {
+        return FLOAT
+    }
+    return INT
+}
+
+// The branches only give the if a type when they all agree and there is an
+// else, otherwise the result might be null
+func (c *Checker) typeOfIf(exp *ast.IfExpression) Type {
+    c.typeOf(exp.Condition)
+
+    result := c.typeOfBlock(exp.Consequence)
+    var rest Type
+    switch {
+    case exp.ElseIf != nil:
+        rest = c.typeOfIf(exp.ElseIf)
+    case exp.Alternative != nil:
+        rest = c.typeOfBlock(exp.Alternative)
+    default:
+        return ANY
+    }
+
+    if result != rest {
+        return ANY
+    }
+    return result
+}
+
+// The type of the last expression in the block, the value the block evaluates to
+func (c *Checker) typeOfBlock(block *ast.BlockStatement) Type {
+    result := Type(ANY)
+    for i, stmt := range block.Statements {
+        if es, ok := stmt.(*ast.ExpressionStatement); ok && i == len(block.Statements)-1 {
+            result = c.typeOf(es.Expression)
+            continue
+        }
+        c.checkStatement(stmt)
+    }
+    return result
+}
+
+func (c *Checker) typeOfFunction(lit *ast.FunctionLiteral) Type {
+    sig := signatureOf(lit)
+
+    outer := c.scope
+    c.scope = newScope(outer)
+    c.returnTypes = append(c.returnTypes, sig.Return)
+
+    for i, param := range lit.Parameters {
+        c.declare(param, sig.Params[i])
+    }
+
+    last := c.typeOfBlock(lit.Body)
+
+    // the last expression is returned even without a return statement
+    n := len(lit.Body.Statements)
+    if n > 0 && !assignable(sig.Return, last) {
+        if es, ok := lit.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+            c.addError(es.Pos(), "cannot return %s from fun returning %s", last, sig.Return)
+        }
+    }
+
+    c.returnTypes = c.returnTypes[:len(c.returnTypes)-1]
+    c.scope = outer
+
+    return sig
+}
+
+func (c *Checker) typeOfCall(exp *ast.CallExpression) Type {
+    callee := c.typeOf(exp.Function)
+
+    args := []Type{}
+    for _, arg := range exp.Arguments {
+        args = append(args, c.typeOf(arg))
+    }
+
+    switch callee := callee.(type) {
+    case *Function:
+        if callee.Params == nil {
+            return callee.Return
+        }
+        if len(args) != len(callee.Params) {
+            c.addError(ast.Start(exp), "wrong number of arguments. want=%d. got=%d", len(callee.Params), len(args))
+            return callee.Return
+        }
+        for i, arg := range args {
+            if !assignable(callee.Params[i], arg) {
+                c.addError(ast.Start(exp.Arguments[i]), "cannot pass %s as %s (argument %d)", arg, callee.Params[i], i+1)
+            }
+        }
+        return callee.Return
+    case Basic:
+        if callee != ANY {
+            c.addError(ast.Start(exp), "not a function: %s", callee)
+        }
+    }
+    return ANY
+}
+
+func (c *Checker) typeOfIndex(exp *ast.IndexExpression) Type {
+    left := c.typeOf(exp.Left)
+    index := c.typeOf(exp.Index)
+
+    switch left {
+    case ANY:
+        return ANY
+    case MAP:
+        c.checkMapKey(exp.Index, index)
+        return ANY
+    case LIST, STRING:
+        if index == INT || index == ANY {
+            if left == STRING {
+                return STRING
+            }
+            return ANY
+        }
+    }
+
+    c.addError(ast.Start(exp), "index operator not supported: %s[%s]", left, index)
+    return ANY
+}
+
+func (c *Checker) checkMapKey(key ast.Expression, t Type) {
+    switch t {
+    case INT, FLOAT, BOOL, STRING, ANY:
+        return
+    }
+    c.addError(ast.Start(key), "unusable as map key: %s", t)
+}
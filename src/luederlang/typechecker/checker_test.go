@@ -0,0 +1,128 @@
+package typechecker
+
+import (
+	"luederlang/lexer"
+	"luederlang/parser"
+	"testing"
+)
+
+func testCheck(t *testing.T, input string) []*TypeError {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%s | parser errors: %q", input, p.Errors())
+	}
+
+	return New().Check(program)
+}
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		"int x = 5; float y = 7.8; float z = x; let w = x + y;",
+		"bool b = 1 < 2 && true; string s = \"a\" + \"b\"; list xs = [1, 2] + []; map m = {1: 2};",
+		"let add = fun(int a, int b) int { a + b }; int c = add(1, 2);",
+		"let fact = fun(int n) int { if (n < 2) { return 1; } return n * fact(n - 1); };",
+		"fun apply = fun(fun f, x) { f(x) }; apply(len, [1]);",
+		"let xs = [1, 2]; int n = xs[0]; string c = \"abc\"[1]; list ys = xs[1:];",
+		"let m = {}; m[\"a\"] = 1; let v = m[\"a\"] + 1;",
+		"int n = len(\"abc\"); bool b = has({}, 1);",
+		"let x = 5; x = 6; let y = if (true) { 1 } else { 2 }; int z = y;",
+		"for (c in \"abc\") { string s = c; } for (x in [1, 2]) { int y = x; }",
+		"let f = fun(x) { x + 1 }; let g = fun() float { f(1) };",
+		"let x = print(1); x = 5;",
+		"int i = 0; i++; i += 2; float f = 1.5; f *= 2; f -= i; let xs = [1]; xs[0] += 1;",
+		"try { throw \"oops\"; } catch (e) { print(e[\"message\"]); } finally { let done = true; }",
+		"try { 1 / 0; } catch (e) { throw e; }; throw error(\"ValueError\", \"bad\");",
+		"bool same = \"a\" == \"b\"; bool different = \"a\" != \"b\";",
+		"float r = 7.5 % 2; float q = 5 % 2.5; int n = 7 % 3; let md = fun(a, b) { a % b }; md(1, 2.5) % 2.5;",
+	}
+
+	for _, input := range tests {
+		if errors := testCheck(t, input); len(errors) != 0 {
+			t.Errorf("%s | unexpected type errors: %v", input, errors)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int x = 7.8;", "1:9: cannot assign float to int x"},
+		{"float y = true;", "1:11: cannot assign bool to float y"},
+		{"int x = 1;\nx = \"s\";", "2:5: cannot assign string to int x"},
+		{"let x = 1; x = [1];", "1:16: cannot assign list to int x"},
+		{"string s = 1 + 2;", "1:12: cannot assign int to string s"},
+		{"let f = fun(int a) { a }; f(\"a\");", "1:29: cannot pass string as int (argument 1)"},
+		{"let f = fun(int a) { a }; f(1, 2);", "1:27: wrong number of arguments. want=1. got=2"},
+		{"let f = fun() int { return true; };", "1:28: cannot return bool from fun returning int"},
+		{"let f = fun() string { 5 };", "1:24: cannot return int from fun returning string"},
+		{"1 + true;", "1:1: type mismatch: int + bool"},
+		{"-\"a\";", "1:1: type mismatch: -string"},
+		{"!5;", "1:1: type mismatch: !int"},
+		{"throw 42;", "1:7: cannot throw int"},
+		{"let x = 5; x();", "1:12: not a function: int"},
+		{"5[0];", "1:1: index operator not supported: int[int]"},
+		{"[1][\"a\"];", "1:1: index operator not supported: list[string]"},
+		{"{[1]: 2};", "1:2: unusable as map key: list"},
+		{"for (x in 5) { }", "1:11: cannot iterate over int"},
+		{"fun f = 5;", "1:9: cannot assign int to fun f"},
+		{"let fact = fun(int n) int { fact(\"n\") };", "1:34: cannot pass string as int (argument 1)"},
+		{"int n = len(\"s\") + 0.5;", "1:9: cannot assign float to int n"},
+		{"int n = 1; n += 0.5;", "1:12: cannot assign float to int n"},
+		{"string s = \"a\"; s++;", "1:17: type mismatch: string + int"},
+		{"bool b = true; b -= 1;", "1:16: type mismatch: bool - int"},
+		{"int n = 7 % 2.5;", "1:9: cannot assign float to int n"},
+		{"\"a\" % 2;", "1:1: type mismatch: string % int"},
+		{"\"a\" == 1;", "1:1: type mismatch: string == int"},
+	}
+
+	for _, tt := range tests {
+		errors := testCheck(t, tt.input)
+		if len(errors) != 1 {
+			t.Errorf("%q | expected 1 type error. got=%v", tt.input, errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q | wrong type error. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestCheckerRemembersNames(t *testing.T) {
+	checker := New()
+
+	for i, line := range []string{"int x = 1;", "x = 2.5;"} {
+		p := parser.New(lexer.New(line))
+		errors := checker.Check(p.ParseProgram())
+
+		if i == 0 && len(errors) != 0 {
+			t.Fatalf("unexpected type errors: %v", errors)
+		}
+		if i == 1 && (len(errors) != 1 || errors[0].Message != "cannot assign float to int x") {
+			t.Fatalf("expected the second line to be a type error. got=%v", errors)
+		}
+	}
+}
+
+func TestCheckerRecordsTypes(t *testing.T) {
+	program := parser.New(lexer.New("let f = fun(int a, b) float { a }; let x = f(1, 2); for (c in \"ab\") {}")).ParseProgram()
+	checker := New()
+	if errors := checker.Check(program); len(errors) != 0 {
+		t.Fatalf("unexpected type errors: %v", errors)
+	}
+
+	got := map[string]string{}
+	for name, typ := range checker.Types {
+		got[name.Value] = typ.String()
+	}
+	expected := map[string]string{"f": "fun(int, any) float", "a": "int", "b": "any", "x": "float", "c": "string"}
+	for name, typ := range expected {
+		if got[name] != typ {
+			t.Errorf("wrong type for %s. expected=%q, got=%q", name, typ, got[name])
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package typechecker
+
+import (
+    "strings"
+)
+
+type Type interface {
+    String() string
+}
+
+// The types that are just a name
+type Basic string
+
+func (b Basic) String() string { return string(b) }
+
+const (
+    INT    = Basic("int")
+    FLOAT  = Basic("float")
+    BOOL   = Basic("bool")
+    STRING = Basic("string")
+    LIST   = Basic("list")
+    MAP    = Basic("map")
+    NULL   = Basic("null")
+
+    // Anything the checker cannot know before running the program, e.g. an
+    // untyped parameter or an element of a list. ANY fits everywhere.
+    ANY = Basic("any")
+)
+
+// Params is nil for a plain "fun" annotation, which says nothing about the
+// signature and accepts every function
+type Function struct {
+    Params []Type
+    Return Type
+}
+
+func (f *Function) String() string {
+    if f.Params == nil {
+        return "fun"
+    }
+
+    params := []string{}
+    for _, p := range f.Params {
+        params = append(params, p.String())
+    }
+    return "fun(" + strings.Join(params, ", ") + ") " + f.Return.String()
+}
+
+var anyFunction = &Function{Return: ANY}
+
+// Maps the type keywords of the language to their types
+func fromName(name string) Type {
+    switch name {
+    case "int":
+        return INT
+    case "float":
+        return FLOAT
+    case "bool":
+        return BOOL
+    case "string":
+        return STRING
+    case "list":
+        return LIST
+    case "map":
+        return MAP
+    case "fun":
+        return anyFunction
+    }
+    return ANY
+}
+
+func isNumeric(t Type) bool {
+    return t == INT || t == FLOAT
+}
+
+// Whether a value of type from can be stored where a to is declared. Ints
+// widen to floats, same as at runtime.
+func assignable(to Type, from Type) bool {
+    if to == ANY || from == ANY {
+        return true
+    }
+    if to == FLOAT && from == INT {
+        return true
+    }
+
+    toFun, ok := to.(*Function)
+    if !ok {
+        return to == from
+    }
+    fromFun, ok := from.(*Function)
+    if !ok {
+        return false
+    }
+    if toFun.Params == nil || fromFun.Params == nil {
+        return true
+    }
+    if len(toFun.Params) != len(fromFun.Params) {
+        return false
+    }
+    for i := range toFun.Params {
+        if !assignable(fromFun.Params[i], toFun.Params[i]) {
+            return false
+        }
+    }
+    return assignable(toFun.Return, fromFun.Return)
+}
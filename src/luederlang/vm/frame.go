@@ -0,0 +1,58 @@
+package vm
+
+import (
+    "luederlang/object"
+    "luederlang/token"
+)
+
+type Frame struct {
+    cl *object.Closure
+    ip int
+
+    // where the stack was before the callee and its arguments were pushed
+    basePointer int
+
+    // nil for the main program, which only has globals
+    locals *object.Locals
+
+    // set when a tail call replaced the caller's frame, the frame below no
+    // longer says where this function was called
+    callPos token.Position
+    // return types of the functions that tail called into this one, the
+    // value it returns has to pass them too
+    returnTypes []string
+}
+
+func NewFrame(cl *object.Closure, basePointer int, locals *object.Locals) *Frame {
+    return &Frame{cl: cl, ip: -1, basePointer: basePointer, locals: locals}
+}
+
+func (f *Frame) Instructions() []byte {
+    return f.cl.Fn.Instructions
+}
+
+// The locals of the function depth levels out from this one
+func (f *Frame) outerLocals(depth int) *object.Locals {
+    locals := f.locals
+    for i := 0; i < depth; i++ {
+        locals = locals.Outer
+    }
+    return locals
+}
+
+// What a for-in loop keeps on the stack while it runs
+type iterator struct {
+    items []object.Object
+    next int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string { return "iterator" }
+
+// Where to continue when an error is raised inside a try block: the frame it
+// was set up in, the stack as it was and the catch
+type handler struct {
+    frames int
+    sp int
+    ip int
+}
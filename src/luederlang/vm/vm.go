@@ -0,0 +1,538 @@
+package vm
+
+import (
+    "fmt"
+    "luederlang/ast"
+    "luederlang/compiler"
+    "luederlang/modules"
+    "luederlang/evaluator"
+    "luederlang/object"
+    "strings"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+
+// Modules are compiled and run by a vm of their own, the closures they
+// export carry their unit along
+var imports *modules.Loader
+
+func init() {
+    imports = modules.NewLoader(func(program *ast.Program) (object.Scope, *object.Error) {
+        c := compiler.New()
+        if err := c.Compile(program); err != nil {
+            compileErr := err.(*compiler.CompileError)
+            return nil, &object.Error{Kind: object.INTERNAL_ERROR, Message: compileErr.Message, Pos: compileErr.Pos}
+        }
+        vm := New(c.Bytecode())
+        if err := vm.run(); err != nil {
+            return nil, err
+        }
+        return vm.frames[0].cl.Unit, nil
+    })
+}
+
+type VM struct {
+    builtins []*object.Builtin
+
+    // grows when deep recursion needs more room
+    stack []object.Object
+    sp int // always points to the next free slot, top of stack is stack[sp-1]
+
+    frames []*Frame
+    handlers []handler
+
+    lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+    return NewWithGlobalsState(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// For the repl, so globals survive between lines
+func NewWithGlobalsState(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+    mainFn := &object.CompiledFunction{
+        Instructions: bytecode.Instructions,
+        Positions: bytecode.Positions,
+    }
+    unit := &object.Unit{
+        Constants: bytecode.Constants,
+        Globals: globals,
+        GlobalNames: bytecode.GlobalNames,
+    }
+    mainFrame := NewFrame(&object.Closure{Fn: mainFn, Unit: unit}, 0, nil)
+
+    builtins := []*object.Builtin{}
+    for _, name := range evaluator.BuiltinNames() {
+        builtin, _ := evaluator.LookupBuiltin(name)
+        builtins = append(builtins, builtin)
+    }
+
+    return &VM{
+        builtins: builtins,
+        stack: make([]object.Object, StackSize),
+        frames: []*Frame{mainFrame},
+    }
+}
+
+// Runs the program and returns what it evaluated to, same as evaluator.Eval
+// would: the value of the last statement, a top level return or an error
+func (vm *VM) Run() object.Object {
+    if err := vm.run(); err != nil {
+        return err
+    }
+    return vm.lastPopped
+}
+
+func (vm *VM) run() *object.Error {
+    for {
+        frame := vm.currentFrame()
+        unit := frame.cl.Unit
+        ins := frame.Instructions()
+        if frame.ip >= len(ins)-1 {
+            return nil
+        }
+
+        frame.ip++
+        ip := frame.ip
+        op := compiler.Opcode(ins[ip])
+
+        var err *object.Error
+
+        switch op {
+        case compiler.OpConstant:
+            constIndex := compiler.ReadUint16(ins[ip+1:])
+            frame.ip += 2
+            vm.push(unit.Constants[constIndex])
+
+        case compiler.OpPop:
+            vm.lastPopped = vm.pop()
+
+        case compiler.OpTrue:
+            vm.push(evaluator.TRUE)
+
+        case compiler.OpFalse:
+            vm.push(evaluator.FALSE)
+
+        case compiler.OpNull:
+            vm.push(evaluator.NULL)
+
+        case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv, compiler.OpMod,
+            compiler.OpEqual, compiler.OpNotEqual, compiler.OpLessThan, compiler.OpGreaterThan,
+            compiler.OpAnd, compiler.OpOr:
+            right := vm.pop()
+            left := vm.pop()
+            err = vm.pushResult(evaluator.EvalInfix(left, compiler.Operators[op], right))
+
+        case compiler.OpMinus, compiler.OpBang:
+            right := vm.pop()
+            err = vm.pushResult(evaluator.EvalPrefix(compiler.Operators[op], right))
+
+        case compiler.OpJump:
+            pos := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip = pos - 1
+
+        case compiler.OpJumpNotTruthy:
+            pos := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            if !evaluator.IsTruthy(vm.pop()) {
+                frame.ip = pos - 1
+            }
+
+        case compiler.OpGetGlobal:
+            globalIndex := compiler.ReadUint16(ins[ip+1:])
+            frame.ip += 2
+            val := unit.Globals[globalIndex]
+            if val == nil {
+                // a global that shares its name with a builtin but is not bound
+                name := unit.GlobalNames[globalIndex]
+                if builtin, ok := evaluator.LookupBuiltin(name); ok {
+                    vm.push(builtin)
+                    break
+                }
+                err = newError(object.NAME_ERROR, "identifier not found: %s", name)
+                break
+            }
+            vm.push(val)
+
+        case compiler.OpSetGlobal:
+            globalIndex := compiler.ReadUint16(ins[ip+1:])
+            frame.ip += 2
+            unit.Globals[globalIndex] = vm.pop()
+
+        case compiler.OpGetLocal:
+            locals := frame.outerLocals(int(compiler.ReadUint8(ins[ip+1:])))
+            localIndex := compiler.ReadUint8(ins[ip+2:])
+            frame.ip += 2
+            val := locals.Slots[localIndex]
+            if val == nil {
+                err = newError(object.NAME_ERROR, "identifier not found: %s", locals.Fn.LocalNames[localIndex])
+                break
+            }
+            vm.push(val)
+
+        case compiler.OpSetLocal:
+            locals := frame.outerLocals(int(compiler.ReadUint8(ins[ip+1:])))
+            localIndex := compiler.ReadUint8(ins[ip+2:])
+            frame.ip += 2
+            locals.Slots[localIndex] = vm.pop()
+
+        case compiler.OpAssignGlobal:
+            globalIndex := compiler.ReadUint16(ins[ip+1:])
+            frame.ip += 2
+            val := vm.pop()
+            if unit.Globals[globalIndex] == nil {
+                err = newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", unit.GlobalNames[globalIndex])
+                break
+            }
+            unit.Globals[globalIndex] = val
+
+        case compiler.OpAssignLocal:
+            locals := frame.outerLocals(int(compiler.ReadUint8(ins[ip+1:])))
+            localIndex := compiler.ReadUint8(ins[ip+2:])
+            frame.ip += 2
+            val := vm.pop()
+            if locals.Slots[localIndex] == nil {
+                err = newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", locals.Fn.LocalNames[localIndex])
+                break
+            }
+            locals.Slots[localIndex] = val
+
+        case compiler.OpGetBuiltin:
+            builtinIndex := compiler.ReadUint8(ins[ip+1:])
+            frame.ip += 1
+            vm.push(vm.builtins[builtinIndex])
+
+        case compiler.OpList:
+            numElements := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            elements := make([]object.Object, numElements)
+            copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+            vm.sp -= numElements
+            vm.push(&object.List{Elements: elements})
+
+        case compiler.OpInterpolate:
+            numParts := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            var out strings.Builder
+            for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+                out.WriteString(part.Inspect())
+            }
+            vm.sp -= numParts
+            vm.push(&object.String{Value: out.String()})
+
+        case compiler.OpMap:
+            numPairs := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            err = vm.buildMap(numPairs)
+
+        case compiler.OpIndex:
+            index := vm.pop()
+            left := vm.pop()
+            err = vm.pushResult(evaluator.EvalIndex(left, index))
+
+        case compiler.OpSlice:
+            bounds := compiler.ReadUint8(ins[ip+1:])
+            frame.ip += 1
+            var start, end object.Object
+            if bounds&2 != 0 {
+                end = vm.pop()
+            }
+            if bounds&1 != 0 {
+                start = vm.pop()
+            }
+            left := vm.pop()
+            err = vm.pushResult(evaluator.EvalSlice(left, start, end))
+
+        case compiler.OpSetIndex:
+            val := vm.pop()
+            index := vm.pop()
+            left := vm.pop()
+            if result := evaluator.EvalIndexAssignment(left, index, val); result != nil {
+                err = result.(*object.Error)
+            }
+
+        case compiler.OpCoerce:
+            typeName := unit.Constants[compiler.ReadUint16(ins[ip+1:])].(*object.String).Value
+            name := unit.Constants[compiler.ReadUint16(ins[ip+3:])].(*object.String).Value
+            frame.ip += 4
+            val, ok := evaluator.CoerceToType(typeName, vm.pop())
+            if !ok {
+                err = newError(object.TYPE_ERROR, "cannot assign %s to %s %s", object.TypeName(val), typeName, name)
+                break
+            }
+            vm.push(val)
+
+        case compiler.OpDupPair:
+            vm.push(vm.stack[vm.sp-2])
+            vm.push(vm.stack[vm.sp-2])
+
+        case compiler.OpIter:
+            items, iterErr := evaluator.IterationItems(vm.pop())
+            if iterErr != nil {
+                err = iterErr
+                break
+            }
+            vm.push(&iterator{items: items})
+
+        case compiler.OpIterNext:
+            pos := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            it := vm.stack[vm.sp-1].(*iterator)
+            if it.next >= len(it.items) {
+                frame.ip = pos - 1
+                break
+            }
+            vm.push(it.items[it.next])
+            it.next++
+
+        case compiler.OpTry:
+            catchPos := int(compiler.ReadUint16(ins[ip+1:]))
+            frame.ip += 2
+            vm.handlers = append(vm.handlers, handler{frames: len(vm.frames), sp: vm.sp, ip: catchPos})
+
+        case compiler.OpEndTry:
+            vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+        case compiler.OpThrow:
+            err = evaluator.Throw(vm.pop())
+
+        case compiler.OpImport:
+            path := unit.Constants[compiler.ReadUint16(ins[ip+1:])].(*object.String).Value
+            frame.ip += 2
+            from := frame.cl.Fn.PositionAt(ip).Filename
+            module, importErr := imports.Import(path, from)
+            if importErr != nil {
+                err = importErr
+                break
+            }
+            vm.push(module)
+
+        case compiler.OpClosure:
+            constIndex := compiler.ReadUint16(ins[ip+1:])
+            frame.ip += 2
+            fn := unit.Constants[constIndex].(*object.CompiledFunction)
+            vm.push(&object.Closure{Fn: fn, Free: frame.locals, Unit: unit})
+
+        case compiler.OpCall:
+            numArgs := int(compiler.ReadUint8(ins[ip+1:]))
+            frame.ip += 1
+            err = vm.callFunction(numArgs, false)
+
+        case compiler.OpTailCall:
+            numArgs := int(compiler.ReadUint8(ins[ip+1:]))
+            frame.ip += 1
+            err = vm.callFunction(numArgs, true)
+
+        case compiler.OpReturnValue:
+            returnValue := vm.pop()
+
+            // a return at the top level ends the program
+            if len(vm.frames) == 1 {
+                vm.lastPopped = returnValue
+                return nil
+            }
+
+            returning := vm.popFrame()
+            vm.sp = returning.basePointer
+            vm.dropHandlers()
+
+            returnValue, err = coerceReturn(returning, returnValue)
+            if err != nil {
+                break
+            }
+            vm.push(returnValue)
+
+        default:
+            err = newError(object.INTERNAL_ERROR, "unknown opcode %d", op)
+        }
+
+        if err != nil {
+            // the instruction that failed is where the error happened
+            if !err.Pos.IsValid() {
+                current := vm.currentFrame()
+                err.Pos = current.cl.Fn.PositionAt(current.ip)
+            }
+            if !vm.catch(err) {
+                return err
+            }
+        }
+    }
+}
+
+// Unwinds to the innermost try block and continues at its catch with the
+// error on the stack, adding the calls it unwinds through to the trace.
+// Returns false if nothing catches err.
+func (vm *VM) catch(err *object.Error) bool {
+    frames := 1
+    if len(vm.handlers) > 0 {
+        frames = vm.handlers[len(vm.handlers)-1].frames
+    }
+    for len(vm.frames) > frames {
+        returning := vm.popFrame()
+        caller := vm.currentFrame()
+        pos := returning.callPos
+        if !pos.IsValid() {
+            pos = caller.cl.Fn.PositionAt(caller.ip)
+        }
+        err.Trace = append(err.Trace, object.TraceEntry{Function: returning.cl.Fn.Name, Pos: pos})
+    }
+
+    if len(vm.handlers) == 0 {
+        return false
+    }
+    h := vm.handlers[len(vm.handlers)-1]
+    vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+    vm.sp = h.sp
+    vm.push(&object.ErrorValue{Err: err})
+    vm.currentFrame().ip = h.ip - 1
+    return true
+}
+
+// A return out of a try block leaves its handler behind
+func (vm *VM) dropHandlers() {
+    for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frames > len(vm.frames) {
+        vm.handlers = vm.handlers[:len(vm.handlers)-1]
+    }
+}
+
+// The value a frame returns, checked against its function's return type and
+// the ones of the functions that tail called into it
+func coerceReturn(frame *Frame, value object.Object) (object.Object, *object.Error) {
+    returnTypes := frame.returnTypes
+    if returnType := frame.cl.Fn.ReturnType; returnType != "" {
+        returnTypes = append(returnTypes[:len(returnTypes):len(returnTypes)], returnType)
+    }
+    for i := len(returnTypes) - 1; i >= 0; i-- {
+        result, ok := evaluator.CoerceToType(returnTypes[i], value)
+        if !ok {
+            return nil, newError(object.TYPE_ERROR, "cannot return %s from fun returning %s", object.TypeName(value), returnTypes[i])
+        }
+        value = result
+    }
+    return value, nil
+}
+
+// A tail call runs a closure in place of the current frame
+func (vm *VM) callFunction(numArgs int, tail bool) *object.Error {
+    callee := vm.stack[vm.sp-1-numArgs]
+    args := make([]object.Object, numArgs)
+    copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+    basePointer := vm.sp - numArgs - 1
+
+    switch callee := callee.(type) {
+    case *object.Closure:
+        fn := callee.Fn
+        if numArgs != fn.NumParameters {
+            return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=%d. got=%d", fn.NumParameters, numArgs)
+        }
+
+        locals := &object.Locals{Fn: fn, Slots: make([]object.Object, len(fn.LocalNames)), Outer: callee.Free}
+        for i, arg := range args {
+            if typeName := fn.ParameterTypes[i]; typeName != "" {
+                var ok bool
+                if arg, ok = evaluator.CoerceToType(typeName, arg); !ok {
+                    return newError(object.TYPE_ERROR, "cannot pass %s as %s %s", object.TypeName(arg), typeName, fn.LocalNames[i])
+                }
+            }
+            locals.Slots[i] = arg
+        }
+
+        // the main program's frame is not a call
+        if !tail && len(vm.frames)-1 >= evaluator.MaxCallDepth {
+            return newError(object.RECURSION_ERROR, "maximum recursion depth exceeded")
+        }
+
+        if tail {
+            caller := vm.popFrame()
+            frame := NewFrame(callee, caller.basePointer, locals)
+            frame.callPos = caller.cl.Fn.PositionAt(caller.ip)
+            frame.returnTypes = caller.returnTypes
+            if returnType := caller.cl.Fn.ReturnType; returnType != "" &&
+                (len(frame.returnTypes) == 0 || frame.returnTypes[len(frame.returnTypes)-1] != returnType) {
+                frame.returnTypes = append(frame.returnTypes, returnType)
+            }
+            vm.sp = caller.basePointer
+            vm.pushFrame(frame)
+            return nil
+        }
+
+        // the arguments live in locals now, so the callee can drop them
+        vm.sp = basePointer
+        vm.pushFrame(NewFrame(callee, basePointer, locals))
+        return nil
+
+    case *object.Builtin:
+        vm.sp = basePointer
+        result := callee.Function(args...)
+        if result == nil {
+            result = evaluator.NULL
+        }
+        return vm.pushResult(result)
+
+    default:
+        return newError(object.TYPE_ERROR, "not a function: %s", object.TypeName(callee))
+    }
+}
+
+func (vm *VM) buildMap(numPairs int) *object.Error {
+    m := object.NewMap()
+    start := vm.sp - numPairs*2
+
+    for i := start; i < vm.sp; i += 2 {
+        key := vm.stack[i]
+        value := vm.stack[i+1]
+
+        hashKey, ok := key.(object.Hashable)
+        if !ok {
+            return newError(object.TYPE_ERROR, "unusable as map key: %s", object.TypeName(key))
+        }
+        m.Set(hashKey, value)
+    }
+
+    vm.sp = start
+    vm.push(m)
+    return nil
+}
+
+// Pushes the result of an operation unless it failed
+func (vm *VM) pushResult(result object.Object) *object.Error {
+    if err, ok := result.(*object.Error); ok {
+        return err
+    }
+    vm.push(result)
+    return nil
+}
+
+func (vm *VM) push(o object.Object) {
+    if vm.sp >= len(vm.stack) {
+        vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+    }
+    vm.stack[vm.sp] = o
+    vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+    o := vm.stack[vm.sp-1]
+    vm.sp--
+    return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+    return vm.frames[len(vm.frames)-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+    vm.frames = append(vm.frames, f)
+}
+
+func (vm *VM) popFrame() *Frame {
+    f := vm.frames[len(vm.frames)-1]
+    vm.frames = vm.frames[:len(vm.frames)-1]
+    return f
+}
+
+func newError(kind string, format string, a ...interface{}) *object.Error {
+    return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}